@@ -0,0 +1,38 @@
+// Analog output support.
+
+package embd
+
+import "math"
+
+// AnalogOutput implements access to an analog output, such as a channel of
+// a DAC.
+type AnalogOutput interface {
+	// Write sets the output to value, from 0 to Max. Max+1 would set the
+	// output to the reference voltage of the DAC.
+	Write(value int) error
+
+	// Max returns the largest value of the output, 4095 for a 12-bit DAC.
+	Max() int
+
+	// Close releases the resources associated with the output.
+	Close() error
+}
+
+// VoltsToValue converts volts to a value of an output with the given
+// maximum and reference voltage, clamped to 0-max.
+func VoltsToValue(volts, vref float64, max int) int {
+	v := math.Floor(volts/vref*float64(max+1) + 0.5)
+	switch {
+	case v > float64(max):
+		return max
+	case v < 0 || math.IsNaN(v):
+		return 0
+	}
+	return int(v)
+}
+
+// WriteVolts sets out to volts, vref being the reference voltage of the
+// DAC. Voltages out of the range of the output are clamped.
+func WriteVolts(out AnalogOutput, volts, vref float64) error {
+	return out.Write(VoltsToValue(volts, vref, out.Max()))
+}
@@ -0,0 +1,47 @@
+package embd
+
+import (
+	"math"
+	"testing"
+)
+
+type fakeAnalogOutput struct {
+	value int
+}
+
+func (o *fakeAnalogOutput) Write(value int) error {
+	o.value = value
+	return nil
+}
+
+func (o *fakeAnalogOutput) Max() int {
+	return 4095
+}
+
+func (o *fakeAnalogOutput) Close() error {
+	return nil
+}
+
+func TestWriteVolts(t *testing.T) {
+	tests := []struct {
+		volts float64
+		want  int
+	}{
+		{0, 0},
+		{1.65, 2048},
+		{1, 1241},
+		{3.3, 4095},
+		{5, 4095},
+		{-1, 0},
+		{math.NaN(), 0},
+	}
+	for _, test := range tests {
+		out := &fakeAnalogOutput{}
+		if err := WriteVolts(out, test.volts, 3.3); err != nil {
+			t.Fatal(err)
+		}
+		if out.value != test.want {
+			t.Errorf("WriteVolts(%v, 3.3): got %v, want %v", test.volts, out.value, test.want)
+		}
+	}
+}
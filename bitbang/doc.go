@@ -0,0 +1,4 @@
+// Package bitbang implements buses and PWM in software on top of plain
+// digital pins, for when the hardware controllers are unavailable or already
+// in use.
+package bitbang
@@ -0,0 +1,404 @@
+// Software I²C support.
+
+package bitbang
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	// DefaultI2CSpeed is the clock frequency used when I2CBus.Speed is not set.
+	DefaultI2CSpeed = 100000
+
+	// DefaultStretchTimeout is the time a slave may hold the clock low when
+	// I2CBus.StretchTimeout is not set.
+	DefaultStretchTimeout = 25 * time.Millisecond
+)
+
+var (
+	errStretchTimeout = errors.New("bitbang: i2c clock stretching timed out")
+	errBusStuck       = errors.New("bitbang: i2c bus stuck, SDA held low")
+	errArbitration    = errors.New("bitbang: i2c arbitration lost")
+)
+
+// I2CBus is an I²C master driving two digital pins. The pins should be
+// pulled up (externally, or by the pin itself where supported): a line is
+// pulled low by switching its pin to an output, and released by switching
+// it back to an input, emulating an open-drain output.
+type I2CBus struct {
+	SDA, SCL embd.DigitalPin
+
+	// Speed is the clock frequency in Hz.
+	Speed int
+
+	// StretchTimeout is the longest time a slave may hold the clock low.
+	StretchTimeout time.Duration
+
+	mu          sync.Mutex
+	half        time.Duration
+	initialized bool
+}
+
+// NewI2CBus returns a software I²C bus using the given data and clock pins.
+func NewI2CBus(sda, scl embd.DigitalPin) *I2CBus {
+	return &I2CBus{SDA: sda, SCL: scl}
+}
+
+func (b *I2CBus) setup() error {
+	if b.initialized {
+		return nil
+	}
+
+	if b.Speed == 0 {
+		b.Speed = DefaultI2CSpeed
+	}
+	if b.StretchTimeout == 0 {
+		b.StretchTimeout = DefaultStretchTimeout
+	}
+	b.half = time.Second / time.Duration(2*b.Speed)
+
+	for _, pin := range []embd.DigitalPin{b.SDA, b.SCL} {
+		// Not every pin has a pull up; the lines may be pulled up externally.
+		pin.PullUp()
+
+		// Latch a low output value so that switching the pin to an output
+		// only ever pulls the line low.
+		if err := pin.SetDirection(embd.Out); err != nil {
+			return err
+		}
+		if err := pin.Write(embd.Low); err != nil {
+			return err
+		}
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+	}
+
+	glog.V(2).Infof("bitbang: i2c bus initialized at %vHz", b.Speed)
+
+	b.initialized = true
+
+	return nil
+}
+
+// delay waits for half a clock period. Sleeping is far too coarse for
+// the periods involved, so it busy waits.
+func (b *I2CBus) delay() {
+	for start := time.Now(); time.Since(start) < b.half; {
+	}
+}
+
+func pull(pin embd.DigitalPin) error {
+	return pin.SetDirection(embd.Out)
+}
+
+func release(pin embd.DigitalPin) error {
+	return pin.SetDirection(embd.In)
+}
+
+// releaseSCL releases the clock and waits for it to go high, allowing the
+// slave to stretch the clock.
+func (b *I2CBus) releaseSCL() error {
+	if err := release(b.SCL); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(b.StretchTimeout)
+	for {
+		v, err := b.SCL.Read()
+		if err != nil {
+			return err
+		}
+		if v == embd.High {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errStretchTimeout
+		}
+		runtime.Gosched()
+	}
+}
+
+// recover clocks a slave stuck in the middle of a transfer until it lets
+// go of the data line.
+func (b *I2CBus) recover() error {
+	for i := 0; i < 9; i++ {
+		if err := pull(b.SCL); err != nil {
+			return err
+		}
+		b.delay()
+		if err := b.releaseSCL(); err != nil {
+			return err
+		}
+		b.delay()
+		v, err := b.SDA.Read()
+		if err != nil {
+			return err
+		}
+		if v == embd.High {
+			glog.V(1).Infof("bitbang: i2c bus recovered after %v clocks", i+1)
+			return nil
+		}
+	}
+	return errBusStuck
+}
+
+// start issues a start condition, or a repeated start in the middle of a
+// transfer.
+func (b *I2CBus) start() error {
+	if err := release(b.SDA); err != nil {
+		return err
+	}
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return err
+	}
+	b.delay()
+	v, err := b.SDA.Read()
+	if err != nil {
+		return err
+	}
+	if v == embd.Low {
+		if err := b.recover(); err != nil {
+			return err
+		}
+	}
+	if err := pull(b.SDA); err != nil {
+		return err
+	}
+	b.delay()
+	if err := pull(b.SCL); err != nil {
+		return err
+	}
+	b.delay()
+	return nil
+}
+
+func (b *I2CBus) stop() error {
+	if err := pull(b.SDA); err != nil {
+		return err
+	}
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return err
+	}
+	b.delay()
+	if err := release(b.SDA); err != nil {
+		return err
+	}
+	b.delay()
+	return nil
+}
+
+func (b *I2CBus) writeBit(bit int) error {
+	var err error
+	if bit == embd.High {
+		err = release(b.SDA)
+	} else {
+		err = pull(b.SDA)
+	}
+	if err != nil {
+		return err
+	}
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return err
+	}
+	b.delay()
+	if bit == embd.High {
+		v, err := b.SDA.Read()
+		if err != nil {
+			return err
+		}
+		if v == embd.Low {
+			return errArbitration
+		}
+	}
+	return pull(b.SCL)
+}
+
+func (b *I2CBus) readBit() (int, error) {
+	if err := release(b.SDA); err != nil {
+		return 0, err
+	}
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return 0, err
+	}
+	b.delay()
+	v, err := b.SDA.Read()
+	if err != nil {
+		return 0, err
+	}
+	return v, pull(b.SCL)
+}
+
+// writeByte clocks out a byte, MSB first, and reports whether the slave
+// acknowledged it.
+func (b *I2CBus) writeByte(value byte) (bool, error) {
+	for i := uint(0); i < 8; i++ {
+		if err := b.writeBit(int(value>>(7-i)) & 1); err != nil {
+			return false, err
+		}
+	}
+	v, err := b.readBit()
+	return v == embd.Low, err
+}
+
+// readByte clocks in a byte, MSB first, acknowledging it if ack is set.
+func (b *I2CBus) readByte(ack bool) (byte, error) {
+	var value byte
+	for i := 0; i < 8; i++ {
+		v, err := b.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | byte(v)
+	}
+	bit := embd.High
+	if ack {
+		bit = embd.Low
+	}
+	return value, b.writeBit(bit)
+}
+
+// transfer writes w to the slave at addr, then reads len(r) bytes into r
+// after a repeated start. Either phase is skipped if its buffer is nil.
+func (b *I2CBus) transfer(addr byte, w, r []byte) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setup(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if serr := b.stop(); err == nil {
+			err = serr
+		}
+	}()
+
+	if w != nil {
+		if err := b.start(); err != nil {
+			return err
+		}
+		if err := b.writeAddr(addr, 0); err != nil {
+			return err
+		}
+		for i, value := range w {
+			ack, err := b.writeByte(value)
+			if err != nil {
+				return err
+			}
+			if !ack {
+				return fmt.Errorf("bitbang: i2c device %#02x did not acknowledge byte %v", addr, i)
+			}
+		}
+	}
+
+	if r != nil {
+		if err := b.start(); err != nil {
+			return err
+		}
+		if err := b.writeAddr(addr, 1); err != nil {
+			return err
+		}
+		for i := range r {
+			if r[i], err = b.readByte(i < len(r)-1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *I2CBus) writeAddr(addr, rw byte) error {
+	ack, err := b.writeByte(addr<<1 | rw)
+	if err != nil {
+		return err
+	}
+	if !ack {
+		return fmt.Errorf("bitbang: no i2c device at %#02x", addr)
+	}
+	return nil
+}
+
+func (b *I2CBus) ReadByte(addr byte) (byte, error) {
+	buf := make([]byte, 1)
+	if err := b.transfer(addr, nil, buf); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+func (b *I2CBus) ReadBytes(addr byte, num int) ([]byte, error) {
+	buf := make([]byte, num)
+	if err := b.transfer(addr, nil, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (b *I2CBus) WriteByte(addr, value byte) error {
+	return b.transfer(addr, []byte{value}, nil)
+}
+
+func (b *I2CBus) WriteBytes(addr byte, value []byte) error {
+	return b.transfer(addr, value, nil)
+}
+
+func (b *I2CBus) ReadFromReg(addr, reg byte, value []byte) error {
+	return b.transfer(addr, []byte{reg}, value)
+}
+
+func (b *I2CBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	buf := make([]byte, 1)
+	if err := b.ReadFromReg(addr, reg, buf); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+func (b *I2CBus) ReadWordFromReg(addr, reg byte) (uint16, error) {
+	buf := make([]byte, 2)
+	if err := b.ReadFromReg(addr, reg, buf); err != nil {
+		return 0, err
+	}
+	return uint16(buf[0])<<8 | uint16(buf[1]), nil
+}
+
+func (b *I2CBus) WriteToReg(addr, reg byte, value []byte) error {
+	return b.transfer(addr, append([]byte{reg}, value...), nil)
+}
+
+func (b *I2CBus) WriteByteToReg(addr, reg, value byte) error {
+	return b.transfer(addr, []byte{reg, value}, nil)
+}
+
+func (b *I2CBus) WriteWordToReg(addr, reg byte, value uint16) error {
+	return b.transfer(addr, []byte{reg, byte(value >> 8), byte(value)}, nil)
+}
+
+// Close releases both lines. The pins themselves belong to the caller and
+// are left open.
+func (b *I2CBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.initialized {
+		return nil
+	}
+	b.initialized = false
+
+	if err := release(b.SDA); err != nil {
+		return err
+	}
+	return release(b.SCL)
+}
@@ -0,0 +1,262 @@
+package bitbang
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+const (
+	phaseIdle = iota
+	phaseAddr
+	phaseRecv
+	phaseAck
+	phaseSend
+	phaseMasterAck
+)
+
+// i2cSlave emulates a register based I²C device on simulated pins. The
+// first byte written after the address selects the register; subsequent
+// bytes are written to consecutive registers, and reads continue from the
+// selected register.
+type i2cSlave struct {
+	sda, scl *sim.Pin
+	addr     byte
+	regs     [256]byte
+
+	// stretch holds the clock low for the given time after the address
+	// has been acknowledged.
+	stretch time.Duration
+
+	// mu guards the state below. The clock stretching timer releases the
+	// clock from its own goroutine, so the callbacks may run concurrently.
+	// Pin changes are deferred until mu is released, as they call back
+	// into the slave.
+	mu          sync.Mutex
+	pending     []func()
+	phase       int
+	read        bool
+	first       bool
+	bits        int
+	shift       byte
+	ptr         byte
+	masterAcked bool
+}
+
+func newI2CSlave(sda, scl *sim.Pin, addr byte) *i2cSlave {
+	s := &i2cSlave{sda: sda, scl: scl, addr: addr}
+	sda.SetPull(sim.PullUp)
+	scl.SetPull(sim.PullUp)
+	sda.OnChange(s.onSDA)
+	scl.OnChange(s.onSCL)
+	return s
+}
+
+// lock locks the slave; unlock runs the deferred pin changes.
+func (s *i2cSlave) lock() {
+	s.mu.Lock()
+}
+
+func (s *i2cSlave) unlock() {
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for _, f := range pending {
+		f()
+	}
+}
+
+func (s *i2cSlave) drive(pin *sim.Pin, level int) {
+	if level == embd.High {
+		s.pending = append(s.pending, pin.Release)
+		return
+	}
+	s.pending = append(s.pending, func() { pin.Drive(embd.Low) })
+}
+
+func (s *i2cSlave) onSDA(level int) {
+	s.lock()
+	defer s.unlock()
+
+	if s.scl.Level() != embd.High {
+		return
+	}
+	if level == embd.Low {
+		s.phase, s.bits, s.shift, s.first = phaseAddr, 0, 0, true
+		return
+	}
+	s.phase = phaseIdle
+}
+
+func (s *i2cSlave) sample() {
+	s.shift = s.shift<<1 | byte(s.sda.Level())
+	s.bits++
+}
+
+func (s *i2cSlave) output() {
+	s.drive(s.sda, int(s.shift>>7))
+	s.shift <<= 1
+	s.bits++
+}
+
+func (s *i2cSlave) load() {
+	s.shift, s.bits = s.regs[s.ptr], 0
+	s.ptr++
+	s.output()
+}
+
+func (s *i2cSlave) onSCL(level int) {
+	s.lock()
+	defer s.unlock()
+
+	if level == embd.High {
+		switch s.phase {
+		case phaseAddr, phaseRecv:
+			s.sample()
+		case phaseMasterAck:
+			s.masterAcked = s.sda.Level() == embd.Low
+		}
+		return
+	}
+
+	switch s.phase {
+	case phaseAddr:
+		if s.bits < 8 {
+			return
+		}
+		if s.shift>>1 != s.addr {
+			s.phase = phaseIdle
+			return
+		}
+		s.read = s.shift&1 == 1
+		s.drive(s.sda, embd.Low)
+		s.phase = phaseAck
+		if s.stretch > 0 {
+			s.drive(s.scl, embd.Low)
+			time.AfterFunc(s.stretch, s.scl.Release)
+		}
+	case phaseRecv:
+		if s.bits < 8 {
+			return
+		}
+		if s.first {
+			s.ptr = s.shift
+			s.first = false
+		} else {
+			s.regs[s.ptr] = s.shift
+			s.ptr++
+		}
+		s.drive(s.sda, embd.Low)
+		s.phase = phaseAck
+	case phaseAck:
+		s.drive(s.sda, embd.High)
+		if s.read {
+			s.load()
+			s.phase = phaseSend
+		} else {
+			s.bits, s.shift = 0, 0
+			s.phase = phaseRecv
+		}
+	case phaseSend:
+		if s.bits < 8 {
+			s.output()
+			return
+		}
+		s.drive(s.sda, embd.High)
+		s.phase = phaseMasterAck
+	case phaseMasterAck:
+		if s.masterAcked {
+			s.load()
+			s.phase = phaseSend
+		} else {
+			s.phase = phaseIdle
+		}
+	}
+}
+
+func newTestI2C() (*I2CBus, *i2cSlave) {
+	sda, scl := sim.NewPin(0), sim.NewPin(1)
+	s := newI2CSlave(sda, scl, 0x42)
+	b := NewI2CBus(sda, scl)
+	b.Speed = 1000000
+	return b, s
+}
+
+func TestI2CWriteToReg(t *testing.T) {
+	b, s := newTestI2C()
+	if err := b.WriteToReg(0x42, 0x10, []byte{0xde, 0xad}); err != nil {
+		t.Fatalf("Writing registers: got %v", err)
+	}
+	if err := b.WriteWordToReg(0x42, 0x20, 0xbeef); err != nil {
+		t.Fatalf("Writing word: got %v", err)
+	}
+	want := []byte{0xde, 0xad, 0xbe, 0xef}
+	got := []byte{s.regs[0x10], s.regs[0x11], s.regs[0x20], s.regs[0x21]}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Writing registers: slave has %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestI2CReadFromReg(t *testing.T) {
+	b, s := newTestI2C()
+	s.regs[0x30], s.regs[0x31], s.regs[0x32] = 0x12, 0x34, 0x56
+	buf := make([]byte, 3)
+	if err := b.ReadFromReg(0x42, 0x30, buf); err != nil {
+		t.Fatalf("Reading registers: got %v", err)
+	}
+	if buf[0] != 0x12 || buf[1] != 0x34 || buf[2] != 0x56 {
+		t.Fatalf("Reading registers: got %#v, want %#v", buf, []byte{0x12, 0x34, 0x56})
+	}
+	w, err := b.ReadWordFromReg(0x42, 0x31)
+	if err != nil {
+		t.Fatalf("Reading word: got %v", err)
+	}
+	if w != 0x3456 {
+		t.Fatalf("Reading word: got %#04x, want %#04x", w, 0x3456)
+	}
+	// A plain read continues where the last one left off.
+	v, err := b.ReadByte(0x42)
+	if err != nil {
+		t.Fatalf("Reading byte: got %v", err)
+	}
+	if v != s.regs[0x33] {
+		t.Fatalf("Reading byte: got %#02x, want %#02x", v, s.regs[0x33])
+	}
+}
+
+func TestI2CNoDevice(t *testing.T) {
+	b, _ := newTestI2C()
+	if err := b.WriteByte(0x43, 0); err == nil {
+		t.Fatal("Writing to missing device: got nil error")
+	}
+	if b.SDA.(*sim.Pin).Level() != embd.High || b.SCL.(*sim.Pin).Level() != embd.High {
+		t.Fatal("Writing to missing device: bus not released")
+	}
+}
+
+func TestI2CClockStretching(t *testing.T) {
+	b, s := newTestI2C()
+	s.stretch = 5 * time.Millisecond
+	start := time.Now()
+	if err := b.WriteByteToReg(0x42, 0x01, 0x99); err != nil {
+		t.Fatalf("Writing with clock stretching: got %v", err)
+	}
+	if d := time.Since(start); d < s.stretch {
+		t.Fatalf("Writing with clock stretching: took %v, want at least %v", d, s.stretch)
+	}
+	if s.regs[0x01] != 0x99 {
+		t.Fatalf("Writing with clock stretching: slave has %#02x, want %#02x", s.regs[0x01], 0x99)
+	}
+
+	s.stretch = 50 * time.Millisecond
+	b.StretchTimeout = time.Millisecond
+	if err := b.WriteByteToReg(0x42, 0x01, 0x99); err != errStretchTimeout {
+		t.Fatalf("Writing with a too long stretch: got %v, want %v", err, errStretchTimeout)
+	}
+}
@@ -0,0 +1,412 @@
+// Software PWM support.
+
+package bitbang
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/util"
+)
+
+const (
+	// PWMDefaultPeriod is the period (20000000ns, 50 Hz) of a software PWM
+	// channel until set, suitable for driving servos.
+	PWMDefaultPeriod = 20000000
+
+	// PWMMinPeriod is the shortest period (100000ns, 10 kHz) accepted for a
+	// software PWM channel.
+	PWMMinPeriod = 100000
+
+	// DefaultMaxChannels is the number of channels DefaultScheduler drives
+	// concurrently.
+	DefaultMaxChannels = 8
+
+	// spinThreshold is the time before an edge at which the scheduler stops
+	// sleeping and busy waits, as sleeps are not precise enough.
+	spinThreshold = 200 * time.Microsecond
+)
+
+// ErrTooManyChannels is returned when starting a software PWM channel on a
+// scheduler which already drives its maximum number of channels.
+var ErrTooManyChannels = errors.New("bitbang: too many pwm channels")
+
+// PWMStats describes how late the edges of software PWM channels were
+// generated compared to when they were due.
+type PWMStats struct {
+	// Edges is the number of edges generated.
+	Edges int64
+
+	// Mean and Max are the mean and the largest delay of an edge.
+	Mean, Max time.Duration
+}
+
+type jitter struct {
+	edges int64
+	total time.Duration
+	max   time.Duration
+}
+
+func (j *jitter) add(d time.Duration) {
+	j.edges++
+	j.total += d
+	if d > j.max {
+		j.max = d
+	}
+}
+
+func (j *jitter) stats() PWMStats {
+	s := PWMStats{Edges: j.edges, Max: j.max}
+	if j.edges > 0 {
+		s.Mean = j.total / time.Duration(j.edges)
+	}
+	return s
+}
+
+// Scheduler generates the edges of software PWM channels from a single
+// goroutine, sleeping until shortly before each edge and busy waiting for
+// the rest. The goroutine only runs while channels are active.
+type Scheduler struct {
+	maxChannels int
+
+	mu       sync.Mutex
+	channels []*PWMPin
+	jitter   jitter
+	running  bool
+	wake     chan struct{}
+}
+
+// DefaultScheduler drives the software PWM pins created with NewPWMPin.
+var DefaultScheduler = NewScheduler(DefaultMaxChannels)
+
+// NewScheduler returns a scheduler driving at most maxChannels channels.
+func NewScheduler(maxChannels int) *Scheduler {
+	return &Scheduler{maxChannels: maxChannels, wake: make(chan struct{}, 1)}
+}
+
+// Stats returns the jitter statistics of all the channels driven by the
+// scheduler.
+func (s *Scheduler) Stats() PWMStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.jitter.stats()
+}
+
+// ResetStats clears the jitter statistics of the scheduler and its
+// channels.
+func (s *Scheduler) ResetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jitter = jitter{}
+	for _, p := range s.channels {
+		p.jitter = jitter{}
+	}
+}
+
+// add starts driving p. s.mu must be held.
+func (s *Scheduler) add(p *PWMPin) error {
+	if len(s.channels) >= s.maxChannels {
+		return ErrTooManyChannels
+	}
+	s.channels = append(s.channels, p)
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	s.poke()
+	return nil
+}
+
+// remove stops driving p. s.mu must be held.
+func (s *Scheduler) remove(p *PWMPin) {
+	for i, c := range s.channels {
+		if c == p {
+			s.channels = append(s.channels[:i], s.channels[i+1:]...)
+			break
+		}
+	}
+	s.poke()
+}
+
+// poke wakes the scheduler up to take changes into account.
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the channel with the earliest edge due. s.mu must be held.
+func (s *Scheduler) next() (*PWMPin, time.Time) {
+	var first *PWMPin
+	var due time.Time
+	for _, p := range s.channels {
+		if t := p.due(); first == nil || t.Before(due) {
+			first, due = p, t
+		}
+	}
+	return first, due
+}
+
+func (s *Scheduler) run() {
+	// Keep the goroutine on its own thread, out of the way of the others.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	timer := time.NewTimer(time.Hour)
+	for {
+		s.mu.Lock()
+		p, due := s.next()
+		if p == nil {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		if d := due.Sub(time.Now()) - spinThreshold; d > 0 {
+			timer.Reset(d)
+			select {
+			case <-timer.C:
+			case <-s.wake:
+				if !timer.Stop() {
+					<-timer.C
+				}
+				continue
+			}
+		}
+		for time.Now().Before(due) {
+		}
+
+		s.mu.Lock()
+		// The channel may have been changed or removed while waiting.
+		if q, d := s.next(); q == p && d.Equal(due) {
+			late := time.Since(due)
+			p.jitter.add(late)
+			s.jitter.add(late)
+			if err := p.edge(due); err != nil {
+				glog.Errorf("bitbang: pwm pin %v: %v", p.n, err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// PWMPin generates PWM in software on a digital pin. It implements
+// embd.PWMPin.
+type PWMPin struct {
+	n     string
+	pin   embd.DigitalPin
+	sched *Scheduler
+
+	// The following are guarded by sched.mu.
+	period   int
+	duty     int
+	polarity embd.Polarity
+
+	start  time.Time // Start of the current period.
+	active bool      // Whether the duty cycle end is the next edge.
+	on     bool      // Whether the output is at the active level.
+	level  int
+	jitter jitter
+
+	initialized bool
+}
+
+// NewPWMPin returns a software PWM pin on pin, driven by DefaultScheduler.
+// The PWM pin takes ownership of pin and closes it when closed.
+func NewPWMPin(pin embd.DigitalPin) *PWMPin {
+	return DefaultScheduler.NewPWMPin(pin)
+}
+
+// NewPWMPin returns a software PWM pin on pin driven by the scheduler.
+// The PWM pin takes ownership of pin and closes it when closed.
+func (s *Scheduler) NewPWMPin(pin embd.DigitalPin) *PWMPin {
+	return &PWMPin{
+		n:      strconv.Itoa(pin.N()),
+		pin:    pin,
+		sched:  s,
+		period: PWMDefaultPeriod,
+	}
+}
+
+// NewPWMPinFactory returns a PWM pin factory for embd.NewGPIODriver,
+// generating PWM in software on the digital pins created by dpf. Hosts
+// without PWM hardware can use it to support embd.NewPWMPin.
+func NewPWMPinFactory(dpf func(pd *embd.PinDesc, drv embd.GPIODriver) embd.DigitalPin) func(pd *embd.PinDesc, drv embd.GPIODriver) embd.PWMPin {
+	return func(pd *embd.PinDesc, drv embd.GPIODriver) embd.PWMPin {
+		p := NewPWMPin(dpf(pd, drv))
+		p.n = pd.ID
+		return p
+	}
+}
+
+func (p *PWMPin) N() string {
+	return p.n
+}
+
+// init sets the pin up and starts driving it. p.sched.mu must be held.
+func (p *PWMPin) init() error {
+	if p.initialized {
+		return nil
+	}
+
+	if err := p.pin.SetDirection(embd.Out); err != nil {
+		return err
+	}
+	p.level = -1
+	if err := p.write(false); err != nil {
+		return err
+	}
+	if err := p.sched.add(p); err != nil {
+		return err
+	}
+	p.start = time.Now()
+	p.active = false
+
+	glog.V(2).Infof("bitbang: pwm pin %v initialized", p.n)
+
+	p.initialized = true
+
+	return nil
+}
+
+// write sets the output to the active or inactive level.
+func (p *PWMPin) write(active bool) error {
+	level := embd.Low
+	if active == (p.polarity == embd.Positive) {
+		level = embd.High
+	}
+	p.on = active
+	if level == p.level {
+		return nil
+	}
+	p.level = level
+	return p.pin.Write(level)
+}
+
+// due returns the time of the next edge. p.sched.mu must be held.
+func (p *PWMPin) due() time.Time {
+	if p.active {
+		return p.start.Add(time.Duration(p.duty))
+	}
+	return p.start.Add(time.Duration(p.period))
+}
+
+// edge generates the edge due at t. p.sched.mu must be held.
+func (p *PWMPin) edge(t time.Time) error {
+	if p.active {
+		p.active = false
+		return p.write(false)
+	}
+
+	p.start = t
+	// Resynchronize rather than catching up when too far behind.
+	if now := time.Now(); now.Sub(t) > time.Duration(p.period) {
+		p.start = now
+	}
+	p.active = p.duty > 0 && p.duty < p.period
+	return p.write(p.duty > 0)
+}
+
+func (p *PWMPin) SetPeriod(ns int) error {
+	p.sched.mu.Lock()
+	defer p.sched.mu.Unlock()
+
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if ns < PWMMinPeriod {
+		return fmt.Errorf("bitbang: pwm period for %v is out of bounds (must be >= %vns)", p.n, PWMMinPeriod)
+	}
+	if p.duty > ns {
+		return fmt.Errorf("bitbang: pwm period %v for pin %v is less than the duty %v", ns, p.n, p.duty)
+	}
+
+	p.period = ns
+	p.sched.poke()
+
+	return nil
+}
+
+func (p *PWMPin) SetDuty(ns int) error {
+	p.sched.mu.Lock()
+	defer p.sched.mu.Unlock()
+
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if ns < 0 || ns > p.period {
+		return fmt.Errorf("bitbang: pwm duty %v for pin %v is out of bounds (must be within the period %v)", ns, p.n, p.period)
+	}
+
+	p.duty = ns
+	p.sched.poke()
+
+	return nil
+}
+
+func (p *PWMPin) SetPolarity(pol embd.Polarity) error {
+	p.sched.mu.Lock()
+	defer p.sched.mu.Unlock()
+
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	p.polarity = pol
+	return p.write(p.on)
+}
+
+func (p *PWMPin) SetMicroseconds(us int) error {
+	p.sched.mu.Lock()
+	period := p.period
+	p.sched.mu.Unlock()
+
+	if period != PWMDefaultPeriod {
+		glog.Warningf("bitbang: pwm pin %v has freq %v hz. recommended 50 hz for servo mode", p.n, 1000000000/period)
+	}
+	return p.SetDuty(us * 1000)
+}
+
+func (p *PWMPin) SetAnalog(value byte) error {
+	p.sched.mu.Lock()
+	period := p.period
+	p.sched.mu.Unlock()
+
+	return p.SetDuty(int(util.Map(int64(value), 0, 255, 0, int64(period))))
+}
+
+// Stats returns the jitter statistics of the pin.
+func (p *PWMPin) Stats() PWMStats {
+	p.sched.mu.Lock()
+	defer p.sched.mu.Unlock()
+
+	return p.jitter.stats()
+}
+
+// Close stops the PWM, leaving the pin inactive, and closes the pin.
+func (p *PWMPin) Close() error {
+	p.sched.mu.Lock()
+	if p.initialized {
+		p.sched.remove(p)
+		p.initialized = false
+		if err := p.write(false); err != nil {
+			p.sched.mu.Unlock()
+			return err
+		}
+	}
+	p.sched.mu.Unlock()
+
+	return p.pin.Close()
+}
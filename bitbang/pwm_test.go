@@ -0,0 +1,130 @@
+package bitbang
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// recorder records the time spent high by a simulated pin.
+type recorder struct {
+	mu    sync.Mutex
+	high  time.Duration
+	since time.Time
+	level int
+	edges int
+}
+
+func newRecorder(pin *sim.Pin) *recorder {
+	r := &recorder{since: time.Now()}
+	pin.OnChange(func(level int) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		now := time.Now()
+		if r.level == embd.High {
+			r.high += now.Sub(r.since)
+		}
+		r.since, r.level = now, level
+		r.edges++
+	})
+	return r
+}
+
+// measure returns the fraction of the time the pin spent high during d.
+func (r *recorder) measure(d time.Duration) (float64, int) {
+	r.mu.Lock()
+	r.high, r.edges, r.since = 0, 0, time.Now()
+	start := r.since
+	r.mu.Unlock()
+
+	time.Sleep(d)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	high := r.high
+	if r.level == embd.High {
+		high += now.Sub(r.since)
+	}
+	return float64(high) / float64(now.Sub(start)), r.edges
+}
+
+func TestPWMDuty(t *testing.T) {
+	pin := sim.NewPin(0)
+	r := newRecorder(pin)
+	p := NewScheduler(1).NewPWMPin(pin)
+	defer p.Close()
+
+	if err := p.SetPeriod(4000000); err != nil {
+		t.Fatalf("Setting period: got %v", err)
+	}
+	if err := p.SetDuty(1000000); err != nil {
+		t.Fatalf("Setting duty: got %v", err)
+	}
+	// Measure over many periods, so that an edge delayed by a few
+	// milliseconds, as happens on a loaded single core host, does not
+	// weigh much.
+	if duty, edges := r.measure(time.Second); duty < 0.2 || duty > 0.3 || edges < 250 {
+		t.Errorf("Duty 1ms of 4ms: pin high %.2f of the time over %v edges, want 0.25 over 500", duty, edges)
+	}
+	if s := p.Stats(); s.Edges == 0 || s.Max < s.Mean {
+		t.Errorf("Duty 1ms of 4ms: got inconsistent stats %+v", s)
+	}
+
+	if err := p.SetPolarity(embd.Negative); err != nil {
+		t.Fatalf("Setting polarity: got %v", err)
+	}
+	if duty, _ := r.measure(time.Second); duty < 0.7 || duty > 0.8 {
+		t.Errorf("Duty 1ms of 4ms, negative polarity: pin high %.2f of the time, want 0.75", duty)
+	}
+}
+
+func TestPWMFullAndOff(t *testing.T) {
+	pin := sim.NewPin(0)
+	r := newRecorder(pin)
+	p := NewScheduler(1).NewPWMPin(pin)
+	defer p.Close()
+
+	p.SetPeriod(1000000)
+	p.SetDuty(1000000)
+	// Changes take effect from the next period, which may start late.
+	time.Sleep(20 * time.Millisecond)
+	if duty, edges := r.measure(20 * time.Millisecond); duty != 1 || edges != 0 {
+		t.Errorf("Full duty: pin high %.2f of the time with %v edges, want 1 with 0", duty, edges)
+	}
+	p.SetDuty(0)
+	time.Sleep(20 * time.Millisecond)
+	if duty, edges := r.measure(20 * time.Millisecond); duty != 0 || edges != 0 {
+		t.Errorf("Zero duty: pin high %.2f of the time with %v edges, want 0 with 0", duty, edges)
+	}
+	if err := p.SetDuty(2000000); err == nil {
+		t.Error("Duty beyond period: got nil error")
+	}
+}
+
+func TestPWMMaxChannels(t *testing.T) {
+	s := NewScheduler(2)
+	var pins []*PWMPin
+	for i := 0; i < 3; i++ {
+		p := s.NewPWMPin(sim.NewPin(i))
+		defer p.Close()
+		pins = append(pins, p)
+	}
+	for _, p := range pins[:2] {
+		if err := p.SetDuty(1000000); err != nil {
+			t.Fatalf("Starting channel %v: got %v", p.N(), err)
+		}
+	}
+	if err := pins[2].SetDuty(1000000); err != ErrTooManyChannels {
+		t.Fatalf("Starting a channel too many: got %v, want %v", err, ErrTooManyChannels)
+	}
+	pins[0].Close()
+	if err := pins[2].SetDuty(1000000); err != nil {
+		t.Fatalf("Starting channel after closing another: got %v", err)
+	}
+}
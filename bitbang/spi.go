@@ -0,0 +1,379 @@
+// Software SPI support.
+
+package bitbang
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// SPIBus is a SPI master driving digital pins. It supports the four clock
+// modes, LSB first transfers, an active high chip select and words of 1 to
+// 32 bits. As with spidev, words wider than 8 bits occupy 2 (up to 16 bits)
+// or 4 bytes in the buffers, least significant byte first.
+type SPIBus struct {
+	sclk, mosi, miso, cs embd.DigitalPin
+
+	mode  embd.SPIMode
+	speed int
+	bpw   int
+	delay int
+
+	mu          sync.Mutex
+	half        time.Duration
+	selected    bool
+	err         error
+	initialized bool
+}
+
+// NewSPIBus returns a software SPI bus. mosi, miso and cs may be nil for
+// devices which only receive, only transmit or have their chip select tied
+// active. speed is the clock frequency in Hz (0 clocks as fast as the pins
+// allow), bpw the bits per word (0 means 8) and delay the time in µs to
+// wait after each transfer before deselecting the device.
+func NewSPIBus(sclk, mosi, miso, cs embd.DigitalPin, mode embd.SPIMode, speed, bpw, delay int) *SPIBus {
+	return &SPIBus{
+		sclk:  sclk,
+		mosi:  mosi,
+		miso:  miso,
+		cs:    cs,
+		mode:  mode,
+		speed: speed,
+		bpw:   bpw,
+		delay: delay,
+	}
+}
+
+func (b *SPIBus) setup() error {
+	if b.initialized {
+		return nil
+	}
+
+	if err := b.mode.Validate(); err != nil {
+		return err
+	}
+	if b.mode&(embd.SPI3Wire|embd.SPITxDual|embd.SPITxQuad|embd.SPIRxDual|embd.SPIRxQuad) != 0 {
+		return fmt.Errorf("bitbang: spi mode %#x is not supported", uint32(b.mode))
+	}
+	if b.bpw < 0 || b.bpw > 32 {
+		return fmt.Errorf("bitbang: spi bpw %v is not supported", b.bpw)
+	}
+
+	if err := b.sclk.SetDirection(embd.Out); err != nil {
+		return err
+	}
+	if err := b.sclk.Write(b.idleClock()); err != nil {
+		return err
+	}
+	if b.mosi != nil {
+		if err := b.mosi.SetDirection(embd.Out); err != nil {
+			return err
+		}
+	}
+	if b.miso != nil {
+		if err := b.miso.SetDirection(embd.In); err != nil {
+			return err
+		}
+	}
+	if b.cs != nil && b.mode&embd.SPINoCS == 0 {
+		if err := b.cs.SetDirection(embd.Out); err != nil {
+			return err
+		}
+		if err := b.cs.Write(b.csLevel(false)); err != nil {
+			return err
+		}
+	}
+
+	b.half = halfPeriod(b.speed)
+
+	glog.V(2).Infof("bitbang: spi bus initialized with mode %#x", uint32(b.mode))
+
+	b.initialized = true
+
+	return nil
+}
+
+func halfPeriod(speed int) time.Duration {
+	if speed <= 0 {
+		return 0
+	}
+	return time.Second / time.Duration(2*speed)
+}
+
+// Configure changes the mode, speed, bpw and delay used by the bus.
+func (b *SPIBus) Configure(mode embd.SPIMode, speed, bpw, delay int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mode, b.speed, b.bpw, b.delay = mode, speed, bpw, delay
+	b.initialized = false
+
+	return b.setup()
+}
+
+func (b *SPIBus) idleClock() int {
+	// SPIMode2 is CPOL alone.
+	if b.mode&embd.SPIMode2 != 0 {
+		return embd.High
+	}
+	return embd.Low
+}
+
+func (b *SPIBus) csLevel(selected bool) int {
+	if selected == (b.mode&embd.SPICSHigh != 0) {
+		return embd.High
+	}
+	return embd.Low
+}
+
+// set writes to a pin, remembering the first error so that the bit loops
+// stay readable. The error is collected by flush.
+func (b *SPIBus) set(pin embd.DigitalPin, v int) {
+	if pin == nil || b.err != nil {
+		return
+	}
+	b.err = pin.Write(v)
+}
+
+func (b *SPIBus) get(pin embd.DigitalPin) int {
+	if pin == nil || b.err != nil {
+		return 0
+	}
+	v, err := pin.Read()
+	b.err = err
+	return v
+}
+
+func (b *SPIBus) flush() error {
+	err := b.err
+	b.err = nil
+	return err
+}
+
+func (b *SPIBus) wait(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+func (b *SPIBus) selectDevice(selected bool) error {
+	if b.cs == nil || b.mode&embd.SPINoCS != 0 || b.selected == selected {
+		b.selected = selected
+		return nil
+	}
+	b.selected = selected
+	return b.cs.Write(b.csLevel(selected))
+}
+
+// word clocks out the n bit word out, returning the word clocked in.
+func (b *SPIBus) word(out uint32, n int, half time.Duration) uint32 {
+	idle := b.idleClock()
+	active := idle ^ embd.High
+	cpha := b.mode&embd.SPIMode1 != 0 // SPIMode1 is CPHA alone.
+	lsb := b.mode&embd.SPILSBFirst != 0
+
+	var in uint32
+	for i := 0; i < n; i++ {
+		shift := uint(n - 1 - i)
+		if lsb {
+			shift = uint(i)
+		}
+		bit := int(out>>shift) & 1
+
+		var v int
+		if cpha {
+			// Data changes on the leading edge and is sampled on the
+			// trailing edge.
+			b.set(b.sclk, active)
+			b.set(b.mosi, bit)
+			b.wait(half)
+			b.set(b.sclk, idle)
+			v = b.get(b.miso)
+			b.wait(half)
+		} else {
+			// Data is set up before the leading edge and sampled on it.
+			b.set(b.mosi, bit)
+			b.wait(half)
+			b.set(b.sclk, active)
+			v = b.get(b.miso)
+			b.wait(half)
+			b.set(b.sclk, idle)
+		}
+		in |= uint32(v&1) << shift
+	}
+	return in
+}
+
+func wordBytes(bpw int) int {
+	switch {
+	case bpw <= 8:
+		return 1
+	case bpw <= 16:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// transfer clocks tx out and rx in, either of which may be nil.
+func (b *SPIBus) transfer(tx, rx []byte, speed, bpw int) error {
+	if bpw == 0 {
+		bpw = 8
+	}
+	if bpw < 0 || bpw > 32 {
+		return fmt.Errorf("bitbang: spi bpw %v is not supported", bpw)
+	}
+	half := b.half
+	if speed > 0 {
+		half = halfPeriod(speed)
+	}
+
+	n := len(tx)
+	if n == 0 {
+		n = len(rx)
+	}
+	size := wordBytes(bpw)
+	if n%size != 0 {
+		return fmt.Errorf("bitbang: spi transfer of %v bytes is not a multiple of the %v bit word size", n, bpw)
+	}
+
+	for i := 0; i < n; i += size {
+		var out uint32
+		if tx != nil {
+			for j := size - 1; j >= 0; j-- {
+				out = out<<8 | uint32(tx[i+j])
+			}
+		}
+		in := b.word(out, bpw, half)
+		if rx != nil {
+			for j := 0; j < size; j++ {
+				rx[i+j] = byte(in >> uint(8*j))
+			}
+		}
+	}
+	return b.flush()
+}
+
+// Transaction carries out the segments under a single chip select
+// assertion, deselecting the device between segments with CSChange set.
+// Dual and quad segments are not supported.
+func (b *SPIBus) Transaction(segments ...embd.SPISegment) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.transaction(segments)
+}
+
+// transferSegment carries out a single segment using the delay of the bus.
+func (b *SPIBus) transferSegment(seg embd.SPISegment) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seg.Delay = time.Duration(b.delay) * time.Microsecond
+	return b.transaction([]embd.SPISegment{seg})
+}
+
+func (b *SPIBus) transaction(segments []embd.SPISegment) error {
+	if err := b.setup(); err != nil {
+		return err
+	}
+
+	if len(segments) == 0 {
+		return errors.New("bitbang: spi transaction has no segments")
+	}
+	for i := range segments {
+		seg := &segments[i]
+		if seg.Tx != nil && seg.Rx != nil && len(seg.Tx) != len(seg.Rx) {
+			return fmt.Errorf("bitbang: spi segment %v has tx and rx buffers of differing lengths", i)
+		}
+		if seg.TxNBits > 1 || seg.RxNBits > 1 {
+			return fmt.Errorf("bitbang: spi segment %v uses more than one data line", i)
+		}
+	}
+
+	for i := range segments {
+		seg := &segments[i]
+		last := i == len(segments)-1
+
+		if err := b.selectDevice(true); err != nil {
+			return err
+		}
+		bpw := seg.BPW
+		if bpw == 0 {
+			bpw = b.bpw
+		}
+		if err := b.transfer(seg.Tx, seg.Rx, seg.Speed, bpw); err != nil {
+			b.selectDevice(false)
+			return err
+		}
+		time.Sleep(seg.Delay)
+
+		// As with spidev, CSChange on the last segment leaves the device
+		// selected.
+		if seg.CSChange == last {
+			continue
+		}
+		if err := b.selectDevice(false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *SPIBus) TransferAndReceiveData(dataBuffer []uint8) error {
+	return b.transferSegment(embd.SPISegment{Tx: dataBuffer, Rx: dataBuffer})
+}
+
+func (b *SPIBus) ReceiveData(len int) ([]uint8, error) {
+	data := make([]uint8, len)
+	if err := b.TransferAndReceiveData(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *SPIBus) TransferAndReceiveByte(data byte) (byte, error) {
+	d := [1]uint8{data}
+	if err := b.TransferAndReceiveData(d[:]); err != nil {
+		return 0, err
+	}
+	return d[0], nil
+}
+
+func (b *SPIBus) ReceiveByte() (byte, error) {
+	return b.TransferAndReceiveByte(0)
+}
+
+func (b *SPIBus) Write(data []byte) (int, error) {
+	if err := b.transferSegment(embd.SPISegment{Tx: data}); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Mode returns the mode the bus is configured with.
+func (b *SPIBus) Mode() (embd.SPIMode, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.mode, nil
+}
+
+// Close deselects the device. The pins themselves belong to the caller and
+// are left open.
+func (b *SPIBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.initialized {
+		return nil
+	}
+	b.initialized = false
+
+	return b.selectDevice(false)
+}
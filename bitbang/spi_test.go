@@ -0,0 +1,200 @@
+package bitbang
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/convertors/mcp3008"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// spiSlave emulates a SPI device on simulated pins. It records the bits
+// received while selected and replies with the bits returned by reply,
+// which is given the index of the bit and the bits received so far.
+type spiSlave struct {
+	sclk, mosi, miso, cs *sim.Pin
+	mode                 embd.SPIMode
+	reply                func(i int, in []int) int
+
+	selected   bool
+	in         []int
+	out        int
+	selections int
+}
+
+func newSPISlave(mode embd.SPIMode) *spiSlave {
+	s := &spiSlave{
+		sclk: sim.NewPin(0),
+		mosi: sim.NewPin(1),
+		miso: sim.NewPin(2),
+		cs:   sim.NewPin(3),
+		mode: mode,
+	}
+	s.sclk.OnChange(s.onSCLK)
+	s.cs.OnChange(s.onCS)
+	return s
+}
+
+func (s *spiSlave) bus(speed, bpw int) *SPIBus {
+	return NewSPIBus(s.sclk, s.mosi, s.miso, s.cs, s.mode, speed, bpw, 0)
+}
+
+func (s *spiSlave) shift() {
+	bit := 0
+	if s.reply != nil {
+		bit = s.reply(s.out, s.in)
+	}
+	s.miso.Drive(bit)
+	s.out++
+}
+
+func (s *spiSlave) onCS(level int) {
+	s.selected = (level == embd.High) == (s.mode&embd.SPICSHigh != 0)
+	if !s.selected {
+		s.miso.Release()
+		return
+	}
+	s.selections++
+	s.in, s.out = nil, 0
+	if s.mode&embd.SPIMode1 == 0 {
+		s.shift()
+	}
+}
+
+func (s *spiSlave) onSCLK(level int) {
+	if !s.selected {
+		return
+	}
+	idle := embd.Low
+	if s.mode&embd.SPIMode2 != 0 {
+		idle = embd.High
+	}
+	leading := level != idle
+	if leading == (s.mode&embd.SPIMode1 == 0) {
+		s.in = append(s.in, s.mosi.Level())
+	} else {
+		s.shift()
+	}
+}
+
+// wireBits returns the bits of the n bit words in data, in the order they
+// appear on the wire.
+func wireBits(data []byte, n int, lsb bool) []int {
+	size := wordBytes(n)
+	var bits []int
+	for i := 0; i < len(data); i += size {
+		var w uint32
+		for j := size - 1; j >= 0; j-- {
+			w = w<<8 | uint32(data[i+j])
+		}
+		for k := 0; k < n; k++ {
+			shift := uint(n - 1 - k)
+			if lsb {
+				shift = uint(k)
+			}
+			bits = append(bits, int(w>>shift)&1)
+		}
+	}
+	return bits
+}
+
+func TestSPIModes(t *testing.T) {
+	tx := []byte{0xa5, 0x3c}
+	resp := []byte{0x5a, 0xf0}
+	for _, mode := range []embd.SPIMode{embd.SPIMode0, embd.SPIMode1, embd.SPIMode2, embd.SPIMode3} {
+		for _, flags := range []embd.SPIMode{0, embd.SPILSBFirst, embd.SPICSHigh} {
+			m := mode | flags
+			lsb := m&embd.SPILSBFirst != 0
+			s := newSPISlave(m)
+			bits := wireBits(resp, 8, lsb)
+			s.reply = func(i int, in []int) int {
+				if i < len(bits) {
+					return bits[i]
+				}
+				return 0
+			}
+			buf := append([]byte{}, tx...)
+			if err := s.bus(0, 8).TransferAndReceiveData(buf); err != nil {
+				t.Fatalf("Transfer in mode %#x: got %v", uint32(m), err)
+			}
+			if want := wireBits(tx, 8, lsb); !reflect.DeepEqual(s.in, want) {
+				t.Errorf("Transfer in mode %#x: slave received %v, want %v", uint32(m), s.in, want)
+			}
+			if !bytes.Equal(buf, resp) {
+				t.Errorf("Transfer in mode %#x: got %#v, want %#v", uint32(m), buf, resp)
+			}
+			if s.selected {
+				t.Errorf("Transfer in mode %#x: device left selected", uint32(m))
+			}
+		}
+	}
+}
+
+func TestSPIWordSize(t *testing.T) {
+	s := newSPISlave(embd.SPIMode0)
+	bits := wireBits([]byte{0xc3, 0x05}, 12, false)
+	s.reply = func(i int, in []int) int {
+		if i < len(bits) {
+			return bits[i]
+		}
+		return 0
+	}
+	buf := []byte{0x34, 0x0a}
+	if err := s.bus(0, 12).TransferAndReceiveData(buf); err != nil {
+		t.Fatalf("Transfer of 12 bit word: got %v", err)
+	}
+	if want := wireBits([]byte{0x34, 0x0a}, 12, false); !reflect.DeepEqual(s.in, want) {
+		t.Errorf("Transfer of 12 bit word: slave received %v, want %v", s.in, want)
+	}
+	if buf[0] != 0xc3 || buf[1] != 0x05 {
+		t.Errorf("Transfer of 12 bit word: got %#v, want %#v", buf, []byte{0xc3, 0x05})
+	}
+	if err := s.bus(0, 12).TransferAndReceiveData(make([]byte, 3)); err == nil {
+		t.Error("Transfer of 3 bytes with 12 bit words: got nil error")
+	}
+}
+
+func TestSPITransaction(t *testing.T) {
+	s := newSPISlave(embd.SPIMode0)
+	b := s.bus(0, 8)
+	err := b.Transaction(
+		embd.SPISegment{Tx: []byte{0x01}},
+		embd.SPISegment{Tx: []byte{0x02}, CSChange: true},
+		embd.SPISegment{Rx: make([]byte, 1)},
+	)
+	if err != nil {
+		t.Fatalf("Transaction: got %v", err)
+	}
+	if s.selections != 2 {
+		t.Fatalf("Transaction with CSChange on the middle segment: device selected %v times, want 2", s.selections)
+	}
+	if err := b.Transaction(embd.SPISegment{Tx: []byte{0}, TxNBits: 2}); err == nil {
+		t.Fatal("Dual transfer: got nil error")
+	}
+}
+
+func TestSPIMCP3008(t *testing.T) {
+	s := newSPISlave(embd.SPIMode0)
+	values := [8]int{0x000, 0x155, 0x2aa, 0x3ff, 0x123, 0x2ab, 0x0f0, 0x30f}
+	// The MCP3008 samples the channel selected by bits 9-11, then outputs a
+	// null bit followed by the 10 bit result.
+	s.reply = func(i int, in []int) int {
+		if i < 14 || i > 23 {
+			return 0
+		}
+		ch := in[9]<<2 | in[10]<<1 | in[11]
+		return values[ch] >> uint(23-i) & 1
+	}
+	adc := mcp3008.New(mcp3008.SingleMode, s.bus(1000000, 8))
+	for ch, want := range values {
+		got, err := adc.AnalogValueAt(ch)
+		if err != nil {
+			t.Fatalf("Reading channel %v: got %v", ch, err)
+		}
+		if got != want {
+			t.Errorf("Reading channel %v: got %#x, want %#x", ch, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Board support.
+
+package embd
+
+import "sync"
+
+// Board represents a single host and owns the drivers created from its
+// Descriptor. Multiple boards can coexist in the same process, allowing,
+// for example, the on-board GPIO and a simulated or remote host to be
+// driven side by side.
+//
+// The package level functions (InitGPIO, NewDigitalPin, NewI2CBus, etc.)
+// operate on a default board which describes the detected host.
+type Board struct {
+	describe func() (*Descriptor, error)
+
+	mu sync.Mutex
+
+	gpioDriver GPIODriver
+	gpioMounts map[string]GPIODriver
+	i2cDriver  I2CDriver
+	spiDriver  SPIDriver
+	ledDriver  LEDDriver
+}
+
+// NewBoard returns a Board whose drivers are created from desc.
+func NewBoard(desc *Descriptor) *Board {
+	return &Board{
+		describe: func() (*Descriptor, error) {
+			return desc, nil
+		},
+	}
+}
+
+// defaultBoard backs the package level functions. Its descriptor is
+// resolved lazily so that SetHost can be called before first use.
+var defaultBoard = &Board{describe: DescribeHost}
+
+// DefaultBoard returns the board used by the package level functions.
+func DefaultBoard() *Board {
+	return defaultBoard
+}
+
+// descriptor returns the descriptor of the board. b.mu must be held.
+func (b *Board) descriptor() (*Descriptor, error) {
+	desc, err := b.describe()
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil {
+		return nil, ErrFeatureNotSupported
+	}
+	return desc, nil
+}
+
+// Close releases the resources associated with all the initialized
+// drivers of the board.
+func (b *Board) Close() error {
+	var firstErr error
+	for _, close := range []func() error{b.CloseGPIO, b.CloseI2C, b.CloseSPI, b.CloseLED} {
+		if err := close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,105 @@
+package embd
+
+import "testing"
+
+func newFakeDescriptor() *Descriptor {
+	pinMap := PinMap{
+		&PinDesc{ID: "P1_1", Aliases: []string{"1"}, Caps: CapDigital, DigitalLogical: 1},
+	}
+	return &Descriptor{
+		GPIODriver: func() GPIODriver {
+			return NewGPIODriver(pinMap, newFakeDigitalPin, nil, nil)
+		},
+	}
+}
+
+func TestBoardDriversAreIndependent(t *testing.T) {
+	b1 := NewBoard(newFakeDescriptor())
+	b2 := NewBoard(newFakeDescriptor())
+	pin1, err := b1.NewDigitalPin(1)
+	if err != nil {
+		t.Fatalf("Looking up digital pin 1 on board 1: got %v", err)
+	}
+	pin2, err := b2.NewDigitalPin(1)
+	if err != nil {
+		t.Fatalf("Looking up digital pin 1 on board 2: got %v", err)
+	}
+	if pin1 == pin2 {
+		t.Fatal("Looking up digital pin 1 on two boards: got the same instance")
+	}
+}
+
+func TestBoardCloseGPIO(t *testing.T) {
+	b := NewBoard(newFakeDescriptor())
+	drv, err := b.GPIODriver()
+	if err != nil {
+		t.Fatalf("Initializing gpio driver: got %v", err)
+	}
+	if err := b.CloseGPIO(); err != nil {
+		t.Fatalf("Closing gpio driver: got %v", err)
+	}
+	drv2, err := b.GPIODriver()
+	if err != nil {
+		t.Fatalf("Initializing gpio driver after close: got %v", err)
+	}
+	if drv == drv2 {
+		t.Fatal("Initializing gpio driver after close: got the old instance")
+	}
+}
+
+func TestBoardUnsupportedFeature(t *testing.T) {
+	b := NewBoard(newFakeDescriptor())
+	if err := b.InitI2C(); err != ErrFeatureNotSupported {
+		t.Fatalf("Initializing i2c driver: got %v, want %v", err, ErrFeatureNotSupported)
+	}
+	if _, err := b.NewLED(0); err != ErrFeatureNotSupported {
+		t.Fatalf("Looking up led 0: got %v, want %v", err, ErrFeatureNotSupported)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Closing board without initialized drivers: got %v", err)
+	}
+}
+
+func TestBoardMountGPIO(t *testing.T) {
+	b := NewBoard(newFakeDescriptor())
+	expMap := PinMap{
+		&PinDesc{ID: "GPA3", Aliases: []string{"3"}, Caps: CapDigital, DigitalLogical: 3},
+	}
+	exp := NewGPIODriver(expMap, newFakeDigitalPin, nil, nil)
+	if err := b.MountGPIO("EXP0", exp); err != nil {
+		t.Fatalf("Mounting expander: got %v", err)
+	}
+	if err := b.MountGPIO("EXP0", exp); err == nil {
+		t.Error("Mounting expander twice: got nil error")
+	}
+	if err := b.MountGPIO("EXP_1", exp); err == nil {
+		t.Error("Mounting expander under EXP_1: got nil error")
+	}
+	if err := b.MountGPIO("P1", exp); err == nil {
+		t.Error("Mounting expander under P1, shadowing host pin P1_1: got nil error")
+	}
+
+	for _, key := range []string{"EXP0_GPA3", "EXP0_3"} {
+		pin, err := b.NewDigitalPin(key)
+		if err != nil {
+			t.Fatalf("Looking up %v: got %v", key, err)
+		}
+		if pin.N() != 3 {
+			t.Errorf("Looking up %v: got pin %v, want 3", key, pin.N())
+		}
+	}
+	pin, err := b.NewDigitalPin(1)
+	if err != nil {
+		t.Fatalf("Looking up host pin 1 with an expander mounted: got %v", err)
+	}
+	if pin.N() != 1 {
+		t.Errorf("Looking up host pin 1 with an expander mounted: got pin %v, want 1", pin.N())
+	}
+
+	if err := b.UnmountGPIO("EXP0"); err != nil {
+		t.Fatalf("Unmounting expander: got %v", err)
+	}
+	if _, err := b.NewDigitalPin("EXP0_GPA3"); err == nil {
+		t.Error("Looking up EXP0_GPA3 after unmounting: got nil error")
+	}
+}
@@ -0,0 +1,177 @@
+// Relay autotuning.
+
+package control
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// DefaultCycles is the number of oscillations a relay measures when Cycles
+// is not set.
+const DefaultCycles = 4
+
+// ErrNoOscillation is returned for the tuning of a relay which did not
+// make the process oscillate.
+var ErrNoOscillation = errors.New("control: process did not oscillate")
+
+// Relay is a controller switching its output between Output+Amplitude and
+// Output-Amplitude as its input crosses the setpoint, making the process
+// oscillate. The amplitude and period of the oscillation give its ultimate
+// gain and period, from which PID gains are derived.
+type Relay struct {
+	Setpoint float64
+
+	// Output is the center of the output of the relay and Amplitude the
+	// deviation from it, in the direction which raises the input.
+	Output, Amplitude float64
+
+	// Hysteresis is the band around the setpoint the input must leave for
+	// the relay to switch, rejecting noise.
+	Hysteresis float64
+
+	// Cycles is the number of oscillations measured. The first
+	// oscillation is not measured as the process settles.
+	Cycles int
+
+	mu      sync.Mutex
+	started bool
+	high    bool
+	elapsed time.Duration
+	last    time.Duration // Time of the last upward switch.
+	rises   int
+	min     float64
+	max     float64
+	periods []time.Duration
+	amps    []float64
+}
+
+// NewRelay creates a new relay oscillating around setpoint with its output
+// at output±amplitude.
+func NewRelay(setpoint, output, amplitude float64) *Relay {
+	return &Relay{Setpoint: setpoint, Output: output, Amplitude: amplitude}
+}
+
+func (r *Relay) cycles() int {
+	if r.Cycles > 0 {
+		return r.Cycles
+	}
+	return DefaultCycles
+}
+
+// Update returns the output for input, read dt after the previous update.
+func (r *Relay) Update(input float64, dt time.Duration) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.elapsed += dt
+	if !r.started {
+		r.started = true
+		r.high = input < r.Setpoint
+		r.min, r.max = input, input
+	}
+	r.min, r.max = math.Min(r.min, input), math.Max(r.max, input)
+
+	switch {
+	case r.high && input > r.Setpoint+r.Hysteresis:
+		r.high = false
+	case !r.high && input < r.Setpoint-r.Hysteresis:
+		r.high = true
+		if r.rises > 1 {
+			r.periods = append(r.periods, r.elapsed-r.last)
+			r.amps = append(r.amps, (r.max-r.min)/2)
+			glog.V(2).Infof("control: relay oscillation of %v over %v", r.amps[len(r.amps)-1], r.periods[len(r.periods)-1])
+		}
+		r.rises++
+		r.last = r.elapsed
+		r.min, r.max = input, input
+	}
+
+	if r.high {
+		return r.Output + r.Amplitude
+	}
+	return r.Output - r.Amplitude
+}
+
+// Done reports whether the relay has measured Cycles oscillations.
+func (r *Relay) Done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.periods) >= r.cycles()
+}
+
+// Tuning returns the ultimate gain and period of the process measured so
+// far.
+func (r *Relay) Tuning() (Tuning, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.periods) == 0 {
+		return Tuning{}, ErrNoOscillation
+	}
+	var period time.Duration
+	var amp float64
+	for i := range r.periods {
+		period += r.periods[i]
+		amp += r.amps[i]
+	}
+	period /= time.Duration(len(r.periods))
+	amp /= float64(len(r.amps))
+	if amp <= r.Hysteresis {
+		return Tuning{}, ErrNoOscillation
+	}
+
+	ku := 4 * math.Abs(r.Amplitude) / (math.Pi * math.Sqrt(amp*amp-r.Hysteresis*r.Hysteresis))
+	return Tuning{Ku: ku, Pu: period}, nil
+}
+
+// Tuning is the ultimate gain and period of a process: the proportional
+// gain at which it oscillates and the period of the oscillation.
+type Tuning struct {
+	Ku float64
+	Pu time.Duration
+}
+
+// PI returns Ziegler-Nichols gains for a PI controller.
+func (t Tuning) PI() (kp, ki float64) {
+	kp = 0.45 * t.Ku
+	ki = kp / (t.Pu.Seconds() / 1.2)
+	return kp, ki
+}
+
+// PID returns Ziegler-Nichols gains for a PID controller.
+func (t Tuning) PID() (kp, ki, kd float64) {
+	kp = 0.6 * t.Ku
+	ki = kp / (t.Pu.Seconds() / 2)
+	kd = kp * t.Pu.Seconds() / 8
+	return kp, ki, kd
+}
+
+// NoOvershoot returns gains for a PID controller which trades speed for
+// little or no overshoot.
+func (t Tuning) NoOvershoot() (kp, ki, kd float64) {
+	kp = 0.2 * t.Ku
+	ki = kp / (t.Pu.Seconds() / 2)
+	kd = kp * t.Pu.Seconds() / 3
+	return kp, ki, kd
+}
+
+// Autotune runs a loop with r as its controller until r has measured its
+// oscillations, and returns the tuning of the process.
+func Autotune(ctx context.Context, l *Loop, r *Relay) (Tuning, error) {
+	run := *l
+	run.Controller = r
+
+	glog.V(1).Infof("control: autotuning around %v", r.Setpoint)
+
+	if err := run.run(ctx, r.Done); err != nil {
+		return Tuning{}, err
+	}
+	return r.Tuning()
+}
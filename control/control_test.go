@@ -0,0 +1,200 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// plant simulates a first order process with dead time, such as a heater:
+// its value approaches gain times the input with time constant tau, the
+// input taking effect after delay.
+type plant struct {
+	gain  float64
+	tau   time.Duration
+	delay []float64
+	value float64
+}
+
+func newPlant(gain float64, tau, delay, dt time.Duration) *plant {
+	return &plant{gain: gain, tau: tau, delay: make([]float64, int(delay/dt))}
+}
+
+func (p *plant) step(input float64, dt time.Duration) float64 {
+	if len(p.delay) > 0 {
+		p.delay = append(p.delay, input)
+		input, p.delay = p.delay[0], p.delay[1:]
+	}
+	p.value += (p.gain*input - p.value) * dt.Seconds() / p.tau.Seconds()
+	return p.value
+}
+
+const dt = 10 * time.Millisecond
+
+// simulate runs c against p for d, returning the inputs.
+func simulate(c Controller, p *plant, d time.Duration) []float64 {
+	var inputs []float64
+	for t := time.Duration(0); t < d; t += dt {
+		inputs = append(inputs, p.value)
+		p.step(c.Update(p.value, dt), dt)
+	}
+	return inputs
+}
+
+func TestPID(t *testing.T) {
+	p := newPlant(2, time.Second, 100*time.Millisecond, dt)
+	c := NewPID(0.5, 0.5, 0)
+	c.Min, c.Max = 0, 10
+	c.SetSetpoint(5)
+
+	inputs := simulate(c, p, 20*time.Second)
+	if v := inputs[len(inputs)-1]; math.Abs(v-5) > 0.01 {
+		t.Errorf("PID: settled at %v, want 5", v)
+	}
+}
+
+func TestPIDClamp(t *testing.T) {
+	c := NewPID(10, 1, 0)
+	c.Min, c.Max = -1, 1
+	c.SetSetpoint(100)
+	for i := 0; i < 1000; i++ {
+		if out := c.Update(0, dt); out != 1 {
+			t.Fatalf("Saturated output: got %v, want 1", out)
+		}
+	}
+
+	// Without anti-windup, the integral accumulated while saturated would
+	// hold the output high.
+	c.SetSetpoint(0)
+	if out := c.Update(0.5, dt); out >= 0 {
+		t.Errorf("Output after saturation: got %v, want negative", out)
+	}
+}
+
+func TestPIDDerivative(t *testing.T) {
+	c := NewPID(0, 0, 1)
+	c.Update(0, dt)
+
+	// The derivative term works on the input, not the error: a change of
+	// setpoint does not kick the output.
+	c.SetSetpoint(10)
+	if out := c.Update(0, dt); out != 0 {
+		t.Errorf("Setpoint change: got %v, want 0", out)
+	}
+	if out := c.Update(0.1, dt); math.Abs(out+10) > 1e-9 {
+		t.Errorf("Unfiltered derivative: got %v, want -10", out)
+	}
+
+	c.Reset()
+	c.Filter = 90 * time.Millisecond
+	c.Update(0, dt)
+	if out := c.Update(0.1, dt); math.Abs(out+1) > 1e-9 {
+		t.Errorf("Filtered derivative: got %v, want -1", out)
+	}
+}
+
+func TestPIDBumpless(t *testing.T) {
+	p := newPlant(2, time.Second, 0, dt)
+	c := NewPID(1, 1, 0.1)
+	c.SetSetpoint(5)
+
+	c.SetManual(1)
+	simulate(c, p, 5*time.Second)
+	if out := c.Output(); out != 1 {
+		t.Errorf("Manual output: got %v, want 1", out)
+	}
+
+	c.SetAuto()
+	if out := c.Update(p.value, dt); math.Abs(out-1) > 1e-9 {
+		t.Errorf("First automatic output: got %v, want 1", out)
+	}
+	if out := c.Update(p.value, dt); math.Abs(out-1) > 0.1 {
+		t.Errorf("Second automatic output: got %v, want close to 1", out)
+	}
+}
+
+func TestAutotune(t *testing.T) {
+	p := newPlant(2, time.Second, 200*time.Millisecond, dt)
+	r := NewRelay(5, 2.5, 1)
+	r.Hysteresis = 0.05
+
+	for i := 0; !r.Done(); i++ {
+		if i > 10000 {
+			t.Fatal("Relay: did not finish")
+		}
+		simulate(r, p, dt)
+	}
+	tuning, err := r.Tuning()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// For this plant, the phase lag reaches 180° at about 1.35Hz with a
+	// gain of about 0.23. The relay method, as a first harmonic
+	// approximation, underestimates the ultimate gain of such a plant.
+	if tuning.Pu < 600*time.Millisecond || tuning.Pu > 900*time.Millisecond {
+		t.Errorf("Pu: got %v, want about 740ms", tuning.Pu)
+	}
+	if tuning.Ku < 2.5 || tuning.Ku > 5 {
+		t.Errorf("Ku: got %v, want about 4.3", tuning.Ku)
+	}
+
+	kp, ki, kd := tuning.PID()
+	c := NewPID(kp, ki, kd)
+	c.Min, c.Max = 0, 10
+	c.SetSetpoint(6)
+	inputs := simulate(c, p, 20*time.Second)
+	if v := inputs[len(inputs)-1]; math.Abs(v-6) > 0.01 {
+		t.Errorf("Tuned PID: settled at %v, want 6", v)
+	}
+
+	if _, err := NewRelay(5, 0, 1).Tuning(); err != ErrNoOscillation {
+		t.Errorf("Tuning without oscillation: got %v, want %v", err, ErrNoOscillation)
+	}
+}
+
+func TestLoop(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		p       = newPlant(2, 100*time.Millisecond, 0, time.Millisecond)
+		last    = time.Now()
+		outputs int
+	)
+	read := func() (float64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return p.value, nil
+	}
+	write := func(out float64) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		p.step(out, now.Sub(last))
+		last = now
+		outputs++
+		return nil
+	}
+
+	c := NewPID(1, 5, 0)
+	c.SetSetpoint(1)
+	l := NewLoop(c, read, write, 5*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := l.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Run: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if outputs < 10 || outputs > 21 {
+		t.Errorf("Run: got %v updates in 100ms every 5ms", outputs)
+	}
+
+	errRead := errors.New("read failed")
+	l.Read = func() (float64, error) { return 0, errRead }
+	if err := l.Run(context.Background()); err != errRead {
+		t.Errorf("Run with failing read: got %v, want %v", err, errRead)
+	}
+}
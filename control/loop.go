@@ -0,0 +1,101 @@
+// Control loop runner.
+
+package control
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// DefaultInterval is the interval between the updates of a loop when
+// Interval is not set.
+const DefaultInterval = 100 * time.Millisecond
+
+// Loop reads a sensor, updates a controller and writes its output to an
+// actuator at a fixed rate.
+type Loop struct {
+	Controller Controller
+
+	// Read returns the input of the controller, e.g. the temperature of a
+	// thermometer.
+	Read func() (float64, error)
+
+	// Write applies the output of the controller, e.g. the duty of a
+	// heater.
+	Write func(output float64) error
+
+	// Interval is the interval between updates.
+	Interval time.Duration
+
+	// Clock returns the current time; time.Now is used if nil.
+	Clock func() time.Time
+}
+
+// NewLoop creates a new loop updating c every interval.
+func NewLoop(c Controller, read func() (float64, error), write func(float64) error, interval time.Duration) *Loop {
+	return &Loop{Controller: c, Read: read, Write: write, Interval: interval}
+}
+
+func (l *Loop) now() time.Time {
+	if l.Clock != nil {
+		return l.Clock()
+	}
+	return time.Now()
+}
+
+// Run updates the controller until ctx is done, returning ctx.Err(), or
+// until reading or writing fails.
+func (l *Loop) Run(ctx context.Context) error {
+	return l.run(ctx, nil)
+}
+
+// run updates the controller until ctx is done, an error occurs or done
+// returns true.
+func (l *Loop) run(ctx context.Context, done func() bool) error {
+	interval := l.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	if interval < 0 {
+		return fmt.Errorf("control: invalid interval %v", interval)
+	}
+
+	glog.V(1).Infof("control: running loop every %v", interval)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	var last time.Time
+	for {
+		input, err := l.Read()
+		if err != nil {
+			return err
+		}
+		now := l.now()
+		var dt time.Duration
+		if !last.IsZero() {
+			dt = now.Sub(last)
+		}
+		last = now
+
+		output := l.Controller.Update(input, dt)
+		if err := l.Write(output); err != nil {
+			return err
+		}
+
+		glog.V(3).Infof("control: input %v, output %v", input, output)
+
+		if done != nil && done() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package control closes feedback loops, such as the speed of a motor read
+// from an encoder or the temperature of a heater read from a thermometer.
+//
+// A PID computes the output of an actuator from the readings of a sensor.
+// It stops integrating while its output is clamped (anti-windup), filters
+// its derivative term, which is computed on the reading rather than the
+// error so that changes of the setpoint do not kick the output, and
+// switches from manual to automatic control without bumping the output.
+//
+// A Loop runs a Controller at a fixed rate, reading the sensor and writing
+// the actuator. Autotune finds the gains of a PID by the relay method,
+// making the process oscillate around its setpoint.
+//
+// Controllers are updated with the time elapsed since their last update,
+// so they can be tested against a simulated plant without waiting for it.
+package control
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Controller computes the output of a control loop from its input.
+type Controller interface {
+	// Update returns the output for input, read dt after the previous
+	// update.
+	Update(input float64, dt time.Duration) float64
+}
+
+// PID is a proportional-integral-derivative controller. The zero value
+// outputs 0; set at least Kp.
+type PID struct {
+	// Kp, Ki and Kd are the proportional, integral (per second) and
+	// derivative (in seconds) gains.
+	Kp, Ki, Kd float64
+
+	// Min and Max clamp the output. The output is unbounded if both are 0.
+	Min, Max float64
+
+	// Filter is the time constant of the low-pass filter applied to the
+	// derivative term. The derivative term is not filtered if 0.
+	Filter time.Duration
+
+	mu       sync.Mutex
+	setpoint float64
+	integral float64
+	deriv    float64
+	input    float64
+	output   float64
+	primed   bool // input holds a previous reading.
+	manual   bool
+	transfer bool // The next update resumes automatic control.
+}
+
+// NewPID creates a new PID controller with the gains kp, ki and kd.
+func NewPID(kp, ki, kd float64) *PID {
+	return &PID{Kp: kp, Ki: ki, Kd: kd}
+}
+
+// Setpoint returns the setpoint of the controller.
+func (c *PID) Setpoint() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.setpoint
+}
+
+// SetSetpoint sets the value the controller drives its input to.
+func (c *PID) SetSetpoint(sp float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setpoint = sp
+}
+
+// Output returns the last output of the controller.
+func (c *PID) Output() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.output
+}
+
+// SetManual suspends automatic control: Update returns output until
+// SetAuto is called.
+func (c *PID) SetManual(output float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.manual = true
+	c.output = c.clamp(output)
+}
+
+// SetAuto resumes automatic control. The first update after SetAuto
+// returns the last output, continuing from it from then on.
+func (c *PID) SetAuto() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.manual {
+		c.manual = false
+		c.transfer = true
+	}
+}
+
+// Reset clears the state of the controller: its integral and derivative
+// terms and last input and output.
+func (c *PID) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.integral, c.deriv, c.input, c.output = 0, 0, 0, 0
+	c.primed, c.transfer = false, false
+}
+
+func (c *PID) clamp(v float64) float64 {
+	if c.Min == 0 && c.Max == 0 {
+		return v
+	}
+	return math.Max(c.Min, math.Min(c.Max, v))
+}
+
+// Update returns the output for input, read dt after the previous update.
+func (c *PID) Update(input float64, dt time.Duration) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.manual {
+		c.input, c.primed = input, true
+		return c.output
+	}
+
+	e := c.setpoint - input
+	p := c.Kp * e
+
+	if c.transfer || !c.primed || dt <= 0 {
+		c.deriv = 0
+	} else {
+		d := -c.Kd * (input - c.input) / dt.Seconds()
+		if c.Filter > 0 {
+			d = c.deriv + dt.Seconds()/(c.Filter.Seconds()+dt.Seconds())*(d-c.deriv)
+		}
+		c.deriv = d
+	}
+	c.input, c.primed = input, true
+
+	if c.transfer {
+		// Bumpless transfer: make the integral term absorb the difference
+		// between the manual output and the other terms.
+		c.integral = c.output - p - c.deriv
+		c.transfer = false
+	} else if dt > 0 {
+		// Anti-windup: do not integrate further into saturation.
+		i := c.integral + c.Ki*e*dt.Seconds()
+		out := p + i + c.deriv
+		if sat := c.clamp(out); sat == out || (out > sat) != (e > 0) {
+			c.integral = i
+		}
+	}
+
+	c.output = c.clamp(p + c.integral + c.deriv)
+	return c.output
+}
@@ -434,6 +434,8 @@ type GPIOConnection struct {
 	D4, D5, D6, D7 embd.DigitalPin
 	Backlight      embd.DigitalPin
 	BLPolarity     BacklightPolarity
+
+	port *embd.DigitalPort
 }
 
 // NewGPIOConnection returns a new Connection based on a 4-bit GPIO bus.
@@ -478,23 +480,24 @@ func (conn *GPIOConnection) backlightSignal(state bool) int {
 }
 
 // Write writes a register select flag and byte to the 4-bit GPIO connection.
+// RS and the data lines are written together where the pins support it.
 func (conn *GPIOConnection) Write(rs bool, data byte) error {
 	glog.V(3).Infof("hd44780: writing to GPIO RS: %t, data: %#x", rs, data)
-	rsInt := embd.Low
+	if conn.port == nil {
+		port, err := embd.NewDigitalPort(conn.RS, conn.D4, conn.D5, conn.D6, conn.D7)
+		if err != nil {
+			return err
+		}
+		conn.port = port
+	}
+	var rsBit uint64
 	if rs {
-		rsInt = embd.High
+		rsBit = 1
 	}
 	functions := []func() error{
-		func() error { return conn.RS.Write(rsInt) },
-		func() error { return conn.D4.Write(int((data >> 4) & 0x01)) },
-		func() error { return conn.D5.Write(int((data >> 5) & 0x01)) },
-		func() error { return conn.D6.Write(int((data >> 6) & 0x01)) },
-		func() error { return conn.D7.Write(int((data >> 7) & 0x01)) },
+		func() error { return conn.port.Write(0x1f, rsBit|uint64(data>>4)<<1) },
 		func() error { return conn.pulseEnable() },
-		func() error { return conn.D4.Write(int(data & 0x01)) },
-		func() error { return conn.D5.Write(int((data >> 1) & 0x01)) },
-		func() error { return conn.D6.Write(int((data >> 2) & 0x01)) },
-		func() error { return conn.D7.Write(int((data >> 3) & 0x01)) },
+		func() error { return conn.port.Write(0x1e, uint64(data&0x0f)<<1) },
 		func() error { return conn.pulseEnable() },
 	}
 	for _, f := range functions {
@@ -0,0 +1,446 @@
+// Package mcp23x17 allows interfacing with the MCP23017 (I2C) and MCP23S17
+// (SPI) 16-bit I/O expanders.
+//
+// The pins of the expander implement embd.DigitalPin, and its GPIO driver
+// can be mounted on the board so that they are looked up like host pins:
+//
+//	exp := mcp23x17.New(bus, 0x20)
+//	embd.MountGPIO("EXP0", exp.GPIODriver())
+//	pin, err := embd.NewDigitalPin("EXP0_GPA3")
+//
+// Interrupts are supported when the INTA or INTB output of the expander is
+// connected to an interrupt capable host pin.
+package mcp23x17
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	// Register addresses with IOCON.BANK = 0, where the registers of port
+	// B follow those of port A.
+	iodirReg   = 0x00
+	gpintenReg = 0x04
+	intconReg  = 0x08
+	ioconReg   = 0x0A
+	gppuReg    = 0x0C
+	gpioReg    = 0x12
+	olatReg    = 0x14
+
+	ioconMirror = 0x40 // INTA and INTB are or'ed.
+	ioconHAEN   = 0x08 // Hardware addresses of the MCP23S17 are enabled.
+
+	spiWrite = 0x40
+	spiRead  = 0x41
+
+	pins = 16
+)
+
+// registers gives access to the registers of the expander.
+type registers interface {
+	read(reg byte, value []byte) error
+	write(reg byte, value []byte) error
+}
+
+type i2cRegisters struct {
+	bus  embd.I2CBus
+	addr byte
+}
+
+func (r *i2cRegisters) read(reg byte, value []byte) error {
+	return r.bus.ReadFromReg(r.addr, reg, value)
+}
+
+func (r *i2cRegisters) write(reg byte, value []byte) error {
+	return r.bus.WriteToReg(r.addr, reg, value)
+}
+
+type spiRegisters struct {
+	bus  embd.SPIBus
+	addr byte
+}
+
+func (r *spiRegisters) read(reg byte, value []byte) error {
+	buf := make([]byte, 2+len(value))
+	buf[0] = spiRead | r.addr<<1
+	buf[1] = reg
+	if err := r.bus.TransferAndReceiveData(buf); err != nil {
+		return err
+	}
+	copy(value, buf[2:])
+	return nil
+}
+
+func (r *spiRegisters) write(reg byte, value []byte) error {
+	buf := append([]byte{spiWrite | r.addr<<1, reg}, value...)
+	return r.bus.TransferAndReceiveData(buf)
+}
+
+type watch struct {
+	pin     *digitalPin
+	edge    embd.Edge
+	handler func(embd.DigitalPin)
+}
+
+// MCP23x17 represents a MCP23017 or MCP23S17 I/O expander.
+type MCP23x17 struct {
+	// Interrupt is the host pin connected to the INTA or INTB output of
+	// the expander, or nil if neither is connected. Both outputs are
+	// mirrored.
+	Interrupt embd.DigitalPin
+
+	regs registers
+
+	mu          sync.Mutex
+	initialized bool
+
+	iodir, gppu, olat, gpinten uint16
+	last                       uint16 // Last value read from GPIO.
+
+	watches  [pins]*watch
+	watching bool
+
+	drv embd.GPIODriver
+}
+
+// New creates a new MCP23017 interface at the I2C address addr (0x20 to
+// 0x27).
+func New(bus embd.I2CBus, addr byte) *MCP23x17 {
+	return &MCP23x17{regs: &i2cRegisters{bus: bus, addr: addr}}
+}
+
+// NewSPI creates a new MCP23S17 interface with the hardware address addr
+// (0 to 7), as set by its A2-A0 pins.
+func NewSPI(bus embd.SPIBus, addr byte) *MCP23x17 {
+	return &MCP23x17{regs: &spiRegisters{bus: bus, addr: addr & 0x07}}
+}
+
+func (d *MCP23x17) readWord(reg byte) (uint16, error) {
+	var buf [2]byte
+	if err := d.regs.read(reg, buf[:]); err != nil {
+		return 0, err
+	}
+	return uint16(buf[0]) | uint16(buf[1])<<8, nil
+}
+
+func (d *MCP23x17) writeWord(reg byte, value uint16) error {
+	return d.regs.write(reg, []byte{byte(value), byte(value >> 8)})
+}
+
+// setup configures the expander. d.mu must be held.
+func (d *MCP23x17) setup() error {
+	if d.initialized {
+		return nil
+	}
+
+	iocon := []byte{ioconMirror | ioconHAEN}
+	if r, ok := d.regs.(*spiRegisters); ok && r.addr != 0 {
+		// Until HAEN is set, the MCP23S17 only answers to address 0.
+		if err := (&spiRegisters{bus: r.bus}).write(ioconReg, iocon); err != nil {
+			return err
+		}
+	}
+	if err := d.regs.write(ioconReg, iocon); err != nil {
+		return err
+	}
+
+	var err error
+	if d.iodir, err = d.readWord(iodirReg); err != nil {
+		return err
+	}
+	if d.gppu, err = d.readWord(gppuReg); err != nil {
+		return err
+	}
+	if d.olat, err = d.readWord(olatReg); err != nil {
+		return err
+	}
+	// Interrupt on any change of the enabled pins.
+	if err := d.writeWord(gpintenReg, 0); err != nil {
+		return err
+	}
+	if err := d.writeWord(intconReg, 0); err != nil {
+		return err
+	}
+
+	glog.V(1).Infof("mcp23x17: initialized with IODIR %#04x, GPPU %#04x, OLAT %#04x", d.iodir, d.gppu, d.olat)
+
+	d.initialized = true
+
+	return nil
+}
+
+// update sets the bits of mask in the cached register *r to those of
+// value, writing the register if it changed. d.mu must be held.
+func (d *MCP23x17) update(reg byte, r *uint16, mask, value uint16) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+	v := *r&^mask | value&mask
+	if v == *r {
+		return nil
+	}
+	if err := d.writeWord(reg, v); err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// Read returns the levels of the 16 pins, GPA0 being bit 0 and GPB7 bit
+// 15.
+func (d *MCP23x17) Read() (uint16, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+	return d.readWord(gpioReg)
+}
+
+// Write sets the output latches of the pins in mask to the matching bits
+// of value, in a single write.
+func (d *MCP23x17) Write(mask, value uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.update(olatReg, &d.olat, mask, value)
+}
+
+// SetDirection sets the pins in mask as inputs or outputs.
+func (d *MCP23x17) SetDirection(mask uint16, dir embd.Direction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var value uint16
+	if dir == embd.In {
+		value = mask
+	}
+	return d.update(iodirReg, &d.iodir, mask, value)
+}
+
+// watch registers w for pin n and enables its interrupt.
+func (d *MCP23x17) watch(n int, w *watch) error {
+	if d.Interrupt == nil {
+		return errors.New("mcp23x17: no interrupt pin")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return err
+	}
+	if d.watches[n] != nil {
+		return fmt.Errorf("mcp23x17: pin %v is already being watched", n)
+	}
+
+	if !d.watching {
+		if err := d.Interrupt.SetDirection(embd.In); err != nil {
+			return err
+		}
+		if err := d.Interrupt.Watch(embd.EdgeFalling, d.interrupt); err != nil {
+			return err
+		}
+		d.watching = true
+	}
+
+	if err := d.update(gpintenReg, &d.gpinten, 1<<uint(n), 1<<uint(n)); err != nil {
+		return err
+	}
+	// Reading GPIO clears any pending interrupt, which would otherwise
+	// hold the interrupt output low.
+	last, err := d.readWord(gpioReg)
+	if err != nil {
+		return err
+	}
+	d.last = last
+	d.watches[n] = w
+
+	return nil
+}
+
+// unwatch disables the interrupt of pin n.
+func (d *MCP23x17) unwatch(n int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.watches[n] == nil {
+		return nil
+	}
+	d.watches[n] = nil
+	if err := d.update(gpintenReg, &d.gpinten, 1<<uint(n), 0); err != nil {
+		return err
+	}
+
+	if d.gpinten == 0 && d.watching {
+		d.watching = false
+		return d.Interrupt.StopWatching()
+	}
+	return nil
+}
+
+// interrupt handles the interrupts of the expander, calling the handlers
+// of the pins which changed.
+func (d *MCP23x17) interrupt(embd.DigitalPin) {
+	d.mu.Lock()
+	value, err := d.readWord(gpioReg)
+	if err != nil {
+		d.mu.Unlock()
+		glog.Errorf("mcp23x17: reading GPIO on interrupt: %v", err)
+		return
+	}
+	changed := (value ^ d.last) & d.gpinten
+	d.last = value
+
+	var fire []*watch
+	for n, w := range d.watches {
+		if w == nil || changed&(1<<uint(n)) == 0 {
+			continue
+		}
+		v := w.pin.logical(int(value>>uint(n)) & 1)
+		switch {
+		case w.edge == embd.EdgeBoth,
+			w.edge == embd.EdgeRising && v == embd.High,
+			w.edge == embd.EdgeFalling && v == embd.Low:
+			fire = append(fire, w)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, w := range fire {
+		w.handler(w.pin)
+	}
+}
+
+var pinMap embd.PinMap
+
+func init() {
+	for n := 0; n < pins; n++ {
+		port := "A"
+		if n >= 8 {
+			port = "B"
+		}
+		pinMap = append(pinMap, &embd.PinDesc{
+			ID:             "GP" + port + strconv.Itoa(n%8),
+			Aliases:        []string{strconv.Itoa(n)},
+			Caps:           embd.CapDigital,
+			DigitalLogical: n,
+		})
+	}
+}
+
+// GPIODriver returns a GPIO driver for the pins of the expander, named
+// GPA0 to GPA7 and GPB0 to GPB7, or 0 to 15.
+func (d *MCP23x17) GPIODriver() embd.GPIODriver {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.drv == nil {
+		d.drv = embd.NewGPIODriver(pinMap, d.newDigitalPin, nil, nil)
+	}
+	return d.drv
+}
+
+// DigitalPin returns the pin designated by key, as GPIODriver().DigitalPin.
+func (d *MCP23x17) DigitalPin(key interface{}) (embd.DigitalPin, error) {
+	return d.GPIODriver().DigitalPin(key)
+}
+
+func (d *MCP23x17) newDigitalPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.DigitalPin {
+	return &digitalPin{d: d, id: pd.ID, n: pd.DigitalLogical, drv: drv}
+}
+
+type digitalPin struct {
+	d   *MCP23x17
+	id  string
+	n   int
+	drv embd.GPIODriver
+
+	mu        sync.Mutex
+	activeLow bool
+}
+
+func (p *digitalPin) mask() uint16 {
+	return 1 << uint(p.n)
+}
+
+// logical converts the level of the pin to its logical value.
+func (p *digitalPin) logical(v int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.activeLow {
+		return v ^ 1
+	}
+	return v
+}
+
+func (p *digitalPin) N() int {
+	return p.n
+}
+
+func (p *digitalPin) Write(val int) error {
+	var v uint16
+	if p.logical(val&1) == embd.High {
+		v = p.mask()
+	}
+	return p.d.Write(p.mask(), v)
+}
+
+func (p *digitalPin) Read() (int, error) {
+	value, err := p.d.Read()
+	if err != nil {
+		return 0, err
+	}
+	return p.logical(int(value>>uint(p.n)) & 1), nil
+}
+
+func (p *digitalPin) TimePulse(state int) (time.Duration, error) {
+	return embd.TimePulseTimeout(p, state, embd.PulseTimeout)
+}
+
+func (p *digitalPin) SetDirection(dir embd.Direction) error {
+	return p.d.SetDirection(p.mask(), dir)
+}
+
+func (p *digitalPin) ActiveLow(b bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.activeLow = b
+	return nil
+}
+
+func (p *digitalPin) PullUp() error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	return p.d.update(gppuReg, &p.d.gppu, p.mask(), p.mask())
+}
+
+func (p *digitalPin) PullDown() error {
+	return errors.New("mcp23x17: pull-down not supported")
+}
+
+func (p *digitalPin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	return p.d.watch(p.n, &watch{pin: p, edge: edge, handler: handler})
+}
+
+func (p *digitalPin) StopWatching() error {
+	return p.d.unwatch(p.n)
+}
+
+func (p *digitalPin) Close() error {
+	if err := p.StopWatching(); err != nil {
+		return err
+	}
+	return p.drv.Unregister(p.id)
+}
@@ -0,0 +1,298 @@
+package mcp23x17
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// chip emulates the registers of a MCP23x17, the levels applied to its
+// input pins and its interrupt output.
+type chip struct {
+	mu   sync.Mutex
+	regs [0x16]byte
+	ext  uint16
+	intr *sim.Pin
+	pend bool // Interrupt pending.
+}
+
+func newChip() *chip {
+	c := &chip{intr: sim.NewPin(100)}
+	c.intr.SetPull(sim.PullUp)
+	// IODIR resets to all inputs.
+	c.regs[iodirReg], c.regs[iodirReg+1] = 0xFF, 0xFF
+	return c
+}
+
+func (c *chip) word(reg byte) uint16 {
+	return uint16(c.regs[reg]) | uint16(c.regs[reg+1])<<8
+}
+
+func (c *chip) gpio() uint16 {
+	iodir := c.word(iodirReg)
+	return c.word(olatReg)&^iodir | c.ext&iodir
+}
+
+// setInt updates the interrupt output once c.mu is released.
+func (c *chip) setInt(pend bool) func() {
+	if pend == c.pend {
+		return func() {}
+	}
+	c.pend = pend
+	if pend {
+		return func() { c.intr.Drive(embd.Low) }
+	}
+	return c.intr.Release
+}
+
+func (c *chip) read(reg byte, value []byte) {
+	c.mu.Lock()
+	after := func() {}
+	for i := range value {
+		r := reg + byte(i)
+		switch r {
+		case gpioReg:
+			value[i] = byte(c.gpio())
+		case gpioReg + 1:
+			value[i] = byte(c.gpio() >> 8)
+		default:
+			value[i] = c.regs[r]
+		}
+	}
+	if reg <= gpioReg+1 && reg+byte(len(value)) > gpioReg {
+		// Reading GPIO clears the interrupt.
+		after = c.setInt(false)
+	}
+	c.mu.Unlock()
+	after()
+}
+
+func (c *chip) write(reg byte, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, v := range value {
+		r := reg + byte(i)
+		if r == ioconReg+1 {
+			r = ioconReg
+		}
+		c.regs[r] = v
+	}
+}
+
+// set applies levels to the input pins.
+func (c *chip) set(ext uint16) {
+	c.mu.Lock()
+	prev := c.gpio()
+	c.ext = ext
+	after := func() {}
+	if (prev^c.gpio())&c.word(gpintenReg) != 0 {
+		after = c.setInt(true)
+	}
+	c.mu.Unlock()
+	after()
+}
+
+type fakeI2CBus struct {
+	sim.I2CBus
+
+	addr byte
+	c    *chip
+}
+
+func (b *fakeI2CBus) check(addr byte) error {
+	if addr != b.addr {
+		return errors.New("no device at address")
+	}
+	return nil
+}
+
+func (b *fakeI2CBus) ReadFromReg(addr, reg byte, value []byte) error {
+	if err := b.check(addr); err != nil {
+		return err
+	}
+	b.c.read(reg, value)
+	return nil
+}
+
+func (b *fakeI2CBus) WriteToReg(addr, reg byte, value []byte) error {
+	if err := b.check(addr); err != nil {
+		return err
+	}
+	b.c.write(reg, value)
+	return nil
+}
+
+type fakeSPIBus struct {
+	sim.SPIBus
+
+	addr byte
+	c    *chip
+}
+
+func (b *fakeSPIBus) TransferAndReceiveData(buf []byte) error {
+	if len(buf) < 3 {
+		return errors.New("short transfer")
+	}
+	addr := buf[0] >> 1 & 0x07
+	b.c.mu.Lock()
+	haen := b.c.regs[ioconReg]&ioconHAEN != 0
+	b.c.mu.Unlock()
+	if !haen {
+		addr = 0
+	}
+	if addr != b.addr && haen {
+		return nil
+	}
+	if buf[0]&1 != 0 {
+		b.c.read(buf[1], buf[2:])
+	} else {
+		b.c.write(buf[1], buf[2:])
+	}
+	return nil
+}
+
+func TestMountedPins(t *testing.T) {
+	c := newChip()
+	d := New(&fakeI2CBus{addr: 0x20, c: c}, 0x20)
+	b := embd.NewBoard(&embd.Descriptor{})
+	if err := b.MountGPIO("EXP0", d.GPIODriver()); err != nil {
+		t.Fatalf("Mounting expander: got %v", err)
+	}
+	defer b.CloseGPIO()
+
+	out, err := b.NewDigitalPin("EXP0_GPA3")
+	if err != nil {
+		t.Fatalf("Looking up EXP0_GPA3: got %v", err)
+	}
+	if err := out.SetDirection(embd.Out); err != nil {
+		t.Fatalf("Setting GPA3 as output: got %v", err)
+	}
+	if err := out.Write(embd.High); err != nil {
+		t.Fatalf("Writing GPA3: got %v", err)
+	}
+	if iodir, olat := c.word(iodirReg), c.word(olatReg); iodir != 0xFFF7 || olat != 0x0008 {
+		t.Errorf("Writing GPA3 high: got IODIR %#04x, OLAT %#04x, want 0xfff7, 0x0008", iodir, olat)
+	}
+	if v, err := out.Read(); err != nil || v != embd.High {
+		t.Errorf("Reading back GPA3: got %v, %v, want %v", v, err, embd.High)
+	}
+
+	in, err := b.NewDigitalPin("EXP0_9")
+	if err != nil {
+		t.Fatalf("Looking up EXP0_9: got %v", err)
+	}
+	if in.N() != 9 {
+		t.Errorf("Looking up EXP0_9: got pin %v", in.N())
+	}
+	if err := in.PullUp(); err != nil {
+		t.Fatalf("Pulling GPB1 up: got %v", err)
+	}
+	if gppu := c.word(gppuReg); gppu != 0x0200 {
+		t.Errorf("Pulling GPB1 up: got GPPU %#04x, want 0x0200", gppu)
+	}
+	if err := in.PullDown(); err == nil {
+		t.Error("Pulling GPB1 down: got nil error")
+	}
+	c.set(0x0200)
+	if v, _ := in.Read(); v != embd.High {
+		t.Errorf("Reading GPB1: got %v, want %v", v, embd.High)
+	}
+	in.ActiveLow(true)
+	if v, _ := in.Read(); v != embd.Low {
+		t.Errorf("Reading active low GPB1: got %v, want %v", v, embd.Low)
+	}
+}
+
+func TestSPIAddress(t *testing.T) {
+	c := newChip()
+	d := NewSPI(&fakeSPIBus{addr: 5, c: c}, 5)
+	if err := d.Write(0xFFFF, 0x1234); err != nil {
+		t.Fatalf("Writing the latches: got %v", err)
+	}
+	if olat := c.word(olatReg); olat != 0x1234 {
+		t.Errorf("Writing the latches: got OLAT %#04x, want 0x1234", olat)
+	}
+	if c.regs[ioconReg]&ioconHAEN == 0 {
+		t.Error("Writing the latches: HAEN is not set")
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	c := newChip()
+	d := New(&fakeI2CBus{addr: 0x21, c: c}, 0x21)
+	d.Interrupt = c.intr
+
+	pin, err := d.DigitalPin("GPB0")
+	if err != nil {
+		t.Fatalf("Looking up GPB0: got %v", err)
+	}
+	var mu sync.Mutex
+	var calls []int
+	handler := func(p embd.DigitalPin) {
+		v, err := p.Read()
+		if err != nil {
+			t.Errorf("Reading GPB0 from its handler: got %v", err)
+		}
+		mu.Lock()
+		calls = append(calls, v)
+		mu.Unlock()
+	}
+	if err := pin.Watch(embd.EdgeRising, handler); err != nil {
+		t.Fatalf("Watching GPB0: got %v", err)
+	}
+	if err := pin.Watch(embd.EdgeRising, handler); err == nil {
+		t.Error("Watching GPB0 twice: got nil error")
+	}
+	if gpinten := c.word(gpintenReg); gpinten != 0x0100 {
+		t.Errorf("Watching GPB0: got GPINTEN %#04x, want 0x0100", gpinten)
+	}
+
+	c.set(0x0100)
+	c.set(0x0000)
+	c.set(0x0001) // GPA0 is not watched.
+	c.set(0x0101)
+
+	mu.Lock()
+	if len(calls) != 2 || calls[0] != embd.High || calls[1] != embd.High {
+		t.Errorf("Two rising edges on GPB0: got handler calls %v, want [1 1]", calls)
+	}
+	mu.Unlock()
+	if c.pend {
+		t.Error("Two rising edges on GPB0: interrupt left pending")
+	}
+
+	if err := pin.Close(); err != nil {
+		t.Fatalf("Closing GPB0: got %v", err)
+	}
+	if gpinten := c.word(gpintenReg); gpinten != 0 {
+		t.Errorf("Closing GPB0: got GPINTEN %#04x, want 0", gpinten)
+	}
+}
+
+func TestNoInterruptPin(t *testing.T) {
+	d := New(&fakeI2CBus{addr: 0x20, c: newChip()}, 0x20)
+	pin, _ := d.DigitalPin(0)
+	if err := pin.Watch(embd.EdgeBoth, func(embd.DigitalPin) {}); err == nil {
+		t.Error("Watching GPA0 without interrupt pin: got nil error")
+	}
+}
+
+func TestTimePulseTimeout(t *testing.T) {
+	defer func(d time.Duration) { embd.PulseTimeout = d }(embd.PulseTimeout)
+	embd.PulseTimeout = 10 * time.Millisecond
+
+	d := New(&fakeI2CBus{addr: 0x20, c: newChip()}, 0x20)
+	pin, err := d.DigitalPin("GPA0")
+	if err != nil {
+		t.Fatalf("Looking up GPA0: got %v", err)
+	}
+	pin.SetDirection(embd.In)
+	if _, err := pin.TimePulse(embd.High); !embd.IsPulseTimeout(err) {
+		t.Fatalf("Measuring pulse on idle GPA0: got %v, want a timeout", err)
+	}
+}
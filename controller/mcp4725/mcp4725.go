@@ -1,4 +1,7 @@
 // Package mcp4725 allows interfacing with the MCP4725 DAC.
+//
+// The DAC implements embd.AnalogOutput, writing its output with the fast
+// write command.
 package mcp4725
 
 import (
@@ -15,8 +18,42 @@ const (
 
 	genReset = 0x06
 	powerUp  = 0x09
+
+	maxValue = 4095
+)
+
+// PowerDown selects the load of the output while powered down.
+type PowerDown int
+
+const (
+	// Normal is the normal mode of operation, not powered down.
+	Normal PowerDown = iota
+	// PowerDown1K pulls the output down with 1kΩ.
+	PowerDown1K
+	// PowerDown100K pulls the output down with 100kΩ.
+	PowerDown100K
+	// PowerDown500K pulls the output down with 500kΩ.
+	PowerDown500K
 )
 
+// Status is the content of the DAC register and the EEPROM.
+type Status struct {
+	// Ready is false while the EEPROM is being written.
+	Ready bool
+
+	// PowerOnReset is set once the DAC is powered up.
+	PowerOnReset bool
+
+	// Value and PowerDown are the content of the DAC register.
+	Value     int
+	PowerDown PowerDown
+
+	// PersistedValue and PersistedPowerDown are the content of the
+	// EEPROM, loaded into the DAC register on reset.
+	PersistedValue     int
+	PersistedPowerDown PowerDown
+}
+
 // MCP4725 represents a MCP4725 DAC.
 type MCP4725 struct {
 	// Bus to communicate over.
@@ -59,16 +96,21 @@ func (d *MCP4725) setup() error {
 	return nil
 }
 
+func clamp(value int) int {
+	if value > maxValue {
+		value = maxValue
+	}
+	if value < 0 {
+		value = 0
+	}
+	return value
+}
+
 func (d *MCP4725) setVoltage(voltage int, reg byte) error {
 	if err := d.setup(); err != nil {
 		return err
 	}
-	if voltage > 4095 {
-		voltage = 4095
-	}
-	if voltage < 0 {
-		voltage = 0
-	}
+	voltage = clamp(voltage)
 
 	glog.V(2).Infof("mcp4725: setting voltage to %04d", voltage)
 
@@ -89,6 +131,54 @@ func (d *MCP4725) SetPersistedVoltage(voltage int) error {
 	return d.setVoltage(voltage, programReg)
 }
 
+// FastWrite sets the output to value (0-4095) and the power down mode with
+// the two byte fast write command.
+func (d *MCP4725) FastWrite(value int, pd PowerDown) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+	value = clamp(value)
+
+	glog.V(2).Infof("mcp4725: fast writing %04d", value)
+
+	return d.Bus.WriteBytes(d.Addr, []byte{byte(pd&0x3)<<4 | byte(value>>8), byte(value)})
+}
+
+// Write sets the output to value (0-4095) with the fast write command. It
+// implements embd.AnalogOutput.
+func (d *MCP4725) Write(value int) error {
+	return d.FastWrite(value, Normal)
+}
+
+// Max returns the largest value of the output, 4095.
+func (d *MCP4725) Max() int {
+	return maxValue
+}
+
+// ReadStatus reads back the DAC register and the EEPROM.
+func (d *MCP4725) ReadStatus() (*Status, error) {
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+
+	data, err := d.Bus.ReadBytes(d.Addr, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Status{
+		Ready:              data[0]&0x80 != 0,
+		PowerOnReset:       data[0]&0x40 != 0,
+		PowerDown:          PowerDown(data[0] >> 1 & 0x3),
+		Value:              int(data[1])<<4 | int(data[2]>>4),
+		PersistedPowerDown: PowerDown(data[3] >> 5 & 0x3),
+		PersistedValue:     int(data[3]&0xF)<<8 | int(data[4]),
+	}
+	glog.V(2).Infof("mcp4725: read status %+v", *s)
+
+	return s, nil
+}
+
 // Close puts the DAC into power down mode.
 func (d *MCP4725) Close() error {
 	glog.V(1).Infof("mcp4725: powering down")
@@ -0,0 +1,76 @@
+package mcp4725
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+const testAddr = 0x62
+
+type fakeBus struct {
+	sim.I2CBus
+
+	writes [][]byte
+	status []byte
+}
+
+func (b *fakeBus) WriteBytes(addr byte, value []byte) error {
+	if addr != testAddr {
+		return errors.New("bad write")
+	}
+	b.writes = append(b.writes, value)
+	return nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	return b.WriteBytes(addr, []byte{reg, value})
+}
+
+func (b *fakeBus) ReadBytes(addr byte, num int) ([]byte, error) {
+	if addr != testAddr || num != len(b.status) {
+		return nil, errors.New("bad read")
+	}
+	return b.status, nil
+}
+
+var _ embd.AnalogOutput = (*MCP4725)(nil)
+
+func TestFastWrite(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus, testAddr)
+	if err := d.Write(0xABC); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.FastWrite(5000, PowerDown100K); err != nil {
+		t.Fatal(err)
+	}
+	writes := bus.writes[len(bus.writes)-2:]
+	want := [][]byte{{0x0A, 0xBC}, {0x2F, 0xFF}}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("FastWrite: got %#02x, want %#02x", writes, want)
+	}
+}
+
+func TestReadStatus(t *testing.T) {
+	bus := &fakeBus{status: []byte{0xC4, 0xAB, 0xC0, 0x41, 0x23}}
+	d := New(bus, testAddr)
+	s, err := d.ReadStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Status{
+		Ready:              true,
+		PowerOnReset:       true,
+		Value:              0xABC,
+		PowerDown:          PowerDown100K,
+		PersistedValue:     0x123,
+		PersistedPowerDown: PowerDown100K,
+	}
+	if *s != want {
+		t.Errorf("ReadStatus: got %+v, want %+v", *s, want)
+	}
+}
@@ -0,0 +1,303 @@
+// Package mcp4728 allows interfacing with the MCP4728 quad 12-bit DAC
+// through I2C protocol.
+//
+// Each channel uses either VDD or the internal 2.048V reference, with a
+// gain of 1 or 2, and is available as an embd.AnalogOutput.
+package mcp4728
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	fastWriteCmd   = 0x00
+	multiWriteCmd  = 0x40
+	singleWriteCmd = 0x58
+	powerDownCmd   = 0xA0
+
+	channels = 4
+	maxValue = 4095
+
+	// InternalVref is the voltage of the internal reference.
+	InternalVref = 2.048
+
+	// DefaultAddr is the address of the DAC as shipped.
+	DefaultAddr = 0x60
+)
+
+// Vref selects the reference voltage of a channel.
+type Vref int
+
+const (
+	// VrefVDD uses the supply voltage as the reference.
+	VrefVDD Vref = iota
+	// VrefInternal uses the internal 2.048V reference.
+	VrefInternal
+)
+
+// Gain selects the gain of a channel, when using the internal reference.
+type Gain int
+
+const (
+	// Gain1 outputs up to 2.048V with the internal reference.
+	Gain1 Gain = iota
+	// Gain2 outputs up to 4.096V with the internal reference.
+	Gain2
+)
+
+// PowerDown selects the load of an output while powered down.
+type PowerDown int
+
+const (
+	// Normal is the normal mode of operation, not powered down.
+	Normal PowerDown = iota
+	// PowerDown1K pulls the output down with 1kΩ.
+	PowerDown1K
+	// PowerDown100K pulls the output down with 100kΩ.
+	PowerDown100K
+	// PowerDown500K pulls the output down with 500kΩ.
+	PowerDown500K
+)
+
+// Config is the configuration of a channel.
+type Config struct {
+	Vref      Vref
+	Gain      Gain
+	PowerDown PowerDown
+}
+
+// FullScale returns the output voltage of a channel set to its maximum
+// plus one, given the supply voltage vdd.
+func (c Config) FullScale(vdd float64) float64 {
+	if c.Vref == VrefVDD {
+		return vdd
+	}
+	if c.Gain == Gain2 {
+		return 2 * InternalVref
+	}
+	return InternalVref
+}
+
+// bytes encodes c and value as the two data bytes of the write commands.
+func (c Config) bytes(value int) []byte {
+	return []byte{byte(c.Vref&0x1)<<7 | byte(c.PowerDown&0x3)<<5 | byte(c.Gain&0x1)<<4 | byte(value>>8), byte(value)}
+}
+
+func parseConfig(b []byte) (Config, int) {
+	c := Config{
+		Vref:      Vref(b[0] >> 7),
+		PowerDown: PowerDown(b[0] >> 5 & 0x3),
+		Gain:      Gain(b[0] >> 4 & 0x1),
+	}
+	return c, int(b[0]&0xF)<<8 | int(b[1])
+}
+
+// Status is the content of the DAC input register and the EEPROM of a
+// channel.
+type Status struct {
+	// Ready is false while the EEPROM is being written.
+	Ready bool
+
+	// PowerOnReset is set once the DAC is powered up.
+	PowerOnReset bool
+
+	// Config and Value are the content of the input register.
+	Config Config
+	Value  int
+
+	// PersistedConfig and PersistedValue are the content of the EEPROM,
+	// loaded into the input register on reset.
+	PersistedConfig Config
+	PersistedValue  int
+}
+
+// MCP4728 represents a MCP4728 DAC.
+type MCP4728 struct {
+	// Bus to communicate over.
+	Bus embd.I2CBus
+	// Addr of the DAC.
+	Addr byte
+
+	mu      sync.Mutex
+	configs [channels]Config
+	values  [channels]int
+}
+
+// New creates a new MCP4728 interface. The channels are assumed to use VDD
+// as their reference until configured.
+func New(bus embd.I2CBus, addr byte) *MCP4728 {
+	return &MCP4728{
+		Bus:  bus,
+		Addr: addr,
+	}
+}
+
+func checkChannel(ch int) error {
+	if ch < 0 || ch >= channels {
+		return fmt.Errorf("mcp4728: invalid channel %v (must be 0-3)", ch)
+	}
+	return nil
+}
+
+func clamp(value int) int {
+	if value > maxValue {
+		value = maxValue
+	}
+	if value < 0 {
+		value = 0
+	}
+	return value
+}
+
+// writeChannel writes the input register of ch with the multi-write
+// command, or the input register and the EEPROM with the single write
+// command. d.mu must be held.
+func (d *MCP4728) writeChannel(cmd byte, ch int, c Config, value int) error {
+	data := append([]byte{cmd | byte(ch)<<1}, c.bytes(value)...)
+	if err := d.Bus.WriteBytes(d.Addr, data); err != nil {
+		return err
+	}
+	glog.V(2).Infof("mcp4728: wrote %#02x to channel %v", data, ch)
+	return nil
+}
+
+// Configure sets the reference, gain and power down mode of ch (0-3).
+func (d *MCP4728) Configure(ch int, c Config) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.writeChannel(multiWriteCmd, ch, c, d.values[ch]); err != nil {
+		return err
+	}
+	d.configs[ch] = c
+	return nil
+}
+
+// Write sets ch (0-3) to value (0-4095).
+func (d *MCP4728) Write(ch, value int) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	value = clamp(value)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.writeChannel(multiWriteCmd, ch, d.configs[ch], value); err != nil {
+		return err
+	}
+	d.values[ch] = value
+	return nil
+}
+
+// WritePersisted sets ch (0-3) to value (0-4095) and programs the EEPROM
+// so that the channel is restored on reset.
+func (d *MCP4728) WritePersisted(ch, value int) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	value = clamp(value)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.writeChannel(singleWriteCmd, ch, d.configs[ch], value); err != nil {
+		return err
+	}
+	d.values[ch] = value
+	return nil
+}
+
+// WriteAll sets the four channels at once with the fast write command,
+// which keeps the reference and gain of the channels.
+func (d *MCP4728) WriteAll(values [channels]int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data := make([]byte, 0, 2*channels)
+	for ch, value := range values {
+		value = clamp(value)
+		values[ch] = value
+		data = append(data, fastWriteCmd|byte(d.configs[ch].PowerDown&0x3)<<4|byte(value>>8), byte(value))
+	}
+	if err := d.Bus.WriteBytes(d.Addr, data); err != nil {
+		return err
+	}
+	glog.V(2).Infof("mcp4728: fast wrote %v", values)
+
+	d.values = values
+	return nil
+}
+
+// ReadStatus reads back the input registers and the EEPROM of the four
+// channels.
+func (d *MCP4728) ReadStatus() ([]Status, error) {
+	data, err := d.Bus.ReadBytes(d.Addr, 6*channels)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, channels)
+	for ch := range status {
+		b := data[6*ch:]
+		if int(b[0]>>4&0x3) != ch {
+			return nil, fmt.Errorf("mcp4728: read status of channel %v instead of %v", b[0]>>4&0x3, ch)
+		}
+		s := &status[ch]
+		s.Ready = b[0]&0x80 != 0
+		s.PowerOnReset = b[0]&0x40 != 0
+		s.Config, s.Value = parseConfig(b[1:3])
+		s.PersistedConfig, s.PersistedValue = parseConfig(b[4:6])
+	}
+	return status, nil
+}
+
+// Close powers the four channels down.
+func (d *MCP4728) Close() error {
+	glog.V(1).Infof("mcp4728: powering down")
+
+	return d.Bus.WriteBytes(d.Addr, []byte{powerDownCmd | 0xF, 0xF0})
+}
+
+// Output is a channel of the DAC. It implements embd.AnalogOutput.
+type Output struct {
+	d  *MCP4728
+	ch int
+}
+
+// Output returns ch (0-3) of the DAC.
+func (d *MCP4728) Output(ch int) *Output {
+	return &Output{d: d, ch: ch}
+}
+
+// Write sets the channel to value (0-4095).
+func (o *Output) Write(value int) error {
+	return o.d.Write(o.ch, value)
+}
+
+// Max returns the largest value of the channel, 4095.
+func (o *Output) Max() int {
+	return maxValue
+}
+
+// Close powers the channel down until it is written again.
+func (o *Output) Close() error {
+	if err := checkChannel(o.ch); err != nil {
+		return err
+	}
+
+	o.d.mu.Lock()
+	defer o.d.mu.Unlock()
+
+	c := o.d.configs[o.ch]
+	c.PowerDown = PowerDown500K
+	return o.d.writeChannel(multiWriteCmd, o.ch, c, o.d.values[o.ch])
+}
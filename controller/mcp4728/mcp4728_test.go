@@ -0,0 +1,163 @@
+package mcp4728
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// fakeBus emulates the input registers and EEPROM of a MCP4728.
+type fakeBus struct {
+	sim.I2CBus
+
+	writes [][]byte
+	regs   [channels][2]byte
+	eeprom [channels][2]byte
+}
+
+func (b *fakeBus) WriteBytes(addr byte, value []byte) error {
+	if addr != DefaultAddr {
+		return errors.New("bad write")
+	}
+	b.writes = append(b.writes, append([]byte(nil), value...))
+
+	switch cmd := value[0]; {
+	case cmd&0xF8 == multiWriteCmd:
+		b.regs[cmd>>1&0x3] = [2]byte{value[1], value[2]}
+	case cmd&0xF8 == singleWriteCmd:
+		b.regs[cmd>>1&0x3] = [2]byte{value[1], value[2]}
+		b.eeprom[cmd>>1&0x3] = [2]byte{value[1], value[2]}
+	case cmd&0xF0 == powerDownCmd:
+	case cmd&0xC0 == fastWriteCmd:
+		for ch := range b.regs {
+			hi, lo := value[2*ch], value[2*ch+1]
+			b.regs[ch] = [2]byte{b.regs[ch][0]&0x90 | hi&0x3F, lo}
+		}
+	default:
+		return errors.New("bad command")
+	}
+	return nil
+}
+
+func (b *fakeBus) ReadBytes(addr byte, num int) ([]byte, error) {
+	if addr != DefaultAddr || num != 24 {
+		return nil, errors.New("bad read")
+	}
+	var data []byte
+	for ch := range b.regs {
+		status := byte(0xC0 | ch<<4)
+		data = append(data, status, b.regs[ch][0], b.regs[ch][1], status, b.eeprom[ch][0], b.eeprom[ch][1])
+	}
+	return data, nil
+}
+
+var _ embd.AnalogOutput = (*Output)(nil)
+
+func TestWrite(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus, DefaultAddr)
+	c := Config{Vref: VrefInternal, Gain: Gain2}
+	if err := d.Configure(2, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write(2, 0xABC); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x44, 0x9A, 0xBC}; !reflect.DeepEqual(bus.writes[1], want) {
+		t.Errorf("Write: got %#02x, want %#02x", bus.writes[1], want)
+	}
+	if err := d.WritePersisted(0, 5000); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x58, 0x0F, 0xFF}; !reflect.DeepEqual(bus.writes[2], want) {
+		t.Errorf("WritePersisted: got %#02x, want %#02x", bus.writes[2], want)
+	}
+	if err := d.Write(4, 0); err == nil {
+		t.Error("Write to channel 4: got no error")
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus, DefaultAddr)
+	if err := d.Configure(1, Config{PowerDown: PowerDown1K}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteAll([4]int{0x123, 0x456, -1, 0x789}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x01, 0x23, 0x14, 0x56, 0x00, 0x00, 0x07, 0x89}
+	if got := bus.writes[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteAll: got %#02x, want %#02x", got, want)
+	}
+}
+
+func TestReadStatus(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus, DefaultAddr)
+	c := Config{Vref: VrefInternal, Gain: Gain1, PowerDown: PowerDown100K}
+	if err := d.Configure(3, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WritePersisted(3, 1000); err != nil {
+		t.Fatal(err)
+	}
+	status, err := d.ReadStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Status{Ready: true, PowerOnReset: true, Config: c, Value: 1000, PersistedConfig: c, PersistedValue: 1000}
+	if status[3] != want {
+		t.Errorf("ReadStatus: got %+v, want %+v", status[3], want)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus, DefaultAddr)
+	if err := d.Configure(0, Config{Vref: VrefInternal, Gain: Gain2}); err != nil {
+		t.Fatal(err)
+	}
+	out := d.Output(0)
+	if err := embd.WriteVolts(out, 1.024, Config{Vref: VrefInternal, Gain: Gain2}.FullScale(3.3)); err != nil {
+		t.Fatal(err)
+	}
+	status, _ := d.ReadStatus()
+	if status[0].Value != 1024 {
+		t.Errorf("WriteVolts(1.024): got %v, want 1024", status[0].Value)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = d.ReadStatus()
+	if status[0].Config.PowerDown != PowerDown500K || status[0].Value != 1024 {
+		t.Errorf("Close: got %+v, want powered down", status[0])
+	}
+	if err := out.Write(0); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = d.ReadStatus()
+	if status[0].Config.PowerDown != Normal {
+		t.Errorf("Write after Close: got %+v, want powered up", status[0])
+	}
+}
+
+func TestFullScale(t *testing.T) {
+	tests := []struct {
+		c    Config
+		want float64
+	}{
+		{Config{}, 5},
+		{Config{Vref: VrefInternal}, 2.048},
+		{Config{Vref: VrefInternal, Gain: Gain2}, 4.096},
+	}
+	for _, test := range tests {
+		if got := test.c.FullScale(5); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("FullScale of %+v: got %v, want %v", test.c, got, test.want)
+		}
+	}
+}
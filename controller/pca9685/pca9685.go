@@ -1,8 +1,14 @@
 // Package pca9685 allows interfacing with the pca9685 16-channel, 12-bit PWM Controller through I2C protocol.
+//
+// The channels implement embd.PWMPin, directly or through a GPIODriver
+// which can be mounted on the board. They share the period of the
+// controller, which can be changed at any time.
 package pca9685
 
 import (
+	"fmt"
 	"math"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,22 +22,60 @@ const (
 	pwmControlPoints = 4096
 
 	mode1RegAddr    = 0x00
+	mode2RegAddr    = 0x01
+	subAddr1RegAddr = 0x02
+	allCallRegAddr  = 0x05
+	pwm0OnLowReg    = 0x6
+	allPwmOnLowReg  = 0xFA
 	preScaleRegAddr = 0xFE
 
-	pwm0OnLowReg = 0x6
+	mode1Restart = 0x80
+	mode1ExtClk  = 0x40
+	mode1AI      = 0x20
+	mode1Sleep   = 0x10
+	mode1Sub1    = 0x08
+	mode1AllCall = 0x01
+
+	mode2Invrt = 0x10
+
+	// Bit 4 of the ON_H and OFF_H registers turns the channel fully on
+	// or off; full off takes precedence.
+	fullBit = 0x10
+
+	minPreScale = 3
+	maxPreScale = 255
+
+	channels = 16
 
 	// inspired by arduino's default freq for analogWrites
 	defaultFreq = 490
+
+	// DefaultAllCallAddr is the power-on LED All Call address.
+	DefaultAllCallAddr = 0x70
 )
 
+// Pwm is the on and off times of a channel, 0-4095.
+type Pwm struct {
+	On, Off int
+}
+
 // PCA9685 represents a PCA9685 PWM generator.
 type PCA9685 struct {
 	Bus  embd.I2CBus
 	Addr byte
 	Freq int
 
+	// ExtClock is the frequency in Hz of the clock applied to the EXTCLK
+	// pin. The internal 25MHz oscillator is used if 0. Once selected, the
+	// external clock is only deselected by a power cycle or a software
+	// reset.
+	ExtClock int
+
 	initialized bool
 	mu          sync.RWMutex
+	preScale    byte
+
+	drv embd.GPIODriver
 }
 
 // New creates a new PCA9685 interface.
@@ -46,6 +90,13 @@ func (d *PCA9685) mode1Reg() (byte, error) {
 	return d.Bus.ReadByteFromReg(d.Addr, mode1RegAddr)
 }
 
+func (d *PCA9685) clock() int {
+	if d.ExtClock != 0 {
+		return d.ExtClock
+	}
+	return clockFreq
+}
+
 func (d *PCA9685) setup() error {
 	d.mu.RLock()
 	if d.initialized {
@@ -57,6 +108,10 @@ func (d *PCA9685) setup() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if d.initialized {
+		return nil
+	}
+
 	mode1Reg, err := d.mode1Reg()
 	if err != nil {
 		return err
@@ -68,26 +123,37 @@ func (d *PCA9685) setup() error {
 		return err
 	}
 
+	newmode := (mode1Reg | mode1AI | mode1AllCall) &^ (mode1Restart | mode1Sleep)
+	if d.ExtClock != 0 {
+		// EXTCLK is only taken into account while asleep.
+		extClkMode := (mode1Reg &^ mode1Restart) | mode1Sleep | mode1ExtClk
+		if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, extClkMode); err != nil {
+			return err
+		}
+		glog.V(1).Infof("pca9685: external clock [%v hz] selected", d.ExtClock)
+		newmode |= mode1ExtClk
+	}
+
 	if d.Freq == 0 {
 		d.Freq = defaultFreq
 	}
-	preScaleValue := byte(math.Floor(float64(clockFreq/(pwmControlPoints*d.Freq))+float64(0.5)) - 1)
-	glog.V(1).Infof("pca9685: calculated prescale value = %#02x", preScaleValue)
-	if err := d.Bus.WriteByteToReg(d.Addr, preScaleRegAddr, byte(preScaleValue)); err != nil {
+	preScaleValue, err := d.calcPreScale(float64(d.Freq))
+	if err != nil {
+		return err
+	}
+	if err := d.writePreScale(preScaleValue); err != nil {
 		return err
 	}
-	glog.V(1).Infof("pca9685: prescale value [%#02x] written to PRE_SCALE Reg [regAddr: %#02x]", preScaleValue, preScaleRegAddr)
 
 	if err := d.wake(); err != nil {
 		return err
 	}
 
-	newmode := ((mode1Reg | 0x01) & 0xDF)
 	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, newmode); err != nil {
 		return err
 	}
 
-	glog.V(1).Infof("pca9685: new mode [%#02x] [disabling register auto increment] written to MODE1 Reg [regAddr: %#02x]", newmode, mode1RegAddr)
+	glog.V(1).Infof("pca9685: new mode [%#02x] [enabling register auto increment] written to MODE1 Reg [regAddr: %#02x]", newmode, mode1RegAddr)
 
 	d.initialized = true
 
@@ -96,77 +162,450 @@ func (d *PCA9685) setup() error {
 	return nil
 }
 
+// calcPreScale returns the PRE_SCALE value generating freq.
+func (d *PCA9685) calcPreScale(freq float64) (byte, error) {
+	preScale := math.Floor(float64(d.clock())/(pwmControlPoints*freq)+0.5) - 1
+	if preScale < minPreScale || preScale > maxPreScale {
+		return 0, fmt.Errorf("pca9685: pwm freq %v hz is out of bounds", freq)
+	}
+	glog.V(1).Infof("pca9685: calculated prescale value = %#02x", byte(preScale))
+	return byte(preScale), nil
+}
+
+// writePreScale writes the PRE_SCALE register, which is only writable while
+// asleep. d.mu must be held.
+func (d *PCA9685) writePreScale(preScale byte) error {
+	if err := d.Bus.WriteByteToReg(d.Addr, preScaleRegAddr, preScale); err != nil {
+		return err
+	}
+	glog.V(1).Infof("pca9685: prescale value [%#02x] written to PRE_SCALE Reg [regAddr: %#02x]", preScale, preScaleRegAddr)
+	d.preScale = preScale
+	return nil
+}
+
+// setPreScale changes the frequency of the outputs. d.mu must be held.
+func (d *PCA9685) setPreScale(preScale byte) error {
+	if preScale == d.preScale {
+		return nil
+	}
+	if err := d.sleep(); err != nil {
+		return err
+	}
+	if err := d.writePreScale(preScale); err != nil {
+		return err
+	}
+	return d.wake()
+}
+
+// SetFreq changes the frequency of the outputs, from 24 to 1526 hz with the
+// internal oscillator. The duty cycles of the channels are preserved.
+func (d *PCA9685) SetFreq(freq int) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	preScale, err := d.calcPreScale(float64(freq))
+	if err != nil {
+		return err
+	}
+	if err := d.setPreScale(preScale); err != nil {
+		return err
+	}
+	d.Freq = freq
+
+	glog.V(1).Infof("pca9685: pwm freq changed to %v", freq)
+
+	return nil
+}
+
+// SetPeriod changes the period of the outputs, in ns. The duty cycles of
+// the channels are preserved.
+func (d *PCA9685) SetPeriod(ns int) error {
+	if ns <= 0 {
+		return fmt.Errorf("pca9685: pwm period %v is out of bounds", ns)
+	}
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	preScale, err := d.calcPreScale(1e9 / float64(ns))
+	if err != nil {
+		return err
+	}
+	if err := d.setPreScale(preScale); err != nil {
+		return err
+	}
+	d.Freq = int(1e9/float64(ns) + 0.5)
+
+	return nil
+}
+
+// Period returns the actual period of the outputs in ns, which may differ
+// slightly from the one requested due to the resolution of the prescaler.
+func (d *PCA9685) Period() (int, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.period(), nil
+}
+
+// period returns the period of the outputs in ns. d.mu must be held.
+func (d *PCA9685) period() int {
+	return int((int64(d.preScale) + 1) * pwmControlPoints * 1e9 / int64(d.clock()))
+}
+
+// SetPolarity sets the polarity of all the outputs through the INVRT bit
+// of MODE2.
+func (d *PCA9685) SetPolarity(pol embd.Polarity) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	mode2Reg, err := d.Bus.ReadByteFromReg(d.Addr, mode2RegAddr)
+	if err != nil {
+		return err
+	}
+	newmode := mode2Reg &^ mode2Invrt
+	if pol == embd.Negative {
+		newmode |= mode2Invrt
+	}
+	if newmode == mode2Reg {
+		return nil
+	}
+	if err := d.Bus.WriteByteToReg(d.Addr, mode2RegAddr, newmode); err != nil {
+		return err
+	}
+	glog.V(1).Infof("pca9685: new mode [%#02x] written to MODE2 Reg [regAddr: %#02x]", newmode, mode2RegAddr)
+
+	return nil
+}
+
+// updateMode1 sets and clears bits of MODE1. d.mu must be held.
+func (d *PCA9685) updateMode1(set, clear byte) error {
+	mode1Reg, err := d.mode1Reg()
+	if err != nil {
+		return err
+	}
+	// Writing RESTART back would restart a sleeping controller.
+	newmode := (mode1Reg | set) &^ (clear | mode1Restart)
+	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, newmode); err != nil {
+		return err
+	}
+	glog.V(1).Infof("pca9685: new mode [%#02x] written to MODE1 Reg [regAddr: %#02x]", newmode, mode1RegAddr)
+	return nil
+}
+
+// EnableAllCall makes the controller respond to the 7-bit LED All Call
+// address addr, in addition to Addr. A PCA9685 created with that address
+// drives all the controllers on the bus at once.
+func (d *PCA9685) EnableAllCall(addr byte) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.Bus.WriteByteToReg(d.Addr, allCallRegAddr, addr<<1); err != nil {
+		return err
+	}
+	return d.updateMode1(mode1AllCall, 0)
+}
+
+// DisableAllCall stops the controller responding to the LED All Call
+// address.
+func (d *PCA9685) DisableAllCall() error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.updateMode1(0, mode1AllCall)
+}
+
+// subAddrBit returns the MODE1 bit enabling sub-address n.
+func subAddrBit(n int) (byte, error) {
+	if n < 1 || n > 3 {
+		return 0, fmt.Errorf("pca9685: invalid sub-address %v (must be 1-3)", n)
+	}
+	return mode1Sub1 >> uint(n-1), nil
+}
+
+// EnableSubAddr makes the controller respond to the 7-bit address addr as
+// its sub-address n, 1-3, in addition to Addr. Controllers sharing a
+// sub-address can be driven together through it.
+func (d *PCA9685) EnableSubAddr(n int, addr byte) error {
+	bit, err := subAddrBit(n)
+	if err != nil {
+		return err
+	}
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.Bus.WriteByteToReg(d.Addr, subAddr1RegAddr+byte(n-1), addr<<1); err != nil {
+		return err
+	}
+	return d.updateMode1(bit, 0)
+}
+
+// DisableSubAddr stops the controller responding to its sub-address n.
+func (d *PCA9685) DisableSubAddr(n int) error {
+	bit, err := subAddrBit(n)
+	if err != nil {
+		return err
+	}
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.updateMode1(0, bit)
+}
+
+// pwmRegs encodes on and off times, 0-4095, as ON_L, ON_H, OFF_L, OFF_H.
+func pwmRegs(onTime, offTime int) ([]byte, error) {
+	if onTime < 0 || onTime >= pwmControlPoints || offTime < 0 || offTime >= pwmControlPoints {
+		return nil, fmt.Errorf("pca9685: on/off time %v/%v is out of bounds (must be 0-4095)", onTime, offTime)
+	}
+	return []byte{byte(onTime & 0xFF), byte(onTime >> 8), byte(offTime & 0xFF), byte(offTime >> 8)}, nil
+}
+
+func checkChannel(channel int) error {
+	if channel < 0 || channel >= channels {
+		return fmt.Errorf("pca9685: invalid channel %v (must be 0-15)", channel)
+	}
+	return nil
+}
+
+// writePwm writes the ON and OFF time registers of the channels starting
+// at channel at once, using register auto increment.
+func (d *PCA9685) writePwm(channel int, regs []byte) error {
+	onTimeLowReg := byte(pwm0OnLowReg + (4 * channel))
+	if err := d.Bus.WriteToReg(d.Addr, onTimeLowReg, regs); err != nil {
+		return err
+	}
+	glog.V(2).Infof("pca9685: writing %#02x to CHAN%v_ON_L reg [reg: %#02x] onwards", regs, channel, onTimeLowReg)
+	return nil
+}
+
 // SetPwm sets the ON and OFF time registers for pwm signal shaping.
 // channel: 0-15
 // onTime/offTime: 0-4095
 func (d *PCA9685) SetPwm(channel, onTime, offTime int) error {
+	return d.SetPwms(channel, Pwm{onTime, offTime})
+}
+
+// SetPwms sets the ON and OFF time registers of len(pwms) consecutive
+// channels starting at channel in a single transfer.
+func (d *PCA9685) SetPwms(channel int, pwms ...Pwm) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	if channel+len(pwms) > channels {
+		return fmt.Errorf("pca9685: %v channels from channel %v is out of bounds", len(pwms), channel)
+	}
 	if err := d.setup(); err != nil {
 		return err
 	}
 
-	onTimeLowReg := byte(pwm0OnLowReg + (4 * channel))
+	regs := make([]byte, 0, 4*len(pwms))
+	for _, pwm := range pwms {
+		r, err := pwmRegs(pwm.On, pwm.Off)
+		if err != nil {
+			return err
+		}
+		regs = append(regs, r...)
+	}
 
-	onTimeLow := byte(onTime & 0xFF)
-	onTimeHigh := byte(onTime >> 8)
-	offTimeLow := byte(offTime & 0xFF)
-	offTimeHigh := byte(offTime >> 8)
+	return d.writePwm(channel, regs)
+}
 
-	if err := d.Bus.WriteByteToReg(d.Addr, onTimeLowReg, onTimeLow); err != nil {
+// SetAllPwm sets the ON and OFF times of all the channels at once.
+func (d *PCA9685) SetAllPwm(onTime, offTime int) error {
+	if err := d.setup(); err != nil {
 		return err
 	}
 
-	glog.V(2).Infof("pca9685: writing on-time low [%#02x] to CHAN%v_ON_L reg [reg: %#02x]", onTimeLow, channel, onTimeLowReg)
-
-	onTimeHighReg := onTimeLowReg + 1
-	if err := d.Bus.WriteByteToReg(d.Addr, onTimeHighReg, onTimeHigh); err != nil {
+	regs, err := pwmRegs(onTime, offTime)
+	if err != nil {
+		return err
+	}
+	if err := d.Bus.WriteToReg(d.Addr, allPwmOnLowReg, regs); err != nil {
 		return err
 	}
-	glog.V(2).Infof("pca9685: writing on-time high [%#02x] to CHAN%v_ON_H reg [reg: %#02x]", onTimeHigh, channel, onTimeHighReg)
+	glog.V(2).Infof("pca9685: writing %#02x to ALL_LED_ON_L reg [reg: %#02x] onwards", regs, allPwmOnLowReg)
 
-	offTimeLowReg := onTimeHighReg + 1
-	if err := d.Bus.WriteByteToReg(d.Addr, offTimeLowReg, offTimeLow); err != nil {
+	return nil
+}
+
+// FullOn turns channel fully on, without any pwm.
+func (d *PCA9685) FullOn(channel int) error {
+	if err := checkChannel(channel); err != nil {
 		return err
 	}
-	glog.V(2).Infof("pca9685: writing off-time low [%#02x] to CHAN%v_OFF_L reg [reg: %#02x]", offTimeLow, channel, offTimeLowReg)
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	return d.writePwm(channel, []byte{0, fullBit, 0, 0})
+}
 
-	offTimeHighReg := offTimeLowReg + 1
-	if err := d.Bus.WriteByteToReg(d.Addr, offTimeHighReg, offTimeHigh); err != nil {
+// FullOff turns channel fully off, without any pwm.
+func (d *PCA9685) FullOff(channel int) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	if err := d.setup(); err != nil {
 		return err
 	}
-	glog.V(2).Infof("pca9685: writing off-time high [%#02x] to CHAN%v_OFF_H reg [reg: %#02x]", offTimeHigh, channel, offTimeHighReg)
 
-	return nil
+	return d.writePwm(channel, []byte{0, 0, 0, fullBit})
 }
 
-type pwmChannel struct {
+// Channel is a channel of the controller. It implements embd.PWMPin.
+type Channel struct {
 	d *PCA9685
 
 	channel int
+	id      string
+	drv     embd.GPIODriver
+}
+
+// Channel returns channel (0-15) of the controller.
+func (d *PCA9685) Channel(channel int) *Channel {
+	return &Channel{d: d, channel: channel, id: channelID(channel)}
+}
+
+// channelID returns the id of channel, as in the pin map of the driver.
+func channelID(channel int) string {
+	return "LED" + strconv.Itoa(channel)
+}
+
+// ServoChannel returns channel (0-15) of the controller, for servo control.
+func (d *PCA9685) ServoChannel(channel int) *Channel {
+	return d.Channel(channel)
+}
+
+// AnalogChannel returns channel (0-15) of the controller, for analog writes.
+func (d *PCA9685) AnalogChannel(channel int) *Channel {
+	return d.Channel(channel)
 }
 
-func (p *pwmChannel) SetMicroseconds(us int) error {
-	return p.d.setMicroseconds(p.channel, us)
+// N returns the id of the channel, LED0 to LED15.
+func (p *Channel) N() string {
+	return p.id
+}
+
+// SetPeriod sets the period of all the channels of the controller.
+func (p *Channel) SetPeriod(ns int) error {
+	return p.d.SetPeriod(ns)
+}
+
+// SetDuty sets the duty of the channel in ns. The channel is fully off at 0
+// and fully on at the period or above.
+func (p *Channel) SetDuty(ns int) error {
+	if ns < 0 {
+		return fmt.Errorf("pca9685: pwm duty %v for channel %v is out of bounds", ns, p.id)
+	}
+	period, err := p.d.Period()
+	if err != nil {
+		return err
+	}
+	switch {
+	case ns == 0:
+		return p.FullOff()
+	case ns >= period:
+		return p.FullOn()
+	}
+	offTime := int(int64(ns) * pwmControlPoints / int64(period))
+	return p.d.SetPwm(p.channel, 0, offTime)
+}
+
+// SetPolarity sets the polarity of all the channels of the controller.
+func (p *Channel) SetPolarity(pol embd.Polarity) error {
+	return p.d.SetPolarity(pol)
+}
+
+// SetMicroseconds is a convinience method which allows easy servo control.
+func (p *Channel) SetMicroseconds(us int) error {
+	return p.SetDuty(us * 1000)
 }
 
 // SetAnalog is a convinience method which allows easy manipulation of the PWM
 // based on a (0-255) range value.
-func (p *pwmChannel) SetAnalog(value byte) error {
+func (p *Channel) SetAnalog(value byte) error {
 	offTime := util.Map(int64(value), 0, 255, 0, pwmControlPoints-1)
 	return p.d.SetPwm(p.channel, 0, int(offTime))
 }
 
-func (d *PCA9685) ServoChannel(channel int) *pwmChannel {
-	return &pwmChannel{d: d, channel: channel}
+// FullOn turns the channel fully on.
+func (p *Channel) FullOn() error {
+	return p.d.FullOn(p.channel)
 }
 
-func (d *PCA9685) AnalogChannel(channel int) *pwmChannel {
-	return &pwmChannel{d: d, channel: channel}
+// FullOff turns the channel fully off.
+func (p *Channel) FullOff() error {
+	return p.d.FullOff(p.channel)
 }
 
-// SetMicroseconds is a convinience method which allows easy servo control.
-func (d *PCA9685) setMicroseconds(channel, us int) error {
-	offTime := us * d.Freq * pwmControlPoints / 1000000
-	return d.SetPwm(channel, 0, offTime)
+// Close turns the channel off and releases it.
+func (p *Channel) Close() error {
+	if p.drv != nil {
+		if err := p.drv.Unregister(p.id); err != nil {
+			return err
+		}
+	}
+	return p.FullOff()
+}
+
+var pinMap embd.PinMap
+
+func init() {
+	for n := 0; n < channels; n++ {
+		pinMap = append(pinMap, &embd.PinDesc{
+			ID:             channelID(n),
+			Aliases:        []string{strconv.Itoa(n)},
+			Caps:           embd.CapPWM,
+			DigitalLogical: n,
+		})
+	}
+}
+
+// GPIODriver returns a GPIO driver providing the channels of the
+// controller as pwm pins, named LED0 to LED15, or 0 to 15.
+func (d *PCA9685) GPIODriver() embd.GPIODriver {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.drv == nil {
+		d.drv = embd.NewGPIODriver(pinMap, nil, nil, d.newPWMPin)
+	}
+	return d.drv
+}
+
+func (d *PCA9685) newPWMPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.PWMPin {
+	return &Channel{d: d, channel: pd.DigitalLogical, id: pd.ID, drv: drv}
 }
 
 // Close stops the controller and resets mode and pwm controller registers.
@@ -175,22 +614,28 @@ func (d *PCA9685) Close() error {
 		return err
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if err := d.sleep(); err != nil {
 		return err
 	}
 
 	glog.V(1).Infof("pca9685: reset request received")
 
-	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, 0x00); err != nil {
+	// Keep auto increment to clear the pwm control registers at once.
+	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, mode1AI|mode1Sleep); err != nil {
 		return err
 	}
 
 	glog.V(1).Infof("pca9685: cleaning up all PWM control registers")
 
-	for regAddr := 0x06; regAddr <= 0x45; regAddr++ {
-		if err := d.Bus.WriteByteToReg(d.Addr, byte(regAddr), 0x00); err != nil {
-			return err
-		}
+	if err := d.Bus.WriteToReg(d.Addr, pwm0OnLowReg, make([]byte, 4*channels)); err != nil {
+		return err
+	}
+
+	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, 0x00); err != nil {
+		return err
 	}
 
 	if glog.V(1) {
@@ -198,6 +643,8 @@ func (d *PCA9685) Close() error {
 		glog.Infof("pca9685: controller reset")
 	}
 
+	d.initialized = false
+
 	return nil
 }
 
@@ -208,7 +655,7 @@ func (d *PCA9685) sleep() error {
 	if err != nil {
 		return err
 	}
-	sleepmode := (mode1Reg & 0x7F) | 0x10
+	sleepmode := (mode1Reg & 0x7F) | mode1Sleep
 	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, sleepmode); err != nil {
 		return err
 	}
@@ -226,6 +673,9 @@ func (d *PCA9685) Sleep() error {
 		return err
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	return d.sleep()
 }
 
@@ -236,8 +686,8 @@ func (d *PCA9685) wake() error {
 	if err != nil {
 		return err
 	}
-	wakeMode := mode1Reg & 0xEF
-	if (mode1Reg & 0x80) == 0x80 {
+	wakeMode := mode1Reg &^ mode1Sleep
+	if (mode1Reg & mode1Restart) == mode1Restart {
 		if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, wakeMode); err != nil {
 			return err
 		}
@@ -246,7 +696,7 @@ func (d *PCA9685) wake() error {
 		time.Sleep(500 * time.Microsecond)
 	}
 
-	restartOpCode := wakeMode | 0x80
+	restartOpCode := wakeMode | mode1Restart
 	if err := d.Bus.WriteByteToReg(d.Addr, mode1RegAddr, restartOpCode); err != nil {
 		return err
 	}
@@ -261,5 +711,8 @@ func (d *PCA9685) Wake() error {
 		return err
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	return d.wake()
 }
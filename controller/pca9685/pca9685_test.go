@@ -0,0 +1,356 @@
+package pca9685
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+const testAddr = 0x40
+
+// fakeBus emulates the registers of a PCA9685.
+type fakeBus struct {
+	sim.I2CBus
+
+	mu     sync.Mutex
+	regs   [256]byte
+	writes int // Number of bus transactions writing registers.
+}
+
+func newFakeBus() *fakeBus {
+	b := &fakeBus{}
+	b.regs[mode1RegAddr] = mode1Sleep | mode1AllCall
+	b.regs[mode2RegAddr] = 0x04
+	b.regs[allCallRegAddr] = DefaultAllCallAddr << 1
+	b.regs[preScaleRegAddr] = 0x1E
+	return b
+}
+
+func (b *fakeBus) write(reg, value byte) error {
+	if reg == preScaleRegAddr && b.regs[mode1RegAddr]&mode1Sleep == 0 {
+		return errors.New("prescale written while awake")
+	}
+	if reg >= allPwmOnLowReg && reg < preScaleRegAddr {
+		for r := pwm0OnLowReg + int(reg-allPwmOnLowReg); r < allPwmOnLowReg-2; r += 4 {
+			b.regs[r] = value
+		}
+		return nil
+	}
+	b.regs[reg] = value
+	return nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	if addr != testAddr {
+		return errors.New("bad write")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.writes++
+	return b.write(reg, value)
+}
+
+func (b *fakeBus) WriteToReg(addr, reg byte, value []byte) error {
+	if addr != testAddr {
+		return errors.New("bad write")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(value) > 1 && b.regs[mode1RegAddr]&mode1AI == 0 {
+		return errors.New("auto increment disabled")
+	}
+	b.writes++
+	for i, v := range value {
+		if err := b.write(reg+byte(i), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *fakeBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	if addr != testAddr {
+		return 0, errors.New("bad read")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.regs[reg], nil
+}
+
+// pwm returns the on and off time registers of channel.
+func (b *fakeBus) pwm(channel int) (on, off uint16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r := pwm0OnLowReg + 4*channel
+	return uint16(b.regs[r]) | uint16(b.regs[r+1])<<8, uint16(b.regs[r+2]) | uint16(b.regs[r+3])<<8
+}
+
+var _ embd.PWMPin = (*Channel)(nil)
+
+func TestSetup(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	d.Freq = 50
+	if err := d.SetPwm(0, 0, 100); err != nil {
+		t.Fatal(err)
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1&(mode1AI|mode1Sleep) != mode1AI {
+		t.Errorf("MODE1: got %#02x, want auto increment and awake", mode1)
+	}
+	if got := bus.regs[preScaleRegAddr]; got != 121 {
+		t.Errorf("PRE_SCALE: got %v, want 121", got)
+	}
+	period, _ := d.Period()
+	if period != 19988480 {
+		t.Errorf("Period: got %v, want 19988480", period)
+	}
+}
+
+func TestSetPwms(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	if err := d.SetPwm(15, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := bus.writes
+	if err := d.SetPwms(2, Pwm{0, 1000}, Pwm{100, 4095}, Pwm{4095, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if n := bus.writes - writes; n != 1 {
+		t.Errorf("SetPwms: got %v writes, want 1", n)
+	}
+	for i, want := range []Pwm{{0, 1000}, {100, 4095}, {4095, 0}} {
+		if on, off := bus.pwm(2 + i); int(on) != want.On || int(off) != want.Off {
+			t.Errorf("Channel %v: got %v/%v, want %v/%v", 2+i, on, off, want.On, want.Off)
+		}
+	}
+
+	if err := d.SetPwms(14, Pwm{}, Pwm{}, Pwm{}); err == nil {
+		t.Error("SetPwms past channel 15: got no error")
+	}
+	if err := d.SetPwm(0, 0, 4096); err == nil {
+		t.Error("SetPwm with off time 4096: got no error")
+	}
+	if err := d.SetPwm(16, 0, 0); err == nil {
+		t.Error("SetPwm on channel 16: got no error")
+	}
+}
+
+func TestSetAllPwm(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	if err := d.SetAllPwm(10, 2000); err != nil {
+		t.Fatal(err)
+	}
+	for ch := 0; ch < channels; ch++ {
+		if on, off := bus.pwm(ch); on != 10 || off != 2000 {
+			t.Errorf("Channel %v: got %v/%v, want 10/2000", ch, on, off)
+		}
+	}
+}
+
+func TestChannelDuty(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	d.Freq = 50
+	p := d.Channel(3)
+	if p.N() != "LED3" {
+		t.Errorf("N: got %v, want LED3", p.N())
+	}
+
+	period, err := d.Period()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ns      int
+		on, off uint16
+	}{
+		{period / 4, 0, 1024},
+		{0, 0, fullBit << 8},
+		{period, fullBit << 8, 0},
+		{2 * period, fullBit << 8, 0},
+	}
+	for _, test := range tests {
+		if err := p.SetDuty(test.ns); err != nil {
+			t.Errorf("SetDuty(%v): got %v", test.ns, err)
+			continue
+		}
+		if on, off := bus.pwm(3); on != test.on || off != test.off {
+			t.Errorf("SetDuty(%v): got %#04x/%#04x, want %#04x/%#04x", test.ns, on, off, test.on, test.off)
+		}
+	}
+
+	if err := p.SetMicroseconds(1500); err != nil {
+		t.Fatal(err)
+	}
+	if _, off := bus.pwm(3); off != 307 {
+		t.Errorf("SetMicroseconds(1500): got off time %v, want 307", off)
+	}
+	if err := p.SetDuty(-1); err == nil {
+		t.Error("SetDuty(-1): got no error")
+	}
+}
+
+func TestSetPeriod(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	p := d.Channel(0)
+	if err := p.SetAnalog(127); err != nil {
+		t.Fatal(err)
+	}
+	on, off := bus.pwm(0)
+
+	if err := p.SetPeriod(1000000); err != nil {
+		t.Fatal(err)
+	}
+	if got := bus.regs[preScaleRegAddr]; got != 5 {
+		t.Errorf("PRE_SCALE: got %v, want 5", got)
+	}
+	if d.Freq != 1000 {
+		t.Errorf("Freq: got %v, want 1000", d.Freq)
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1&mode1Sleep != 0 {
+		t.Errorf("MODE1: got %#02x, want awake", mode1)
+	}
+	if gotOn, gotOff := bus.pwm(0); gotOn != on || gotOff != off {
+		t.Errorf("Duty cycle: got %v/%v, want %v/%v", gotOn, gotOff, on, off)
+	}
+
+	if err := d.SetFreq(2000); err == nil {
+		t.Error("SetFreq(2000): got no error")
+	}
+	if err := d.SetFreq(24); err != nil {
+		t.Errorf("SetFreq(24): got %v", err)
+	}
+	if got := bus.regs[preScaleRegAddr]; got != 253 {
+		t.Errorf("PRE_SCALE: got %v, want 253", got)
+	}
+}
+
+func TestExtClock(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	d.ExtClock = 50000000
+	d.Freq = 1000
+	if err := d.FullOn(0); err != nil {
+		t.Fatal(err)
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1&mode1ExtClk == 0 {
+		t.Errorf("MODE1: got %#02x, want external clock", mode1)
+	}
+	if got := bus.regs[preScaleRegAddr]; got != 11 {
+		t.Errorf("PRE_SCALE: got %v, want 11", got)
+	}
+}
+
+func TestPolarity(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	p := d.Channel(0)
+	if err := p.SetPolarity(embd.Negative); err != nil {
+		t.Fatal(err)
+	}
+	if mode2 := bus.regs[mode2RegAddr]; mode2 != 0x04|mode2Invrt {
+		t.Errorf("MODE2: got %#02x, want %#02x", mode2, 0x04|mode2Invrt)
+	}
+	if err := p.SetPolarity(embd.Positive); err != nil {
+		t.Fatal(err)
+	}
+	if mode2 := bus.regs[mode2RegAddr]; mode2 != 0x04 {
+		t.Errorf("MODE2: got %#02x, want 0x04", mode2)
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	if err := d.EnableSubAddr(2, 0x72); err != nil {
+		t.Fatal(err)
+	}
+	if got := bus.regs[subAddr1RegAddr+1]; got != 0x72<<1 {
+		t.Errorf("SUBADR2: got %#02x, want %#02x", got, 0x72<<1)
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1&0x0F != mode1Sub1>>1|mode1AllCall {
+		t.Errorf("MODE1: got %#02x, want SUB2 and ALLCALL", mode1)
+	}
+	if err := d.DisableAllCall(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.EnableAllCall(0x60); err != nil {
+		t.Fatal(err)
+	}
+	if got := bus.regs[allCallRegAddr]; got != 0x60<<1 {
+		t.Errorf("ALLCALLADR: got %#02x, want %#02x", got, 0x60<<1)
+	}
+	if err := d.DisableSubAddr(2); err != nil {
+		t.Fatal(err)
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1&0x0F != mode1AllCall {
+		t.Errorf("MODE1: got %#02x, want ALLCALL only", mode1)
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1&mode1Sleep != 0 {
+		t.Errorf("MODE1: got %#02x, want awake", mode1)
+	}
+	if err := d.EnableSubAddr(4, 0x10); err == nil {
+		t.Error("EnableSubAddr(4): got no error")
+	}
+}
+
+func TestGPIODriver(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	b := embd.NewBoard(&embd.Descriptor{})
+	if err := b.MountGPIO("PWM0", d.GPIODriver()); err != nil {
+		t.Fatal(err)
+	}
+	p, err := b.NewPWMPin("PWM0_LED5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.N() != "LED5" {
+		t.Errorf("N: got %v, want LED5", p.N())
+	}
+	if err := p.SetAnalog(255); err != nil {
+		t.Fatal(err)
+	}
+	if _, off := bus.pwm(5); off != 4095 {
+		t.Errorf("SetAnalog(255): got off time %v, want 4095", off)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, off := bus.pwm(5); off != fullBit<<8 {
+		t.Errorf("Close: got off time %#04x, want full off", off)
+	}
+	if _, err := b.NewPWMPin("PWM0_5"); err != nil {
+		t.Errorf("NewPWMPin after Close: got %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, testAddr)
+	if err := d.SetAllPwm(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for r := pwm0OnLowReg; r < pwm0OnLowReg+4*channels; r++ {
+		if bus.regs[r] != 0 {
+			t.Fatalf("Close: got reg %#02x = %#02x, want 0", r, bus.regs[r])
+		}
+	}
+	if mode1 := bus.regs[mode1RegAddr]; mode1 != 0 {
+		t.Errorf("MODE1: got %#02x, want 0", mode1)
+	}
+}
@@ -0,0 +1,351 @@
+// Package pcf857x allows interfacing with the PCF8574 (and PCF8574A) 8-bit
+// and PCF8575 16-bit I2C I/O expanders.
+//
+// The pins of these expanders are quasi-bidirectional: a pin set high is
+// only weakly pulled up, so that it can be used as an input. Setting a pin
+// as an input sets it high, and the expander is assumed to be in its
+// power-on state, all pins high, when first used.
+//
+// The pins of the expander implement embd.DigitalPin, and its GPIO driver
+// can be mounted on the board so that they are looked up like host pins:
+//
+//	exp := pcf857x.NewPCF8574(bus, 0x20)
+//	embd.MountGPIO("EXP0", exp.GPIODriver())
+//	pin, err := embd.NewDigitalPin("EXP0_P3")
+//
+// Interrupts are supported when the INT output of the expander is
+// connected to an interrupt capable host pin.
+package pcf857x
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+type watch struct {
+	pin     *digitalPin
+	edge    embd.Edge
+	handler func(embd.DigitalPin)
+}
+
+// PCF857x represents a PCF8574 or PCF8575 I/O expander.
+type PCF857x struct {
+	Bus  embd.I2CBus
+	Addr byte
+
+	// Interrupt is the host pin connected to the INT output of the
+	// expander, or nil if it is not connected.
+	Interrupt embd.DigitalPin
+
+	pins   int
+	pinMap embd.PinMap
+
+	mu     sync.Mutex
+	out    uint16 // Levels of the outputs.
+	inputs uint16 // Pins set as inputs, which are kept high.
+	last   uint16 // Last value read.
+
+	watches  []*watch
+	watching bool
+
+	drv embd.GPIODriver
+}
+
+// NewPCF8574 creates a new PCF8574 interface at the I2C address addr (0x20
+// to 0x27, or 0x38 to 0x3F for the PCF8574A).
+func NewPCF8574(bus embd.I2CBus, addr byte) *PCF857x {
+	return newPCF857x(bus, addr, 8)
+}
+
+// NewPCF8575 creates a new PCF8575 interface at the I2C address addr (0x20
+// to 0x27).
+func NewPCF8575(bus embd.I2CBus, addr byte) *PCF857x {
+	return newPCF857x(bus, addr, 16)
+}
+
+func newPCF857x(bus embd.I2CBus, addr byte, pins int) *PCF857x {
+	d := &PCF857x{
+		Bus:     bus,
+		Addr:    addr,
+		pins:    pins,
+		out:     0xFFFF,
+		watches: make([]*watch, pins),
+	}
+	for n := 0; n < pins; n++ {
+		id := "P" + strconv.Itoa(n)
+		if pins > 8 {
+			id = "P" + strconv.Itoa(n/8) + strconv.Itoa(n%8)
+		}
+		d.pinMap = append(d.pinMap, &embd.PinDesc{
+			ID:             id,
+			Aliases:        []string{strconv.Itoa(n)},
+			Caps:           embd.CapDigital,
+			DigitalLogical: n,
+		})
+	}
+	return d
+}
+
+func (d *PCF857x) mask() uint16 {
+	return uint16(1<<uint(d.pins) - 1)
+}
+
+// read reads the levels of the pins. d.mu must be held.
+func (d *PCF857x) read() (uint16, error) {
+	buf, err := d.Bus.ReadBytes(d.Addr, d.pins/8)
+	if err != nil {
+		return 0, err
+	}
+	value := uint16(buf[0])
+	if d.pins > 8 {
+		value |= uint16(buf[1]) << 8
+	}
+	return value, nil
+}
+
+// write writes the outputs, keeping the inputs high. d.mu must be held.
+func (d *PCF857x) write(out, inputs uint16) error {
+	v := (out | inputs) & d.mask()
+	buf := []byte{byte(v)}
+	if d.pins > 8 {
+		buf = append(buf, byte(v>>8))
+	}
+	if err := d.Bus.WriteBytes(d.Addr, buf); err != nil {
+		return err
+	}
+	d.out, d.inputs = out, inputs
+	return nil
+}
+
+// Read returns the levels of the pins, P0 (or P00) being bit 0.
+func (d *PCF857x) Read() (uint16, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.read()
+}
+
+// Write sets the outputs in mask to the matching bits of value, in a
+// single write. Pins set as inputs are kept high.
+func (d *PCF857x) Write(mask, value uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.write(d.out&^mask|value&mask, d.inputs)
+}
+
+// SetDirection sets the pins in mask as inputs or outputs. An output
+// takes the level last written to it, high by default.
+func (d *PCF857x) SetDirection(mask uint16, dir embd.Direction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inputs := d.inputs &^ mask
+	if dir == embd.In {
+		inputs |= mask
+	}
+	return d.write(d.out, inputs)
+}
+
+// watch registers w for pin n.
+func (d *PCF857x) watch(n int, w *watch) error {
+	if d.Interrupt == nil {
+		return errors.New("pcf857x: no interrupt pin")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.watches[n] != nil {
+		return fmt.Errorf("pcf857x: pin %v is already being watched", n)
+	}
+
+	if !d.watching {
+		if err := d.Interrupt.SetDirection(embd.In); err != nil {
+			return err
+		}
+		if err := d.Interrupt.Watch(embd.EdgeFalling, d.interrupt); err != nil {
+			return err
+		}
+		d.watching = true
+	}
+
+	// Reading clears any pending interrupt, which would otherwise hold
+	// the interrupt output low.
+	last, err := d.read()
+	if err != nil {
+		return err
+	}
+	d.last = last
+	d.watches[n] = w
+
+	return nil
+}
+
+// unwatch stops calling the handler of pin n.
+func (d *PCF857x) unwatch(n int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.watches[n] == nil {
+		return nil
+	}
+	d.watches[n] = nil
+
+	for _, w := range d.watches {
+		if w != nil {
+			return nil
+		}
+	}
+	if d.watching {
+		d.watching = false
+		return d.Interrupt.StopWatching()
+	}
+	return nil
+}
+
+// interrupt handles the interrupts of the expander, calling the handlers
+// of the pins which changed.
+func (d *PCF857x) interrupt(embd.DigitalPin) {
+	d.mu.Lock()
+	value, err := d.read()
+	if err != nil {
+		d.mu.Unlock()
+		glog.Errorf("pcf857x: reading pins on interrupt: %v", err)
+		return
+	}
+	changed := value ^ d.last
+	d.last = value
+
+	var fire []*watch
+	for n, w := range d.watches {
+		if w == nil || changed&(1<<uint(n)) == 0 {
+			continue
+		}
+		v := w.pin.logical(int(value>>uint(n)) & 1)
+		switch {
+		case w.edge == embd.EdgeBoth,
+			w.edge == embd.EdgeRising && v == embd.High,
+			w.edge == embd.EdgeFalling && v == embd.Low:
+			fire = append(fire, w)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, w := range fire {
+		w.handler(w.pin)
+	}
+}
+
+// GPIODriver returns a GPIO driver for the pins of the expander, named P0
+// to P7 on the PCF8574 and P00 to P07, P10 to P17 on the PCF8575, or by
+// their number.
+func (d *PCF857x) GPIODriver() embd.GPIODriver {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.drv == nil {
+		d.drv = embd.NewGPIODriver(d.pinMap, d.newDigitalPin, nil, nil)
+	}
+	return d.drv
+}
+
+// DigitalPin returns the pin designated by key, as GPIODriver().DigitalPin.
+func (d *PCF857x) DigitalPin(key interface{}) (embd.DigitalPin, error) {
+	return d.GPIODriver().DigitalPin(key)
+}
+
+func (d *PCF857x) newDigitalPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.DigitalPin {
+	return &digitalPin{d: d, id: pd.ID, n: pd.DigitalLogical, drv: drv}
+}
+
+type digitalPin struct {
+	d   *PCF857x
+	id  string
+	n   int
+	drv embd.GPIODriver
+
+	mu        sync.Mutex
+	activeLow bool
+}
+
+func (p *digitalPin) mask() uint16 {
+	return 1 << uint(p.n)
+}
+
+// logical converts the level of the pin to its logical value.
+func (p *digitalPin) logical(v int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.activeLow {
+		return v ^ 1
+	}
+	return v
+}
+
+func (p *digitalPin) N() int {
+	return p.n
+}
+
+func (p *digitalPin) Write(val int) error {
+	var v uint16
+	if p.logical(val&1) == embd.High {
+		v = p.mask()
+	}
+	return p.d.Write(p.mask(), v)
+}
+
+func (p *digitalPin) Read() (int, error) {
+	value, err := p.d.Read()
+	if err != nil {
+		return 0, err
+	}
+	return p.logical(int(value>>uint(p.n)) & 1), nil
+}
+
+func (p *digitalPin) TimePulse(state int) (time.Duration, error) {
+	return embd.TimePulseTimeout(p, state, embd.PulseTimeout)
+}
+
+func (p *digitalPin) SetDirection(dir embd.Direction) error {
+	return p.d.SetDirection(p.mask(), dir)
+}
+
+func (p *digitalPin) ActiveLow(b bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.activeLow = b
+	return nil
+}
+
+// PullUp succeeds as the inputs are always weakly pulled up.
+func (p *digitalPin) PullUp() error {
+	return nil
+}
+
+func (p *digitalPin) PullDown() error {
+	return errors.New("pcf857x: pull-down not supported")
+}
+
+func (p *digitalPin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	return p.d.watch(p.n, &watch{pin: p, edge: edge, handler: handler})
+}
+
+func (p *digitalPin) StopWatching() error {
+	return p.d.unwatch(p.n)
+}
+
+func (p *digitalPin) Close() error {
+	if err := p.StopWatching(); err != nil {
+		return err
+	}
+	return p.drv.Unregister(p.id)
+}
@@ -0,0 +1,195 @@
+package pcf857x
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// fakeBus emulates a PCF8575: each pin is low when either its latch or the
+// external device pulls it low. The interrupt output goes low when the
+// pins change, until they are read or written.
+type fakeBus struct {
+	sim.I2CBus
+
+	addr byte
+	n    int
+
+	mu    sync.Mutex
+	latch uint16
+	ext   uint16
+	last  uint16
+	intr  *sim.Pin
+	pend  bool
+}
+
+func newFakeBus(addr byte, n int) *fakeBus {
+	b := &fakeBus{addr: addr, n: n, latch: 0xFFFF, ext: 0xFFFF, intr: sim.NewPin(100)}
+	b.intr.SetPull(sim.PullUp)
+	b.last = b.levels()
+	return b
+}
+
+func (b *fakeBus) levels() uint16 {
+	return b.latch & b.ext
+}
+
+// clearInt clears the interrupt once b.mu is released.
+func (b *fakeBus) clearInt() func() {
+	b.last = b.levels()
+	if !b.pend {
+		return func() {}
+	}
+	b.pend = false
+	return b.intr.Release
+}
+
+// set applies levels to the pins, 0 pulling a pin low.
+func (b *fakeBus) set(ext uint16) {
+	b.mu.Lock()
+	b.ext = ext
+	after := func() {}
+	if b.levels() != b.last && !b.pend {
+		b.pend = true
+		after = func() { b.intr.Drive(embd.Low) }
+	}
+	b.mu.Unlock()
+	after()
+}
+
+func (b *fakeBus) ReadBytes(addr byte, num int) ([]byte, error) {
+	if addr != b.addr || num != b.n/8 {
+		return nil, errors.New("bad read")
+	}
+	b.mu.Lock()
+	v := b.levels()
+	after := b.clearInt()
+	b.mu.Unlock()
+	after()
+	return []byte{byte(v), byte(v >> 8)}[:num], nil
+}
+
+func (b *fakeBus) WriteBytes(addr byte, value []byte) error {
+	if addr != b.addr || len(value) != b.n/8 {
+		return errors.New("bad write")
+	}
+	b.mu.Lock()
+	b.latch = 0xFF00 | uint16(value[0])
+	if len(value) > 1 {
+		b.latch = uint16(value[0]) | uint16(value[1])<<8
+	}
+	after := b.clearInt()
+	b.mu.Unlock()
+	after()
+	return nil
+}
+
+func TestPCF8574Pins(t *testing.T) {
+	bus := newFakeBus(0x38, 8)
+	d := NewPCF8574(bus, 0x38)
+	b := embd.NewBoard(&embd.Descriptor{})
+	if err := b.MountGPIO("EXP0", d.GPIODriver()); err != nil {
+		t.Fatalf("Mounting expander: got %v", err)
+	}
+	defer b.CloseGPIO()
+
+	out, err := b.NewDigitalPin("EXP0_P3")
+	if err != nil {
+		t.Fatalf("Looking up EXP0_P3: got %v", err)
+	}
+	if err := out.SetDirection(embd.Out); err != nil {
+		t.Fatalf("Setting P3 as output: got %v", err)
+	}
+	if err := out.Write(embd.Low); err != nil {
+		t.Fatalf("Writing P3: got %v", err)
+	}
+	if bus.latch != 0xFFF7 {
+		t.Errorf("Writing P3 low: got latch %#04x, want 0xfff7", bus.latch)
+	}
+
+	in, err := b.NewDigitalPin("EXP0_5")
+	if err != nil {
+		t.Fatalf("Looking up EXP0_5: got %v", err)
+	}
+	if err := in.SetDirection(embd.In); err != nil {
+		t.Fatalf("Setting P5 as input: got %v", err)
+	}
+	bus.set(0xFFDF)
+	if v, _ := in.Read(); v != embd.Low {
+		t.Errorf("Reading P5 pulled low: got %v, want %v", v, embd.Low)
+	}
+
+	// An input stays high when written.
+	in.Write(embd.Low)
+	if bus.latch != 0xFFF7 {
+		t.Errorf("Writing input P5 low: got latch %#04x, want 0xfff7", bus.latch)
+	}
+	if _, err := b.NewDigitalPin("EXP0_P8"); err == nil {
+		t.Error("Looking up EXP0_P8 on a PCF8574: got nil error")
+	}
+}
+
+func TestPCF8575Interrupt(t *testing.T) {
+	bus := newFakeBus(0x20, 16)
+	d := NewPCF8575(bus, 0x20)
+	d.Interrupt = bus.intr
+
+	pin, err := d.DigitalPin("P12")
+	if err != nil {
+		t.Fatalf("Looking up P12: got %v", err)
+	}
+	if pin.N() != 10 {
+		t.Errorf("Looking up P12: got pin %v, want 10", pin.N())
+	}
+	pin.SetDirection(embd.In)
+	pin.ActiveLow(true)
+
+	var mu sync.Mutex
+	var calls []int
+	err = pin.Watch(embd.EdgeRising, func(p embd.DigitalPin) {
+		v, _ := p.Read()
+		mu.Lock()
+		calls = append(calls, v)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Watching P12: got %v", err)
+	}
+
+	bus.set(0xFBFF) // P12 pressed.
+	bus.set(0xFFFF)
+	bus.set(0xFFFE) // P00 is not watched.
+	bus.set(0xFBFE)
+
+	mu.Lock()
+	if len(calls) != 2 || calls[0] != embd.High || calls[1] != embd.High {
+		t.Errorf("Pressing P12 twice: got handler calls %v, want [1 1]", calls)
+	}
+	mu.Unlock()
+
+	if err := pin.Close(); err != nil {
+		t.Fatalf("Closing P12: got %v", err)
+	}
+	if d.watching {
+		t.Error("Closing P12: interrupt pin still watched")
+	}
+}
+
+func TestTimePulseTimeout(t *testing.T) {
+	defer func(d time.Duration) { embd.PulseTimeout = d }(embd.PulseTimeout)
+	embd.PulseTimeout = 10 * time.Millisecond
+
+	d := NewPCF8574(newFakeBus(0x20, 8), 0x20)
+	pin, err := d.DigitalPin("P0")
+	if err != nil {
+		t.Fatalf("Looking up P0: got %v", err)
+	}
+	pin.SetDirection(embd.In)
+	if _, err := pin.TimePulse(embd.High); !embd.IsPulseTimeout(err) {
+		t.Fatalf("Measuring pulse on idle P0: got %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,500 @@
+// Package ads1x15 allows interfacing with the ADS1015 (12-bit) and ADS1115
+// (16-bit) 4-channel ADCs through I2C protocol.
+//
+// Inputs are measured single-ended against ground or differentially, with
+// a programmable gain, either one conversion at a time or continuously.
+// The ALERT/RDY output can be configured as a comparator or to signal the
+// end of each conversion.
+//
+// The inputs are also available as embd.AnalogPin, directly or through a
+// GPIODriver which can be mounted on the board.
+package ads1x15
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	conversionReg = 0x00
+	configReg     = 0x01
+	loThreshReg   = 0x02
+	hiThreshReg   = 0x03
+
+	configOS         = 1 << 15 // Start a conversion; set when idle.
+	configMuxShift   = 12
+	configPGAShift   = 9
+	configSingleShot = 1 << 8
+	configDRShift    = 5
+	configCompWindow = 1 << 4
+	configCompPol    = 1 << 3
+	configCompLatch  = 1 << 2
+	configCompQueDis = 0x3
+
+	// DefaultAddr is the address of the convertor with its ADDR pin
+	// connected to ground.
+	DefaultAddr = 0x48
+)
+
+// Input selects the voltage measured by a conversion.
+type Input int
+
+const (
+	// Diff01 to Diff23 are differential inputs, AINx - AINy.
+	Diff01 Input = iota
+	Diff03
+	Diff13
+	Diff23
+
+	// AIN0 to AIN3 are single-ended inputs, measured against ground.
+	AIN0
+	AIN1
+	AIN2
+	AIN3
+)
+
+var inputNames = [...]string{"DIFF01", "DIFF03", "DIFF13", "DIFF23", "AIN0", "AIN1", "AIN2", "AIN3"}
+
+func (in Input) String() string {
+	if in < 0 || int(in) >= len(inputNames) {
+		return "Input(" + strconv.Itoa(int(in)) + ")"
+	}
+	return inputNames[in]
+}
+
+// Differential returns the differential input AINp - AINn. Only the pairs
+// 0-1, 0-3, 1-3 and 2-3 are supported by the multiplexer.
+func Differential(p, n int) (Input, error) {
+	switch {
+	case p == 0 && n == 1:
+		return Diff01, nil
+	case p == 0 && n == 3:
+		return Diff03, nil
+	case p == 1 && n == 3:
+		return Diff13, nil
+	case p == 2 && n == 3:
+		return Diff23, nil
+	}
+	return 0, fmt.Errorf("ads1x15: no differential input AIN%v - AIN%v", p, n)
+}
+
+// Gain sets the full-scale range of the programmable gain amplifier. The
+// inputs must however stay between ground and the supply voltage.
+type Gain int
+
+const (
+	// Gain2_3 has a full-scale range of ±6.144V.
+	Gain2_3 Gain = iota
+	// Gain1 has a full-scale range of ±4.096V.
+	Gain1
+	// Gain2 has a full-scale range of ±2.048V, the power-on default.
+	Gain2
+	// Gain4 has a full-scale range of ±1.024V.
+	Gain4
+	// Gain8 has a full-scale range of ±0.512V.
+	Gain8
+	// Gain16 has a full-scale range of ±0.256V.
+	Gain16
+)
+
+var fullScales = [...]float64{6.144, 4.096, 2.048, 1.024, 0.512, 0.256}
+
+// FullScale returns the full-scale range of the gain in volts.
+func (g Gain) FullScale() float64 {
+	if g < 0 || int(g) >= len(fullScales) {
+		return 0
+	}
+	return fullScales[g]
+}
+
+type model struct {
+	name  string
+	shift uint  // Unused low bits of the conversion register.
+	rates []int // Data rates in SPS, by DR code.
+	rate  int   // Default data rate.
+}
+
+var (
+	ads1015 = &model{
+		name:  "ads1015",
+		shift: 4,
+		rates: []int{128, 250, 490, 920, 1600, 2400, 3300},
+		rate:  1600,
+	}
+	ads1115 = &model{
+		name:  "ads1115",
+		rates: []int{8, 16, 32, 64, 128, 250, 475, 860},
+		rate:  128,
+	}
+)
+
+// Comparator configures the ALERT/RDY output as a comparator.
+type Comparator struct {
+	// Low and High are the thresholds, in the same unit as Read.
+	Low, High int
+
+	// Window asserts ALERT/RDY when the conversion is outside [Low,
+	// High]. Otherwise it is asserted above High, until the conversion
+	// falls below Low.
+	Window bool
+
+	// ActiveHigh drives ALERT/RDY high when asserted instead of low.
+	ActiveHigh bool
+
+	// Latching keeps ALERT/RDY asserted until the conversion is read.
+	Latching bool
+
+	// Queue is the number of successive conversions, 1, 2 or 4, beyond
+	// the thresholds needed to assert ALERT/RDY. 0 means 1.
+	Queue int
+}
+
+// ADS1x15 represents an ADS1015 or ADS1115 convertor.
+type ADS1x15 struct {
+	Bus  embd.I2CBus
+	Addr byte
+
+	// Gain sets the full-scale range of the conversions. The zero value,
+	// Gain2_3, measures up to 6.144V.
+	Gain Gain
+
+	// DataRate is the number of samples per second, one of the rates
+	// supported by the model; the default rate is used if 0.
+	DataRate int
+
+	model *model
+
+	mu         sync.Mutex
+	comp       uint16 // Comparator bits of the config register.
+	continuous bool
+	contInput  Input
+
+	drv embd.GPIODriver
+}
+
+// NewADS1015 creates a new ADS1015 interface.
+func NewADS1015(bus embd.I2CBus, addr byte) *ADS1x15 {
+	return &ADS1x15{Bus: bus, Addr: addr, model: ads1015, comp: configCompQueDis}
+}
+
+// NewADS1115 creates a new ADS1115 interface.
+func NewADS1115(bus embd.I2CBus, addr byte) *ADS1x15 {
+	return &ADS1x15{Bus: bus, Addr: addr, model: ads1115, comp: configCompQueDis}
+}
+
+func (d *ADS1x15) rate() (int, uint16, error) {
+	rate := d.DataRate
+	if rate == 0 {
+		rate = d.model.rate
+	}
+	for code, r := range d.model.rates {
+		if r == rate {
+			return rate, uint16(code), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%v: unsupported data rate %v, want one of %v", d.model.name, rate, d.model.rates)
+}
+
+// config returns the config register for a conversion of in.
+func (d *ADS1x15) config(in Input, single bool) (uint16, int, error) {
+	if in < Diff01 || in > AIN3 {
+		return 0, 0, fmt.Errorf("%v: invalid input %v", d.model.name, in)
+	}
+	if d.Gain.FullScale() == 0 {
+		return 0, 0, fmt.Errorf("%v: invalid gain %v", d.model.name, d.Gain)
+	}
+	rate, dr, err := d.rate()
+	if err != nil {
+		return 0, 0, err
+	}
+	config := uint16(in)<<configMuxShift | uint16(d.Gain)<<configPGAShift | dr<<configDRShift | d.comp
+	if single {
+		config |= configOS | configSingleShot
+	}
+	return config, rate, nil
+}
+
+// value converts a register value to a conversion result.
+func (d *ADS1x15) value(reg uint16) int {
+	return int(int16(reg)) >> d.model.shift
+}
+
+// Read converts in and returns the result, from -2048 to 2047 on the
+// ADS1015 and -32768 to 32767 on the ADS1115, full scale being set by Gain.
+// When converting in continuously, the last conversion is returned.
+func (d *ADS1x15) Read(in Input) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.continuous {
+		if in != d.contInput {
+			return 0, fmt.Errorf("%v: converting %v continuously", d.model.name, d.contInput)
+		}
+		return d.readConversion()
+	}
+
+	config, rate, err := d.config(in, true)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.Bus.WriteWordToReg(d.Addr, configReg, config); err != nil {
+		return 0, err
+	}
+	glog.V(2).Infof("%v: started conversion with config %#04x", d.model.name, config)
+
+	// Allow for the 10% tolerance of the internal oscillator.
+	period := time.Second / time.Duration(rate)
+	time.Sleep(period + period/10)
+	deadline := time.Now().Add(10 * period)
+	for {
+		config, err := d.Bus.ReadWordFromReg(d.Addr, configReg)
+		if err != nil {
+			return 0, err
+		}
+		if config&configOS != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("%v: conversion timed out", d.model.name)
+		}
+		time.Sleep(period / 10)
+	}
+
+	return d.readConversion()
+}
+
+func (d *ADS1x15) readConversion() (int, error) {
+	reg, err := d.Bus.ReadWordFromReg(d.Addr, conversionReg)
+	if err != nil {
+		return 0, err
+	}
+	return d.value(reg), nil
+}
+
+// Voltage converts in and returns the result in volts.
+func (d *ADS1x15) Voltage(in Input) (float64, error) {
+	v, err := d.Read(in)
+	if err != nil {
+		return 0, err
+	}
+	return d.ToVoltage(v), nil
+}
+
+// ToVoltage converts a result of Read to volts, according to Gain.
+func (d *ADS1x15) ToVoltage(v int) float64 {
+	max := 1 << (15 - d.model.shift)
+	return float64(v) * d.Gain.FullScale() / float64(max)
+}
+
+// FromVoltage converts volts to a result of Read, according to Gain.
+func (d *ADS1x15) FromVoltage(volts float64) int {
+	max := 1 << (15 - d.model.shift)
+	v := int(volts / d.Gain.FullScale() * float64(max))
+	switch {
+	case v >= max:
+		return max - 1
+	case v < -max:
+		return -max
+	}
+	return v
+}
+
+// StartContinuous starts converting in continuously at DataRate, until
+// Stop is called. Read then returns the last conversion.
+func (d *ADS1x15) StartContinuous(in Input) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	config, _, err := d.config(in, false)
+	if err != nil {
+		return err
+	}
+	if err := d.Bus.WriteWordToReg(d.Addr, configReg, config); err != nil {
+		return err
+	}
+	d.continuous = true
+	d.contInput = in
+
+	glog.V(1).Infof("%v: converting %v continuously", d.model.name, in)
+
+	return nil
+}
+
+// Stop stops the continuous conversions, powering the convertor down.
+func (d *ADS1x15) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.continuous {
+		return nil
+	}
+	config, _, err := d.config(d.contInput, false)
+	if err != nil {
+		return err
+	}
+	if err := d.Bus.WriteWordToReg(d.Addr, configReg, config|configSingleShot); err != nil {
+		return err
+	}
+	d.continuous = false
+	return nil
+}
+
+// writeComparator writes the thresholds and the comparator bits, which
+// take effect with the next conversion. d.mu must be held.
+func (d *ADS1x15) writeComparator(lo, hi, comp uint16) error {
+	if err := d.Bus.WriteWordToReg(d.Addr, loThreshReg, lo); err != nil {
+		return err
+	}
+	if err := d.Bus.WriteWordToReg(d.Addr, hiThreshReg, hi); err != nil {
+		return err
+	}
+	d.comp = comp
+	return d.restart()
+}
+
+// restart applies the comparator bits to the continuous conversions.
+// d.mu must be held.
+func (d *ADS1x15) restart() error {
+	if !d.continuous {
+		return nil
+	}
+	config, _, err := d.config(d.contInput, false)
+	if err != nil {
+		return err
+	}
+	return d.Bus.WriteWordToReg(d.Addr, configReg, config)
+}
+
+// SetComparator configures the ALERT/RDY output as a comparator.
+func (d *ADS1x15) SetComparator(c Comparator) error {
+	var comp uint16
+	switch c.Queue {
+	case 0, 1:
+	case 2:
+		comp = 1
+	case 4:
+		comp = 2
+	default:
+		return fmt.Errorf("%v: invalid comparator queue %v, want 1, 2 or 4", d.model.name, c.Queue)
+	}
+	if c.Window {
+		comp |= configCompWindow
+	}
+	if c.ActiveHigh {
+		comp |= configCompPol
+	}
+	if c.Latching {
+		comp |= configCompLatch
+	}
+	if c.Low > c.High {
+		return fmt.Errorf("%v: comparator low threshold %v above high threshold %v", d.model.name, c.Low, c.High)
+	}
+
+	max := 1 << (15 - d.model.shift)
+	for _, v := range []int{c.Low, c.High} {
+		if v < -max || v >= max {
+			return fmt.Errorf("%v: comparator threshold %v out of range", d.model.name, v)
+		}
+	}
+	lo := uint16(int16(c.Low << d.model.shift))
+	hi := uint16(int16(c.High << d.model.shift))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.writeComparator(lo, hi, comp)
+}
+
+// SetConversionReady configures the ALERT/RDY output to pulse low at the
+// end of each conversion.
+func (d *ADS1x15) SetConversionReady() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The MSB of the high threshold set and that of the low threshold
+	// cleared select the conversion ready function.
+	return d.writeComparator(0x0000, 0x8000, 0)
+}
+
+// DisableComparator leaves the ALERT/RDY output high impedance.
+func (d *ADS1x15) DisableComparator() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.comp = configCompQueDis
+	return d.restart()
+}
+
+// Pin is an input of the convertor as an embd.AnalogPin.
+type Pin struct {
+	d   *ADS1x15
+	in  Input
+	id  string
+	drv embd.GPIODriver
+}
+
+// Pin returns in as an embd.AnalogPin.
+func (d *ADS1x15) Pin(in Input) *Pin {
+	return &Pin{d: d, in: in}
+}
+
+// N returns the input of the pin.
+func (p *Pin) N() int {
+	return int(p.in)
+}
+
+// Read converts the input of the pin, as ADS1x15.Read.
+func (p *Pin) Read() (int, error) {
+	return p.d.Read(p.in)
+}
+
+// Voltage converts the input of the pin and returns the result in volts.
+func (p *Pin) Voltage() (float64, error) {
+	return p.d.Voltage(p.in)
+}
+
+// Close releases the pin.
+func (p *Pin) Close() error {
+	if p.drv == nil {
+		return nil
+	}
+	return p.drv.Unregister(p.id)
+}
+
+var pinMap embd.PinMap
+
+func init() {
+	for in := Diff01; in <= AIN3; in++ {
+		pd := &embd.PinDesc{
+			ID:            in.String(),
+			Caps:          embd.CapAnalog,
+			AnalogLogical: int(in),
+		}
+		if in >= AIN0 {
+			pd.Aliases = []string{strconv.Itoa(int(in - AIN0)), "A" + strconv.Itoa(int(in-AIN0))}
+		}
+		pinMap = append(pinMap, pd)
+	}
+}
+
+// GPIODriver returns a GPIO driver providing the inputs of the convertor
+// as analog pins, named AIN0 to AIN3 (or 0 to 3, A0 to A3) and DIFF01,
+// DIFF03, DIFF13 and DIFF23.
+func (d *ADS1x15) GPIODriver() embd.GPIODriver {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.drv == nil {
+		d.drv = embd.NewGPIODriver(pinMap, nil, d.newAnalogPin, nil)
+	}
+	return d.drv
+}
+
+func (d *ADS1x15) newAnalogPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.AnalogPin {
+	return &Pin{d: d, in: Input(pd.AnalogLogical), id: pd.ID, drv: drv}
+}
@@ -0,0 +1,203 @@
+package ads1x15
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// fakeBus emulates an ADS1x15 converting the voltages applied to its
+// inputs instantly.
+type fakeBus struct {
+	sim.I2CBus
+
+	shift uint
+
+	mu    sync.Mutex
+	regs  [4]uint16
+	volts [4]float64
+}
+
+func (b *fakeBus) convert() {
+	config := b.regs[configReg]
+	mux := config >> configMuxShift & 0x7
+	var v float64
+	switch Input(mux) {
+	case Diff01:
+		v = b.volts[0] - b.volts[1]
+	case Diff03:
+		v = b.volts[0] - b.volts[3]
+	case Diff13:
+		v = b.volts[1] - b.volts[3]
+	case Diff23:
+		v = b.volts[2] - b.volts[3]
+	default:
+		v = b.volts[mux-4]
+	}
+	fs := Gain(config >> configPGAShift & 0x7).FullScale()
+	code := math.Floor(v / fs * 32768)
+	code = math.Max(math.Min(code, 32767), -32768)
+	b.regs[conversionReg] = uint16(int16(code)) >> b.shift << b.shift
+}
+
+func (b *fakeBus) WriteWordToReg(addr, reg byte, value uint16) error {
+	if addr != DefaultAddr || reg > hiThreshReg {
+		return errors.New("bad write")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.regs[reg] = value
+	if reg == configReg && (value&configOS != 0 || value&configSingleShot == 0) {
+		b.convert()
+		// The conversion is over.
+		b.regs[configReg] |= configOS
+	}
+	return nil
+}
+
+func (b *fakeBus) ReadWordFromReg(addr, reg byte) (uint16, error) {
+	if addr != DefaultAddr || reg > hiThreshReg {
+		return 0, errors.New("bad read")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.regs[reg], nil
+}
+
+func TestSingleShot(t *testing.T) {
+	tests := []struct {
+		new   func(embd.I2CBus, byte) *ADS1x15
+		shift uint
+		gain  Gain
+		in    Input
+		want  int
+		volts float64
+	}{
+		{NewADS1115, 0, Gain1, AIN1, 8000, 1.0},
+		{NewADS1115, 0, Gain16, AIN1, 32767, 0.256},
+		{NewADS1115, 0, Gain2, Diff13, -32000, -2.0},
+		{NewADS1015, 4, Gain2_3, AIN3, 1000, 3.0},
+		{NewADS1015, 4, Gain4, Diff01, -1000, -0.5},
+	}
+	for _, test := range tests {
+		bus := &fakeBus{shift: test.shift, volts: [4]float64{0.5, 1.0, 2.0, 3.0}}
+		d := test.new(bus, DefaultAddr)
+		d.Gain = test.gain
+		v, err := d.Read(test.in)
+		if err != nil {
+			t.Errorf("Reading %v: got %v", test.in, err)
+			continue
+		}
+		if v != test.want {
+			t.Errorf("Reading %v with gain %v: got %v, want %v", test.in, test.gain, v, test.want)
+		}
+		volts, _ := d.Voltage(test.in)
+		if math.Abs(volts-test.volts) > 0.01 {
+			t.Errorf("Reading %v with gain %v: got %vV, want %vV", test.in, test.gain, volts, test.volts)
+		}
+		if config := bus.regs[configReg]; config&configSingleShot == 0 {
+			t.Errorf("Reading %v: got config %#04x, want single-shot", test.in, config)
+		}
+	}
+}
+
+func TestDataRate(t *testing.T) {
+	bus := &fakeBus{}
+	d := NewADS1115(bus, DefaultAddr)
+	d.DataRate = 1600
+	if _, err := d.Read(AIN0); err == nil {
+		t.Error("Reading at 1600 SPS on an ads1115: got nil error")
+	}
+	d.DataRate = 860
+	if _, err := d.Read(AIN0); err != nil {
+		t.Fatalf("Reading at 860 SPS on an ads1115: got %v", err)
+	}
+	if dr := bus.regs[configReg] >> configDRShift & 0x7; dr != 7 {
+		t.Errorf("Reading at 860 SPS on an ads1115: got DR %v, want 7", dr)
+	}
+}
+
+func TestContinuous(t *testing.T) {
+	bus := &fakeBus{shift: 4, volts: [4]float64{1, 2, 3, 4}}
+	d := NewADS1015(bus, DefaultAddr)
+	if err := d.StartContinuous(AIN2); err != nil {
+		t.Fatalf("Starting continuous conversions: got %v", err)
+	}
+	if v, err := d.Read(AIN2); err != nil || v != 1000 {
+		t.Errorf("Reading AIN2 continuously: got %v, %v, want 1000", v, err)
+	}
+	if _, err := d.Read(AIN0); err == nil {
+		t.Error("Reading AIN0 while converting AIN2 continuously: got nil error")
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stopping continuous conversions: got %v", err)
+	}
+	if config := bus.regs[configReg]; config&configSingleShot == 0 {
+		t.Errorf("Stopping continuous conversions: got config %#04x, want single-shot", config)
+	}
+	if _, err := d.Read(AIN0); err != nil {
+		t.Errorf("Reading AIN0 after stopping: got %v", err)
+	}
+}
+
+func TestComparator(t *testing.T) {
+	bus := &fakeBus{shift: 4}
+	d := NewADS1015(bus, DefaultAddr)
+	err := d.SetComparator(Comparator{Low: -100, High: 1000, Window: true, Latching: true, Queue: 4})
+	if err != nil {
+		t.Fatalf("Setting comparator: got %v", err)
+	}
+	if lo, hi := bus.regs[loThreshReg], bus.regs[hiThreshReg]; lo != 0xF9C0 || hi != 0x3E80 {
+		t.Errorf("Setting comparator: got thresholds %#04x, %#04x, want 0xf9c0, 0x3e80", lo, hi)
+	}
+	d.Read(AIN0)
+	if comp := bus.regs[configReg] & 0x1F; comp != configCompWindow|configCompLatch|2 {
+		t.Errorf("Reading with comparator: got comparator bits %#02x", comp)
+	}
+	if err := d.SetComparator(Comparator{Low: 0, High: 2048}); err == nil {
+		t.Error("Setting comparator threshold to 2048 on an ads1015: got nil error")
+	}
+
+	if err := d.SetConversionReady(); err != nil {
+		t.Fatalf("Setting conversion ready: got %v", err)
+	}
+	d.Read(AIN0)
+	if lo, hi, que := bus.regs[loThreshReg], bus.regs[hiThreshReg], bus.regs[configReg]&0x3; lo&0x8000 != 0 || hi&0x8000 == 0 || que == 0x3 {
+		t.Errorf("Setting conversion ready: got thresholds %#04x, %#04x and queue %v", lo, hi, que)
+	}
+
+	d.DisableComparator()
+	d.Read(AIN0)
+	if que := bus.regs[configReg] & 0x3; que != configCompQueDis {
+		t.Errorf("Disabling comparator: got queue %v, want %v", que, configCompQueDis)
+	}
+}
+
+func TestMountedPins(t *testing.T) {
+	bus := &fakeBus{volts: [4]float64{0.5, 1.0, 2.0, 3.0}}
+	d := NewADS1115(bus, DefaultAddr)
+	d.Gain = Gain1
+	b := embd.NewBoard(&embd.Descriptor{})
+	if err := b.MountGPIO("ADC0", d.GPIODriver()); err != nil {
+		t.Fatalf("Mounting convertor: got %v", err)
+	}
+	defer b.CloseGPIO()
+
+	for key, want := range map[string]int{"ADC0_A2": 16000, "ADC0_AIN0": 4000, "ADC0_DIFF23": -8000} {
+		pin, err := b.NewAnalogPin(key)
+		if err != nil {
+			t.Fatalf("Looking up %v: got %v", key, err)
+		}
+		if v, err := pin.Read(); err != nil || v != want {
+			t.Errorf("Reading %v: got %v, %v, want %v", key, v, err, want)
+		}
+	}
+
+	var _ embd.AnalogPin = d.Pin(AIN0)
+}
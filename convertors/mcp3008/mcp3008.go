@@ -1,9 +1,12 @@
 // Package mcp3008 allows interfacing with the mcp3008 8-channel, 10-bit ADC through SPI protocol.
+//
+// See package mcp3xxx for the other convertors of the family, voltage
+// conversion and analog pins.
 package mcp3008
 
 import (
-	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/convertors/mcp3xxx"
 )
 
 // MCP3008 represents a mcp3008 8bit DAC.
@@ -26,21 +29,13 @@ func New(mode byte, bus embd.SPIBus) *MCP3008 {
 	return &MCP3008{mode, bus}
 }
 
-const (
-	startBit = 1
-)
-
 // AnalogValueAt returns the analog value at the given channel of the convertor.
+// In DifferenceMode, chanNum selects the pair as the D2-D0 bits of the
+// datasheet: CH0-CH1 is 0, CH1-CH0 is 1, and so on.
 func (m *MCP3008) AnalogValueAt(chanNum int) (int, error) {
-	var data [3]uint8
-	data[0] = startBit
-	data[1] = uint8(m.Mode)<<7 | uint8(chanNum)<<4
-	data[2] = 0
-
-	glog.V(2).Infof("mcp3008: sendingdata buffer %v", data)
-	if err := m.Bus.TransferAndReceiveData(data[:]); err != nil {
-		return 0, err
+	d := mcp3xxx.New(mcp3xxx.MCP3008, m.Bus)
+	if m.Mode == SingleMode {
+		return d.Read(chanNum)
 	}
-
-	return int(uint16(data[1]&0x03)<<8 | uint16(data[2])), nil
+	return d.ReadDifferential(chanNum, chanNum^1)
 }
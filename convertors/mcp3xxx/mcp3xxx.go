@@ -0,0 +1,316 @@
+// Package mcp3xxx allows interfacing with the MCP3002, MCP3004, MCP3008
+// (10-bit) and MCP3202, MCP3204, MCP3208 (12-bit) ADCs through SPI
+// protocol.
+//
+// Channels are measured single-ended against ground, or as pseudo-
+// differential pairs of adjacent channels where the result is 0 whenever
+// the positive input is below the negative one.
+//
+// The channels are also available as embd.AnalogPin, directly or through a
+// GPIODriver which can be mounted on the board.
+package mcp3xxx
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// DefaultVref is the reference voltage used when Vref is not set.
+const DefaultVref = 3.3
+
+// Model identifies a convertor of the family.
+type Model int
+
+const (
+	MCP3002 Model = iota
+	MCP3004
+	MCP3008
+	MCP3202
+	MCP3204
+	MCP3208
+)
+
+var models = [...]struct {
+	name     string
+	channels int
+	bits     uint
+}{
+	{"mcp3002", 2, 10},
+	{"mcp3004", 4, 10},
+	{"mcp3008", 8, 10},
+	{"mcp3202", 2, 12},
+	{"mcp3204", 4, 12},
+	{"mcp3208", 8, 12},
+}
+
+func (m Model) valid() bool {
+	return m >= 0 && int(m) < len(models)
+}
+
+func (m Model) String() string {
+	if !m.valid() {
+		return "Model(" + strconv.Itoa(int(m)) + ")"
+	}
+	return models[m].name
+}
+
+// Channels returns the number of channels of the model.
+func (m Model) Channels() int {
+	if !m.valid() {
+		return 0
+	}
+	return models[m].channels
+}
+
+// Bits returns the resolution of the model.
+func (m Model) Bits() uint {
+	if !m.valid() {
+		return 0
+	}
+	return models[m].bits
+}
+
+// MCP3xxx represents a convertor of the MCP3xxx family.
+type MCP3xxx struct {
+	Bus   embd.SPIBus
+	Model Model
+
+	// Vref is the voltage on the VREF pin, which is full scale. The
+	// default is DefaultVref.
+	Vref float64
+
+	mu  sync.Mutex
+	drv embd.GPIODriver
+}
+
+// New creates a new interface for a convertor of the given model.
+func New(model Model, bus embd.SPIBus) *MCP3xxx {
+	return &MCP3xxx{Bus: bus, Model: model}
+}
+
+// command returns the bytes to transfer for a conversion, the start bit
+// being aligned so that the result ends with the last byte.
+func (d *MCP3xxx) command(single bool, ch int) ([]byte, error) {
+	if !d.Model.valid() {
+		return nil, fmt.Errorf("mcp3xxx: invalid model %v", d.Model)
+	}
+	if ch < 0 || ch >= d.Model.Channels() {
+		return nil, fmt.Errorf("%v: invalid channel %v", d.Model, ch)
+	}
+	var sgl byte
+	if single {
+		sgl = 1
+	}
+	c := byte(ch)
+
+	switch d.Model {
+	case MCP3002:
+		// 0, start, SGL, ODD, MSBF, null, B9-B0.
+		return []byte{0x40 | sgl<<5 | c<<4 | 0x08, 0}, nil
+	case MCP3202:
+		// Start, then SGL, ODD, MSBF, null, B11-B0.
+		return []byte{0x01, sgl<<7 | c<<6 | 0x20, 0}, nil
+	case MCP3004, MCP3008:
+		// Start, then SGL, D2-D0, sample, null, B9-B0.
+		return []byte{0x01, sgl<<7 | c<<4, 0}, nil
+	default:
+		// Start, SGL, D2-D0, sample, null, B11-B0.
+		return []byte{0x04 | sgl<<1 | c>>2, c << 6, 0}, nil
+	}
+}
+
+// result extracts the conversion result from the bytes received.
+func (d *MCP3xxx) result(rx []byte) int {
+	n := len(rx)
+	v := int(rx[n-2])<<8 | int(rx[n-1])
+	return v & (1<<d.Model.Bits() - 1)
+}
+
+func (d *MCP3xxx) convert(single bool, ch int) (int, error) {
+	buf, err := d.command(single, ch)
+	if err != nil {
+		return 0, err
+	}
+	glog.V(2).Infof("%v: sending %v", d.Model, buf)
+	if err := d.Bus.TransferAndReceiveData(buf); err != nil {
+		return 0, err
+	}
+	return d.result(buf), nil
+}
+
+// Read converts the channel ch against ground.
+func (d *MCP3xxx) Read(ch int) (int, error) {
+	return d.convert(true, ch)
+}
+
+// Differential returns the code of the pair of channels p, n: the two
+// channels of a pair are adjacent, the lower being even.
+func Differential(p, n int) (int, error) {
+	lo := p
+	if n < lo {
+		lo = n
+	}
+	if lo < 0 || lo%2 != 0 || p-n != 1 && n-p != 1 {
+		return 0, fmt.Errorf("mcp3xxx: no differential pair CH%v - CH%v", p, n)
+	}
+	return p, nil
+}
+
+// ReadDifferential converts the voltage of the channel p against that of
+// the channel n, which must form a pair (see Differential). The result is
+// 0 when CHp is below CHn.
+func (d *MCP3xxx) ReadDifferential(p, n int) (int, error) {
+	code, err := Differential(p, n)
+	if err != nil {
+		return 0, err
+	}
+	return d.convert(false, code)
+}
+
+// ReadAll converts every channel against ground, in a single transaction.
+func (d *MCP3xxx) ReadAll() ([]int, error) {
+	channels := make([]int, d.Model.Channels())
+	for ch := range channels {
+		channels[ch] = ch
+	}
+	return d.Scan(channels...)
+}
+
+// Scan converts the given channels against ground in a single
+// transaction, which avoids the overhead of a transfer per conversion.
+func (d *MCP3xxx) Scan(channels ...int) ([]int, error) {
+	segments := make([]embd.SPISegment, len(channels))
+	for i, ch := range channels {
+		buf, err := d.command(true, ch)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = embd.SPISegment{Tx: buf, Rx: make([]byte, len(buf)), CSChange: true}
+	}
+	// Leave the chip select deasserted after the last conversion.
+	if len(segments) > 0 {
+		segments[len(segments)-1].CSChange = false
+	}
+	if err := d.Bus.Transaction(segments...); err != nil {
+		return nil, err
+	}
+
+	values := make([]int, len(channels))
+	for i := range segments {
+		values[i] = d.result(segments[i].Rx)
+	}
+	return values, nil
+}
+
+// Burst converts the channel ch n times in a single transaction.
+func (d *MCP3xxx) Burst(ch, n int) ([]int, error) {
+	channels := make([]int, n)
+	for i := range channels {
+		channels[i] = ch
+	}
+	return d.Scan(channels...)
+}
+
+func (d *MCP3xxx) vref() float64 {
+	if d.Vref == 0 {
+		return DefaultVref
+	}
+	return d.Vref
+}
+
+// ToVoltage converts a conversion result to volts, according to Vref.
+func (d *MCP3xxx) ToVoltage(v int) float64 {
+	return float64(v) * d.vref() / float64(int(1)<<d.Model.Bits())
+}
+
+// Voltage converts the channel ch against ground and returns the result
+// in volts.
+func (d *MCP3xxx) Voltage(ch int) (float64, error) {
+	v, err := d.Read(ch)
+	if err != nil {
+		return 0, err
+	}
+	return d.ToVoltage(v), nil
+}
+
+// Pin is a channel, or a differential pair of channels, of the convertor
+// as an embd.AnalogPin.
+type Pin struct {
+	d      *MCP3xxx
+	ch     int
+	single bool
+
+	id  string
+	drv embd.GPIODriver
+}
+
+// Pin returns the channel ch as an embd.AnalogPin.
+func (d *MCP3xxx) Pin(ch int) *Pin {
+	return &Pin{d: d, ch: ch, single: true}
+}
+
+// DifferentialPin returns the pair of channels p, n as an embd.AnalogPin.
+func (d *MCP3xxx) DifferentialPin(p, n int) (*Pin, error) {
+	code, err := Differential(p, n)
+	if err != nil {
+		return nil, err
+	}
+	return &Pin{d: d, ch: code}, nil
+}
+
+// N returns the channel of the pin, or the positive channel of a pair.
+func (p *Pin) N() int {
+	return p.ch
+}
+
+// Read converts the pin.
+func (p *Pin) Read() (int, error) {
+	return p.d.convert(p.single, p.ch)
+}
+
+// Voltage converts the pin and returns the result in volts.
+func (p *Pin) Voltage() (float64, error) {
+	v, err := p.Read()
+	if err != nil {
+		return 0, err
+	}
+	return p.d.ToVoltage(v), nil
+}
+
+// Close releases the pin.
+func (p *Pin) Close() error {
+	if p.drv == nil {
+		return nil
+	}
+	return p.drv.Unregister(p.id)
+}
+
+// GPIODriver returns a GPIO driver providing the channels of the
+// convertor as analog pins, named CH0 to CH7 (or 0 to 7, A0 to A7).
+func (d *MCP3xxx) GPIODriver() embd.GPIODriver {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.drv == nil {
+		var pinMap embd.PinMap
+		for ch := 0; ch < d.Model.Channels(); ch++ {
+			n := strconv.Itoa(ch)
+			pinMap = append(pinMap, &embd.PinDesc{
+				ID:            "CH" + n,
+				Aliases:       []string{n, "A" + n},
+				Caps:          embd.CapAnalog,
+				AnalogLogical: ch,
+			})
+		}
+		d.drv = embd.NewGPIODriver(pinMap, nil, d.newAnalogPin, nil)
+	}
+	return d.drv
+}
+
+func (d *MCP3xxx) newAnalogPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.AnalogPin {
+	return &Pin{d: d, ch: pd.AnalogLogical, single: true, id: pd.ID, drv: drv}
+}
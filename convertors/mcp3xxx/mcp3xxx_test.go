@@ -0,0 +1,241 @@
+package mcp3xxx
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// adc emulates a convertor at the bit level: once the start bit is
+// received, it reads the SGL bit and the channel bits, lets two clocks
+// pass (sampling and null bit) and then shifts the result out MSB first.
+type adc struct {
+	model Model
+	vref  float64
+	volts []float64
+
+	transfers int // Chip select assertions.
+}
+
+func (a *adc) code(single bool, ch int) int {
+	v := a.volts[ch]
+	if !single {
+		v -= a.volts[ch^1]
+	}
+	max := 1<<a.model.Bits() - 1
+	code := int(math.Floor(v / a.vref * float64(max+1)))
+	switch {
+	case code < 0:
+		return 0
+	case code > max:
+		return max
+	}
+	return code
+}
+
+// transfer carries out the bits exchanged during one chip select
+// assertion.
+func (a *adc) transfer(tx []byte) ([]byte, error) {
+	a.transfers++
+	rx := make([]byte, len(tx))
+
+	addrBits := 3
+	if a.model.Channels() == 2 {
+		// ODD and MSBF; MSBF also lets the input be sampled.
+		addrBits = 2
+	}
+	state, bits := "idle", 0
+	var single bool
+	var ch, out, outBits int
+	for i := 0; i < 8*len(tx); i++ {
+		bit := int(tx[i/8]>>uint(7-i%8)) & 1
+		switch state {
+		case "idle":
+			if bit == 1 {
+				state = "sgl"
+			}
+		case "sgl":
+			single = bit == 1
+			state = "addr"
+		case "addr":
+			ch = ch<<1 | bit
+			if bits++; bits == addrBits {
+				if a.model.Channels() == 2 {
+					if ch&1 == 0 {
+						return nil, errors.New("MSBF must be set")
+					}
+					ch >>= 1
+					state, bits = "null", 0
+				} else {
+					state, bits = "sample", 0
+				}
+			}
+		case "sample":
+			state = "null"
+		case "null":
+			if ch >= a.model.Channels() {
+				return nil, errors.New("bad channel")
+			}
+			out, outBits = a.code(single, ch), int(a.model.Bits())
+			state = "out"
+		case "out":
+			if outBits > 0 {
+				outBits--
+				if out>>uint(outBits)&1 != 0 {
+					rx[i/8] |= 1 << uint(7-i%8)
+				}
+			}
+		}
+	}
+	if state != "out" || outBits != 0 {
+		return nil, errors.New("incomplete conversion")
+	}
+	return rx, nil
+}
+
+// fakeBus connects an adc to the SPI bus.
+type fakeBus struct {
+	sim.SPIBus
+
+	a *adc
+}
+
+func (b *fakeBus) TransferAndReceiveData(buf []byte) error {
+	rx, err := b.a.transfer(buf)
+	if err != nil {
+		return err
+	}
+	copy(buf, rx)
+	return nil
+}
+
+func (b *fakeBus) Transaction(segments ...embd.SPISegment) error {
+	var tx []byte
+	var rx [][]byte
+	for i, seg := range segments {
+		tx = append(tx, seg.Tx...)
+		rx = append(rx, seg.Rx)
+		if !seg.CSChange && i != len(segments)-1 {
+			continue
+		}
+		in, err := b.a.transfer(tx)
+		if err != nil {
+			return err
+		}
+		for _, r := range rx {
+			n := copy(r, in)
+			in = in[n:]
+		}
+		tx, rx = nil, nil
+	}
+	return nil
+}
+
+var testVolts = []float64{0.1, 0.5, 1.0, 1.5, 2.0, 2.5, 3.0, 3.2}
+
+func newTestADC(model Model) (*MCP3xxx, *adc) {
+	a := &adc{model: model, vref: 3.3, volts: testVolts[:model.Channels()]}
+	return New(model, &fakeBus{a: a}), a
+}
+
+func TestRead(t *testing.T) {
+	for m := MCP3002; m <= MCP3208; m++ {
+		d, a := newTestADC(m)
+		for ch := 0; ch < m.Channels(); ch++ {
+			v, err := d.Read(ch)
+			if err != nil {
+				t.Errorf("%v: reading channel %v: got %v", m, ch, err)
+				continue
+			}
+			if want := a.code(true, ch); v != want {
+				t.Errorf("%v: reading channel %v: got %v, want %v", m, ch, v, want)
+			}
+			volts := d.ToVoltage(v)
+			if math.Abs(volts-testVolts[ch]) > 3.3/float64(int(1)<<m.Bits()) {
+				t.Errorf("%v: reading channel %v: got %vV, want %vV", m, ch, volts, testVolts[ch])
+			}
+		}
+		if _, err := d.Read(m.Channels()); err == nil {
+			t.Errorf("%v: reading channel %v: got nil error", m, m.Channels())
+		}
+	}
+}
+
+func TestReadDifferential(t *testing.T) {
+	for m := MCP3002; m <= MCP3208; m++ {
+		d, a := newTestADC(m)
+		v, err := d.ReadDifferential(1, 0)
+		if err != nil {
+			t.Errorf("%v: reading CH1 - CH0: got %v", m, err)
+			continue
+		}
+		if want := a.code(false, 1); v != want || v == 0 {
+			t.Errorf("%v: reading CH1 - CH0: got %v, want %v", m, v, want)
+		}
+		if v, _ := d.ReadDifferential(0, 1); v != 0 {
+			t.Errorf("%v: reading CH0 - CH1: got %v, want 0", m, v)
+		}
+	}
+
+	for _, pair := range [][2]int{{0, 2}, {1, 2}, {2, 1}, {-1, 0}} {
+		if _, err := Differential(pair[0], pair[1]); err == nil {
+			t.Errorf("Differential(%v, %v): got nil error", pair[0], pair[1])
+		}
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	d, a := newTestADC(MCP3208)
+	values, err := d.ReadAll()
+	if err != nil {
+		t.Fatalf("Reading all channels: got %v", err)
+	}
+	for ch, v := range values {
+		if want := a.code(true, ch); v != want {
+			t.Errorf("Reading all channels: got %v on channel %v, want %v", v, ch, want)
+		}
+	}
+	if a.transfers != 8 {
+		t.Errorf("Reading all channels: got %v conversions, want 8", a.transfers)
+	}
+
+	burst, err := d.Burst(5, 4)
+	if err != nil {
+		t.Fatalf("Reading a burst: got %v", err)
+	}
+	if len(burst) != 4 || burst[3] != a.code(true, 5) {
+		t.Errorf("Reading a burst of channel 5: got %v", burst)
+	}
+}
+
+func TestMountedPins(t *testing.T) {
+	d, _ := newTestADC(MCP3004)
+	d.Vref = 3.3
+	b := embd.NewBoard(&embd.Descriptor{})
+	if err := b.MountGPIO("ADC0", d.GPIODriver()); err != nil {
+		t.Fatalf("Mounting convertor: got %v", err)
+	}
+	defer b.CloseGPIO()
+
+	pin, err := b.NewAnalogPin("ADC0_CH2")
+	if err != nil {
+		t.Fatalf("Looking up ADC0_CH2: got %v", err)
+	}
+	if v, err := pin.Read(); err != nil || v != 310 {
+		t.Errorf("Reading ADC0_CH2: got %v, %v, want 310", v, err)
+	}
+	if _, err := b.NewAnalogPin("ADC0_CH4"); err == nil {
+		t.Error("Looking up ADC0_CH4 on a mcp3004: got nil error")
+	}
+
+	diff, err := d.DifferentialPin(3, 2)
+	if err != nil {
+		t.Fatalf("Looking up CH3 - CH2: got %v", err)
+	}
+	if volts, _ := diff.Voltage(); math.Abs(volts-0.5) > 0.01 {
+		t.Errorf("Reading CH3 - CH2: got %vV, want 0.5V", volts)
+	}
+}
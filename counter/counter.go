@@ -0,0 +1,295 @@
+// Package counter counts the edges of a digital signal and measures its
+// frequency, period and duty cycle, as needed for flow meters, fan
+// tachometers, anemometers and the like.
+//
+// The signal is sampled through the interrupts of the pin, so each edge
+// is timestamped as its interrupt is delivered. Edges are counted on the
+// rising edges of the logical signal; make the pin active low to count
+// the falling edges instead.
+package counter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// DefaultGate is the length of the gate windows when Gate is not set.
+const DefaultGate = time.Second
+
+// Measurement describes the signal over a gate window.
+type Measurement struct {
+	// Start and End delimit the gate window.
+	Start, End time.Time
+
+	// Count is the number of rising edges during the window, Total the
+	// number of rising edges since the counter was started.
+	Count, Total uint64
+
+	// Frequency is the frequency of the signal in Hz. It is measured
+	// between the first and last rising edges of the window when there
+	// are at least two, and from Count and the length of the window
+	// otherwise.
+	Frequency float64
+
+	// Period, MinPeriod and MaxPeriod are the mean, shortest and longest
+	// periods completed during the window. Jitter is the standard
+	// deviation of the periods.
+	Period, MinPeriod, MaxPeriod time.Duration
+	Jitter                       time.Duration
+
+	// Duty is the fraction of the periods completed during the window
+	// spent high, or NaN if no period was completed.
+	Duty float64
+
+	// Missed is the number of edges known to have been missed during the
+	// window, two consecutive edges reporting the same level.
+	Missed uint64
+
+	// Overflow reports that the measurement before this one was dropped
+	// as C was not read in time. It is only set on measurements received
+	// from C.
+	Overflow bool
+}
+
+// Counter measures the signal on a digital pin.
+type Counter struct {
+	Pin embd.DigitalPin
+
+	// Gate is the length of the gate windows of the measurements sent on
+	// C. If negative, no measurements are sent and windows are closed by
+	// calling Read.
+	Gate time.Duration
+
+	// Clock returns the current time; time.Now is used if nil.
+	Clock func() time.Time
+
+	// C receives the measurement of each gate window once started.
+	C <-chan Measurement
+	c chan Measurement
+
+	mu     sync.Mutex
+	total  uint64
+	level  int
+	rise   time.Time // Last rising edge.
+	fall   time.Time // Last falling edge.
+	window window
+
+	quit chan struct{}
+	done chan struct{}
+
+	started bool
+}
+
+// window accumulates the edges of a gate window.
+type window struct {
+	start      time.Time
+	count      uint64
+	first      time.Time // First rising edge.
+	last       time.Time // Last rising edge.
+	periods    uint64
+	sum, sumsq float64
+	min, max   time.Duration
+	high       time.Duration
+	missed     uint64
+}
+
+// New returns a counter on the pin.
+func New(pin embd.DigitalPin) *Counter {
+	c := make(chan Measurement, 1)
+	return &Counter{Pin: pin, C: c, c: c}
+}
+
+func (c *Counter) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// Start watches the pin and, unless Gate is negative, starts sending a
+// measurement on C at the end of each gate window.
+func (c *Counter) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return nil
+	}
+
+	if err := c.Pin.SetDirection(embd.In); err != nil {
+		return err
+	}
+	level, err := c.Pin.Read()
+	if err != nil {
+		return err
+	}
+	c.level = level
+	c.window = window{start: c.now()}
+
+	if err := c.Pin.Watch(embd.EdgeBoth, c.edge); err != nil {
+		return err
+	}
+
+	if c.Gate >= 0 {
+		gate := c.Gate
+		if gate == 0 {
+			gate = DefaultGate
+		}
+		c.quit = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.run(gate)
+	}
+
+	glog.V(2).Infof("counter: started on pin %v", c.Pin.N())
+
+	c.started = true
+
+	return nil
+}
+
+func (c *Counter) run(gate time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(gate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m := c.Read()
+			select {
+			case c.c <- m:
+			default:
+				// Replace the unread measurement with the latest one.
+				select {
+				case <-c.c:
+				default:
+				}
+				m.Overflow = true
+				c.c <- m
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// edge handles the interrupts of the pin.
+func (c *Counter) edge(pin embd.DigitalPin) {
+	level, err := pin.Read()
+	if err != nil {
+		glog.Errorf("counter: reading pin %v: %v", pin.N(), err)
+		return
+	}
+	t := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &c.window
+	if level == c.level {
+		// The opposite edge was missed in between.
+		w.missed++
+		if level == embd.Low {
+			return
+		}
+		// Keep counting the rising edges, but without a period.
+		c.rise = time.Time{}
+	}
+	c.level = level
+
+	if level == embd.Low {
+		c.fall = t
+		return
+	}
+
+	c.total++
+	w.count++
+	if w.first.IsZero() {
+		w.first = t
+	}
+	w.last = t
+
+	if !c.rise.IsZero() && c.fall.After(c.rise) {
+		period := t.Sub(c.rise)
+		w.periods++
+		w.sum += float64(period)
+		w.sumsq += float64(period) * float64(period)
+		if w.periods == 1 || period < w.min {
+			w.min = period
+		}
+		if period > w.max {
+			w.max = period
+		}
+		w.high += c.fall.Sub(c.rise)
+	}
+	c.rise = t
+}
+
+// Read closes the current gate window and returns its measurement.
+func (c *Counter) Read() Measurement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now()
+	w := c.window
+	c.window = window{start: end}
+
+	m := Measurement{
+		Start:     w.start,
+		End:       end,
+		Count:     w.count,
+		Total:     c.total,
+		MinPeriod: w.min,
+		MaxPeriod: w.max,
+		Missed:    w.missed,
+		Duty:      math.NaN(),
+	}
+
+	switch {
+	case w.count >= 2 && w.last.After(w.first):
+		m.Frequency = float64(w.count-1) / w.last.Sub(w.first).Seconds()
+	case end.After(w.start):
+		m.Frequency = float64(w.count) / end.Sub(w.start).Seconds()
+	}
+	if w.periods > 0 {
+		n := float64(w.periods)
+		mean := w.sum / n
+		m.Period = time.Duration(mean)
+		m.Jitter = time.Duration(math.Sqrt(math.Max(w.sumsq/n-mean*mean, 0)))
+		m.Duty = float64(w.high) / w.sum
+	}
+
+	return m
+}
+
+// Count returns the number of rising edges since the counter was started.
+func (c *Counter) Count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.total
+}
+
+// Stop stops watching the pin and sending measurements.
+func (c *Counter) Stop() error {
+	c.mu.Lock()
+	if !c.started {
+		c.mu.Unlock()
+		return nil
+	}
+	c.started = false
+	quit, done := c.quit, c.done
+	c.mu.Unlock()
+
+	if quit != nil {
+		close(quit)
+		<-done
+	}
+
+	return c.Pin.StopWatching()
+}
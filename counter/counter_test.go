@@ -0,0 +1,121 @@
+package counter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestCounter(t *testing.T) (*Counter, *sim.Pin, *fakeClock) {
+	pin := sim.NewPin(0)
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	c := New(pin)
+	c.Gate = -1
+	c.Clock = clock.now
+	if err := c.Start(); err != nil {
+		t.Fatalf("Starting counter: got %v", err)
+	}
+	return c, pin, clock
+}
+
+// pulses drives n periods of the given high and low times.
+func pulses(pin *sim.Pin, clock *fakeClock, n int, high, low time.Duration) {
+	for i := 0; i < n; i++ {
+		pin.Drive(embd.High)
+		clock.advance(high)
+		pin.Drive(embd.Low)
+		clock.advance(low)
+	}
+}
+
+func TestCounterMeasurement(t *testing.T) {
+	c, pin, clock := newTestCounter(t)
+	defer c.Stop()
+
+	clock.advance(5 * time.Millisecond)
+	pulses(pin, clock, 100, 2*time.Millisecond, 8*time.Millisecond)
+	m := c.Read()
+
+	if m.Count != 100 || m.Total != 100 {
+		t.Errorf("100 pulses: got count %v total %v, want 100 100", m.Count, m.Total)
+	}
+	if math.Abs(m.Frequency-100) > 1e-9 {
+		t.Errorf("100 pulses at 100Hz: got %vHz", m.Frequency)
+	}
+	if m.Period != 10*time.Millisecond || m.Jitter != 0 {
+		t.Errorf("100 pulses at 100Hz: got period %v jitter %v, want 10ms 0s", m.Period, m.Jitter)
+	}
+	if math.Abs(m.Duty-0.2) > 1e-9 {
+		t.Errorf("100 pulses at 20%% duty: got %v", m.Duty)
+	}
+	if m.Missed != 0 {
+		t.Errorf("100 pulses: got %v missed edges, want 0", m.Missed)
+	}
+
+	// The next window carries on from the previous one.
+	pulses(pin, clock, 10, time.Millisecond, 4*time.Millisecond)
+	pulses(pin, clock, 10, time.Millisecond, 14*time.Millisecond)
+	m = c.Read()
+	if m.Count != 20 || m.Total != 120 {
+		t.Errorf("20 more pulses: got count %v total %v, want 20 120", m.Count, m.Total)
+	}
+	if m.MinPeriod != 5*time.Millisecond || m.MaxPeriod != 15*time.Millisecond {
+		t.Errorf("20 more pulses: got periods from %v to %v, want 5ms to 15ms", m.MinPeriod, m.MaxPeriod)
+	}
+	if m.Jitter == 0 {
+		t.Error("20 more pulses of varying periods: got no jitter")
+	}
+}
+
+func TestCounterMissedEdges(t *testing.T) {
+	c, pin, clock := newTestCounter(t)
+	defer c.Stop()
+
+	pulses(pin, clock, 3, time.Millisecond, time.Millisecond)
+	// Interrupts reporting the same level twice indicate missed edges.
+	pin.Drive(embd.High)
+	c.edge(pin)
+	m := c.Read()
+	if m.Missed != 1 {
+		t.Errorf("Repeated high level: got %v missed edges, want 1", m.Missed)
+	}
+	if m.Count != 5 {
+		t.Errorf("Repeated high level: got count %v, want 5", m.Count)
+	}
+}
+
+func TestCounterGate(t *testing.T) {
+	pin := sim.NewPin(0)
+	c := New(pin)
+	c.Gate = 10 * time.Millisecond
+	if err := c.Start(); err != nil {
+		t.Fatalf("Starting counter: got %v", err)
+	}
+	defer c.Stop()
+
+	pin.Drive(embd.High)
+	pin.Drive(embd.Low)
+	m := <-c.C
+	if m.Count != 1 || m.End.Sub(m.Start) <= 0 {
+		t.Errorf("Gated measurement: got count %v over %v, want 1", m.Count, m.End.Sub(m.Start))
+	}
+	time.Sleep(35 * time.Millisecond)
+	if m = <-c.C; !m.Overflow {
+		t.Error("Unread measurements: got no overflow")
+	}
+}
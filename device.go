@@ -0,0 +1,57 @@
+// Device node support.
+
+package embd
+
+import "errors"
+
+// ErrDeviceNotFound is returned when the device node backing a bus does
+// not exist, usually because the corresponding kernel module or overlay
+// is not loaded.
+var ErrDeviceNotFound = errors.New("embd: device node not found")
+
+// ErrPermissionDenied is returned when the device node backing a bus
+// exists but cannot be opened by the current user.
+var ErrPermissionDenied = errors.New("embd: permission denied")
+
+// DeviceError records a failure to open the device node backing a bus.
+type DeviceError struct {
+	// Path of the device node.
+	Path string
+	// Err is either ErrDeviceNotFound or ErrPermissionDenied.
+	Err error
+	// Hint suggests how the device can be made available.
+	Hint string
+}
+
+func (e *DeviceError) Error() string {
+	s := e.Err.Error() + " (" + e.Path + ")"
+	if e.Hint != "" {
+		s += ", " + e.Hint
+	}
+	return s
+}
+
+// IsDeviceNotFound reports whether err indicates that the device node
+// backing a bus does not exist.
+func IsDeviceNotFound(err error) bool {
+	if e, ok := err.(*DeviceError); ok {
+		err = e.Err
+	}
+	return err == ErrDeviceNotFound
+}
+
+// IsPermissionDenied reports whether err indicates that the device node
+// backing a bus could not be opened due to insufficient permissions.
+func IsPermissionDenied(err error) bool {
+	if e, ok := err.(*DeviceError); ok {
+		err = e.Err
+	}
+	return err == ErrPermissionDenied
+}
+
+// opener is implemented by buses which can acquire their device node
+// eagerly, allowing errors to be reported when the bus is created
+// rather than on first use.
+type opener interface {
+	Open() error
+}
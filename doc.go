@@ -25,6 +25,15 @@ most easily accomplished by doing an "underscore import" on of the sub-packages
 e.g., `import _ "github.com/kidoman/embd/host/chip"`. An `Init()` function in the host driver
 registers all the individual drivers with embd.
 
+The package level functions operate on a default Board describing the detected host. A Board
+can also be created explicitly from any Descriptor using NewBoard. Each Board owns its own
+GPIO, I2C, SPI and LED drivers, so several hosts (say the on-board GPIO and a simulated host)
+can be driven from the same process, and tests do not share driver state.
+
+The pins of I/O expanders, such as those of the controller/mcp23x17 and controller/pcf857x
+packages, can be mounted on a Board with MountGPIO. They are then looked up like the pins of
+the host, with the mount prefix: embd.NewDigitalPin("EXP0_GPA3").
+
 After getting the host driver the next step might be to instantiate a GPIO pin using
 `NewDigitalPin` or an I2CBus using `NewI2CBus`. Such a pin or bus can be used directly but
 often it is passed into the initializer of a sensor, controller or other user-level driver
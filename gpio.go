@@ -2,7 +2,12 @@
 
 package embd
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // The Direction type indicates the direction of a GPIO pin.
 type Direction int
@@ -60,7 +65,9 @@ type DigitalPin interface {
 	// Read reads the value from the pin.
 	Read() (int, error)
 
-	// TimePulse measures the duration of a pulse on the pin.
+	// TimePulse measures the duration of a pulse on the pin, giving up
+	// after PulseTimeout. Use the TimePulse function to choose the
+	// timeout per measurement.
 	TimePulse(state int) (time.Duration, error)
 
 	// SetDirection sets the direction of the pin (in/out).
@@ -148,43 +155,192 @@ type GPIODriver interface {
 	Close() error
 }
 
-var gpioDriverInitialized bool
-var gpioDriverInstance GPIODriver
+// InitGPIO initializes the GPIO driver of the board.
+func (b *Board) InitGPIO() error {
+	_, err := b.GPIODriver()
+	return err
+}
 
-// InitGPIO initializes the GPIO driver.
-func InitGPIO() error {
-	if gpioDriverInitialized {
-		return nil
+// GPIODriver returns the GPIO driver of the board, initializing it if
+// required.
+func (b *Board) GPIODriver() (GPIODriver, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.gpioDriver != nil {
+		return b.gpioDriver, nil
 	}
 
-	desc, err := DescribeHost()
+	desc, err := b.descriptor()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if desc.GPIODriver == nil {
-		return ErrFeatureNotSupported
+		return nil, ErrFeatureNotSupported
+	}
+
+	b.gpioDriver = desc.GPIODriver()
+
+	return b.gpioDriver, nil
+}
+
+// CloseGPIO releases resources associated with the GPIO driver of the
+// board, and closes and unmounts the drivers mounted with MountGPIO.
+func (b *Board) CloseGPIO() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+	for prefix, drv := range b.gpioMounts {
+		if err := drv.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(b.gpioMounts, prefix)
+	}
+
+	if b.gpioDriver == nil {
+		return firstErr
+	}
+
+	err := b.gpioDriver.Close()
+	b.gpioDriver = nil
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
+
+// MountGPIO makes the pins of drv, typically those of an I/O expander,
+// available from the board under prefix: the pin "GPA3" of drv is then
+// looked up as "<prefix>_GPA3" by NewDigitalPin, NewAnalogPin and
+// NewPWMPin. The prefix must not contain an underscore, and must not
+// shadow the pins of the host: "GPIO" is rejected on a host with a pin
+// "GPIO_17".
+func (b *Board) MountGPIO(prefix string, drv GPIODriver) error {
+	if prefix == "" || strings.Contains(prefix, "_") {
+		return fmt.Errorf("gpio: invalid mount prefix %q", prefix)
+	}
+	if drv == nil {
+		return errors.New("gpio: cannot mount a nil driver")
+	}
+	if host, err := b.GPIODriver(); err == nil {
+		for _, pd := range host.PinMap() {
+			for _, k := range append([]string{pd.ID}, pd.Aliases...) {
+				if strings.HasPrefix(k, prefix+"_") {
+					return fmt.Errorf("gpio: mount prefix %q shadows host pin %q", prefix, k)
+				}
+			}
+		}
 	}
 
-	gpioDriverInstance = desc.GPIODriver()
-	gpioDriverInitialized = true
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.gpioMounts[prefix]; ok {
+		return fmt.Errorf("gpio: prefix %q is already mounted", prefix)
+	}
+	if b.gpioMounts == nil {
+		b.gpioMounts = map[string]GPIODriver{}
+	}
+	b.gpioMounts[prefix] = drv
 
 	return nil
 }
 
+// UnmountGPIO closes the driver mounted under prefix and removes it from
+// the board.
+func (b *Board) UnmountGPIO(prefix string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	drv, ok := b.gpioMounts[prefix]
+	if !ok {
+		return fmt.Errorf("gpio: prefix %q is not mounted", prefix)
+	}
+	delete(b.gpioMounts, prefix)
+
+	return drv.Close()
+}
+
+// pinDriver returns the driver of the pin designated by key, and the key
+// of the pin within that driver.
+func (b *Board) pinDriver(key interface{}) (GPIODriver, interface{}, error) {
+	if ks, ok := key.(string); ok {
+		if i := strings.Index(ks, "_"); i > 0 {
+			b.mu.Lock()
+			drv, ok := b.gpioMounts[ks[:i]]
+			b.mu.Unlock()
+
+			if ok {
+				return drv, ks[i+1:], nil
+			}
+		}
+	}
+
+	drv, err := b.GPIODriver()
+	return drv, key, err
+}
+
+// NewDigitalPin returns a DigitalPin interface which allows control over
+// the digital GPIO pin of the board.
+func (b *Board) NewDigitalPin(key interface{}) (DigitalPin, error) {
+	drv, key, err := b.pinDriver(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return drv.DigitalPin(key)
+}
+
+// NewAnalogPin returns a AnalogPin interface which allows control over
+// the analog GPIO pin of the board.
+func (b *Board) NewAnalogPin(key interface{}) (AnalogPin, error) {
+	drv, key, err := b.pinDriver(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return drv.AnalogPin(key)
+}
+
+// NewPWMPin returns a PWMPin interface which allows PWM signal
+// generation over a the PWM pin of the board.
+func (b *Board) NewPWMPin(key interface{}) (PWMPin, error) {
+	drv, key, err := b.pinDriver(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return drv.PWMPin(key)
+}
+
+// InitGPIO initializes the GPIO driver.
+func InitGPIO() error {
+	return defaultBoard.InitGPIO()
+}
+
 // CloseGPIO releases resources associated with the GPIO driver.
 func CloseGPIO() error {
-	return gpioDriverInstance.Close()
+	return defaultBoard.CloseGPIO()
+}
+
+// MountGPIO makes the pins of drv available under prefix. See
+// Board.MountGPIO.
+func MountGPIO(prefix string, drv GPIODriver) error {
+	return defaultBoard.MountGPIO(prefix, drv)
+}
+
+// UnmountGPIO closes the driver mounted under prefix and removes it.
+func UnmountGPIO(prefix string) error {
+	return defaultBoard.UnmountGPIO(prefix)
 }
 
 // NewDigitalPin returns a DigitalPin interface which allows control over
 // the digital GPIO pin.
 func NewDigitalPin(key interface{}) (DigitalPin, error) {
-	if err := InitGPIO(); err != nil {
-		return nil, err
-	}
-
-	return gpioDriverInstance.DigitalPin(key)
+	return defaultBoard.NewDigitalPin(key)
 }
 
 // DigitalWrite writes val to the pin.
@@ -251,11 +407,7 @@ func PullDown(key interface{}) error {
 // NewAnalogPin returns a AnalogPin interface which allows control over
 // the analog GPIO pin.
 func NewAnalogPin(key interface{}) (AnalogPin, error) {
-	if err := InitGPIO(); err != nil {
-		return nil, err
-	}
-
-	return gpioDriverInstance.AnalogPin(key)
+	return defaultBoard.NewAnalogPin(key)
 }
 
 // AnalogWrite reads a value from the pin.
@@ -271,9 +423,5 @@ func AnalogRead(key interface{}) (int, error) {
 // NewPWMPin returns a PWMPin interface which allows PWM signal
 // generation over a the PWM pin.
 func NewPWMPin(key interface{}) (PWMPin, error) {
-	if err := InitGPIO(); err != nil {
-		return nil, err
-	}
-
-	return gpioDriverInstance.PWMPin(key)
+	return defaultBoard.NewPWMPin(key)
 }
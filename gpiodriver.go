@@ -58,7 +58,10 @@ func (io *gpioDriver) DigitalPin(key interface{}) (DigitalPin, error) {
 	}
 
 	if p, ok := io.initializedPins[pd.ID]; ok {
-		return p.(DigitalPin), nil
+		if p, ok := p.(DigitalPin); ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("gpio: pin %v is already open, but not as a digital pin", pd.ID)
 	}
 
 	p := io.dpf(pd, io)
@@ -78,7 +81,10 @@ func (io *gpioDriver) AnalogPin(key interface{}) (AnalogPin, error) {
 	}
 
 	if p, ok := io.initializedPins[pd.ID]; ok {
-		return p.(AnalogPin), nil
+		if p, ok := p.(AnalogPin); ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("gpio: pin %v is already open, but not as a analog pin", pd.ID)
 	}
 
 	p := io.apf(pd, io)
@@ -92,13 +98,16 @@ func (io *gpioDriver) PWMPin(key interface{}) (PWMPin, error) {
 		return nil, errors.New("gpio: pwm not supported on this host")
 	}
 
-	pd, found := io.pinMap.Lookup(key, CapPWM)
+	pd, found := io.pinMap.Lookup(key, CapPWM|CapSoftPWM)
 	if !found {
 		return nil, fmt.Errorf("gpio: could not find pin matching %v", key)
 	}
 
 	if p, ok := io.initializedPins[pd.ID]; ok {
-		return p.(PWMPin), nil
+		if p, ok := p.(PWMPin); ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("gpio: pin %v is already open, but not as a pwm pin", pd.ID)
 	}
 
 	p := io.ppf(pd, io)
@@ -185,3 +185,77 @@ func TestGpioPinCaching(t *testing.T) {
 		t.Fatal("Looking up a closed pin, but got the same old instance")
 	}
 }
+
+type fakePWMPin struct {
+	id string
+
+	drv GPIODriver
+}
+
+func (p *fakePWMPin) N() string {
+	return p.id
+}
+
+func (*fakePWMPin) SetPeriod(ns int) error {
+	return nil
+}
+
+func (*fakePWMPin) SetDuty(ns int) error {
+	return nil
+}
+
+func (*fakePWMPin) SetPolarity(pol Polarity) error {
+	return nil
+}
+
+func (*fakePWMPin) SetMicroseconds(us int) error {
+	return nil
+}
+
+func (*fakePWMPin) SetAnalog(value byte) error {
+	return nil
+}
+
+func (p *fakePWMPin) Close() error {
+	return p.drv.Unregister(p.id)
+}
+
+func newFakePWMPin(pd *PinDesc, drv GPIODriver) PWMPin {
+	return &fakePWMPin{id: pd.ID, drv: drv}
+}
+
+func TestGpioPinKindConflict(t *testing.T) {
+	pinMap := PinMap{
+		&PinDesc{ID: "P1_1", Aliases: []string{"1"}, Caps: CapDigital | CapPWM},
+	}
+	driver := NewGPIODriver(pinMap, newFakeDigitalPin, nil, newFakePWMPin)
+	pwm, err := driver.PWMPin(1)
+	if err != nil {
+		t.Fatalf("Looking up pwm pin 1: got %v", err)
+	}
+	if _, err := driver.DigitalPin(1); err == nil {
+		t.Error("Looking up digital pin 1 open as a pwm pin: did not get error")
+	}
+
+	pwm.Close()
+	if _, err := driver.DigitalPin(1); err != nil {
+		t.Fatalf("Looking up digital pin 1: got %v", err)
+	}
+	if _, err := driver.PWMPin(1); err == nil {
+		t.Error("Looking up pwm pin 1 open as a digital pin: did not get error")
+	}
+}
+
+func TestGpioDriverSoftPWMPin(t *testing.T) {
+	pinMap := PinMap{
+		&PinDesc{ID: "P1_1", Aliases: []string{"1"}, Caps: CapDigital | CapSoftPWM},
+		&PinDesc{ID: "P1_2", Aliases: []string{"2"}, Caps: CapDigital},
+	}
+	driver := NewGPIODriver(pinMap, newFakeDigitalPin, nil, newFakePWMPin)
+	if _, err := driver.PWMPin(1); err != nil {
+		t.Fatalf("Looking up software pwm pin 1: got %v", err)
+	}
+	if _, err := driver.PWMPin(2); err == nil {
+		t.Error("Looking up pwm pin 2 without pwm: did not get error")
+	}
+}
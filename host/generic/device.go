@@ -0,0 +1,26 @@
+// Device node support.
+
+package generic
+
+import (
+	"os"
+
+	"github.com/kidoman/embd"
+)
+
+// openDevice opens the device node at path. A missing node or
+// insufficient permissions are reported as an *embd.DeviceError carrying
+// the corresponding hint.
+func openDevice(path, notFoundHint, permissionHint string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, os.ModeExclusive)
+	switch {
+	case err == nil:
+		return file, nil
+	case os.IsNotExist(err):
+		return nil, &embd.DeviceError{Path: path, Err: embd.ErrDeviceNotFound, Hint: notFoundHint}
+	case os.IsPermission(err):
+		return nil, &embd.DeviceError{Path: path, Err: embd.ErrPermissionDenied, Hint: permissionHint}
+	default:
+		return nil, err
+	}
+}
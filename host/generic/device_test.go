@@ -0,0 +1,21 @@
+package generic
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+func TestOpenDeviceNotFound(t *testing.T) {
+	_, err := openDevice("/dev/embd-does-not-exist", "load the module", "")
+	if !embd.IsDeviceNotFound(err) {
+		t.Fatalf("Opening missing device: got %v, want %v", err, embd.ErrDeviceNotFound)
+	}
+	de, ok := err.(*embd.DeviceError)
+	if !ok {
+		t.Fatalf("Opening missing device: got %T, want *embd.DeviceError", err)
+	}
+	if de.Hint != "load the module" {
+		t.Fatalf("Opening missing device: got hint %q, want %q", de.Hint, "load the module")
+	}
+}
@@ -5,6 +5,7 @@
 package generic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -159,55 +160,56 @@ func (p *digitalPin) Write(val int) error {
 	return p.write(val)
 }
 
+// TimePulse polls a pulse at state, giving up after embd.PulseTimeout.
 func (p *digitalPin) TimePulse(state int) (time.Duration, error) {
 	if err := p.init(); err != nil {
 		return 0, err
 	}
 
-	aroundState := embd.Low
-	if state == embd.Low {
-		aroundState = embd.High
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), embd.PulseTimeout)
+	defer cancel()
 
-	// Wait for any previous pulse to end
-	for {
-		v, err := p.read()
-		if err != nil {
-			return 0, err
-		}
+	durations, err := embd.PollPulses(ctx, p, state, 1)
+	if err != nil {
+		return 0, err
+	}
+	return durations[0], nil
+}
 
-		if v == aroundState {
-			break
-		}
+// TimePulses measures pulses from the edge events of the pin, timestamped
+// as they are delivered, rather than by polling. If the pin is already
+// being watched, it falls back to polling.
+func (p *digitalPin) TimePulses(ctx context.Context, state, n int) ([]time.Duration, error) {
+	if err := p.init(); err != nil {
+		return nil, err
 	}
 
-	// Wait until ECHO goes high
-	for {
+	edges := make(chan embd.PulseEdge, 2*n+4)
+	send := func(t time.Time) {
 		v, err := p.read()
 		if err != nil {
-			return 0, err
+			return
 		}
-
-		if v == state {
-			break
+		select {
+		case edges <- embd.PulseEdge{Time: t, Value: v}:
+		default:
 		}
 	}
 
-	startTime := time.Now() // Record time when ECHO goes high
-
-	// Wait until ECHO goes low
-	for {
-		v, err := p.read()
-		if err != nil {
-			return 0, err
-		}
-
-		if v == aroundState {
-			break
-		}
+	err := p.Watch(embd.EdgeBoth, func(embd.DigitalPin) {
+		send(time.Now())
+	})
+	if err == ErrorPinAlreadyRegistered {
+		return embd.PollPulses(ctx, p, state, n)
 	}
+	if err != nil {
+		return nil, err
+	}
+	defer p.StopWatching()
+
+	send(time.Now())
 
-	return time.Since(startTime), nil // Calculate time lapsed for ECHO to transition from high to low
+	return embd.MeasurePulses(ctx, edges, state, n)
 }
 
 func (p *digitalPin) ActiveLow(b bool) error {
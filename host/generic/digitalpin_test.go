@@ -1,7 +1,10 @@
 package generic
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/kidoman/embd"
 )
@@ -24,3 +27,24 @@ func TestDigitalPinClose(t *testing.T) {
 		t.Fatal("Looking up closed digital pin 1: but got the old instance")
 	}
 }
+
+func TestDigitalPinTimePulseTimeout(t *testing.T) {
+	// The value file of a pin which stays low.
+	val, err := ioutil.TempFile("", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(val.Name())
+	defer val.Close()
+	if _, err := val.WriteString("0\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(d time.Duration) { embd.PulseTimeout = d }(embd.PulseTimeout)
+	embd.PulseTimeout = 10 * time.Millisecond
+
+	pin := &digitalPin{n: 1, val: val, readBuf: make([]byte, 1), initialized: true}
+	if _, err := pin.TimePulse(embd.High); !embd.IsPulseTimeout(err) {
+		t.Fatalf("Measuring pulse on idle pin: got %v, want a timeout", err)
+	}
+}
@@ -22,6 +22,9 @@ const (
 	rdrwCmd  = 0x0707 // Cmd to read/write data together
 
 	rd = 0x0001
+
+	i2cNotFoundHint   = "enable I²C (e.g. dtparam=i2c_arm=on on the Raspberry Pi) and load the i2c-dev module"
+	i2cPermissionHint = "run as root or add the user to the i2c group"
 )
 
 type i2c_msg struct {
@@ -55,7 +58,7 @@ func (b *i2cBus) init() error {
 	}
 
 	var err error
-	if b.file, err = os.OpenFile(fmt.Sprintf("/dev/i2c-%v", b.l), os.O_RDWR, os.ModeExclusive); err != nil {
+	if b.file, err = openDevice(fmt.Sprintf("/dev/i2c-%v", b.l), i2cNotFoundHint, i2cPermissionHint); err != nil {
 		return err
 	}
 
@@ -66,6 +69,14 @@ func (b *i2cBus) init() error {
 	return nil
 }
 
+// Open opens the underlying device node, reporting any error up front.
+func (b *i2cBus) Open() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.init()
+}
+
 func (b *i2cBus) setAddress(addr byte) error {
 	if addr != b.addr {
 		glog.V(2).Infof("i2c: setting bus %v address to %#02x", b.l, addr)
@@ -1,10 +1,14 @@
 package generic
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"runtime"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/golang/glog"
@@ -15,10 +19,12 @@ const (
 	spiIOCWrMode        = 0x40016B01
 	spiIOCWrBitsPerWord = 0x40016B03
 	spiIOCWrMaxSpeedHz  = 0x40046B04
+	spiIOCWrMode32      = 0x40046B05
 
 	spiIOCRdMode        = 0x80016B01
 	spiIOCRdBitsPerWord = 0x80016B03
 	spiIOCRdMaxSpeedHz  = 0x80046B04
+	spiIOCRdMode32      = 0x80046B05
 
 	spiIOCMessage0    = 1073769216 //0x40006B00
 	spiIOCIncrementor = 2097152    //0x200000
@@ -26,6 +32,9 @@ const (
 	defaultDelayms  = 0
 	defaultSPIBPW   = 8
 	defaultSPISpeed = 1000000
+
+	spiNotFoundHint   = "enable SPI (e.g. dtparam=spi=on on the Raspberry Pi, the BB-SPIDEV0 overlay on the BeagleBone Black) and load the spidev module"
+	spiPermissionHint = "run as root or add the user to the spi group"
 )
 
 type spiIOCTransfer struct {
@@ -37,7 +46,9 @@ type spiIOCTransfer struct {
 	delayus     uint16
 	bitsPerWord uint8
 	csChange    uint8
-	pad         uint32
+	txNbits     uint8
+	rxNbits     uint8
+	pad         uint16
 }
 
 type spiBus struct {
@@ -46,7 +57,7 @@ type spiBus struct {
 	spiDevMinor int
 
 	channel byte
-	mode    byte
+	mode    embd.SPIMode
 	speed   int
 	bpw     int
 	delayms int
@@ -63,7 +74,7 @@ func spiIOCMessageN(n uint32) uint32 {
 	return (spiIOCMessage0 + (n * spiIOCIncrementor))
 }
 
-func NewSPIBus(spiDevMinor int, mode, channel byte, speed, bpw, delay int, i func() error) embd.SPIBus {
+func NewSPIBus(spiDevMinor int, mode embd.SPIMode, channel byte, speed, bpw, delay int, i func() error) embd.SPIBus {
 	return &spiBus{
 		spiDevMinor: spiDevMinor,
 		mode:        mode,
@@ -87,48 +98,127 @@ func (b *spiBus) init() error {
 	}
 
 	var err error
-	if b.file, err = os.OpenFile(fmt.Sprintf("/dev/spidev%v.%v", b.spiDevMinor, b.channel), os.O_RDWR, os.ModeExclusive); err != nil {
+	if b.file, err = openDevice(fmt.Sprintf("/dev/spidev%v.%v", b.spiDevMinor, b.channel), spiNotFoundHint, spiPermissionHint); err != nil {
 		return err
 	}
 	glog.V(3).Infof("spi: sucessfully opened file /dev/spidev%v.%v", b.spiDevMinor, b.channel)
 
-	if err = b.setMode(); err != nil {
+	b.spiTransferData = spiIOCTransfer{}
+
+	if err = b.configure(); err != nil {
+		b.file.Close()
+		b.file = nil
 		return err
 	}
 
-	b.spiTransferData = spiIOCTransfer{}
+	glog.V(2).Infof("spi: bus %v initialized", b.channel)
+	glog.V(3).Infof("spi: bus %v initialized with spiIOCTransfer as %v", b.channel, b.spiTransferData)
 
-	if err = b.setSpeed(); err != nil {
-		return err
+	b.initialized = true
+	return nil
+}
+
+// Open opens and configures the underlying device node, reporting any
+// error up front.
+func (b *spiBus) Open() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.init()
+}
+
+// Configure changes the mode, speed, bpw and delay used by the bus. If the
+// bus has already been opened, the new settings are applied immediately.
+func (b *spiBus) Configure(mode embd.SPIMode, speed, bpw, delay int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mode = mode
+	b.speed = speed
+	b.bpw = bpw
+	b.delayms = delay
+
+	if !b.initialized {
+		return nil
 	}
 
-	if err = b.setBPW(); err != nil {
+	if err := b.configure(); err != nil {
 		return err
 	}
 
-	b.setDelay()
+	glog.V(2).Infof("spi: bus %v reconfigured", b.channel)
 
-	glog.V(2).Infof("spi: bus %v initialized", b.channel)
-	glog.V(3).Infof("spi: bus %v initialized with spiIOCTransfer as %v", b.channel, b.spiTransferData)
+	return nil
+}
+
+// configure applies the mode, speed, bpw and delay to the open device.
+func (b *spiBus) configure() error {
+	if err := b.setMode(); err != nil {
+		return err
+	}
+	if err := b.setSpeed(); err != nil {
+		return err
+	}
+	if err := b.setBPW(); err != nil {
+		return err
+	}
+	b.setDelay()
 
-	b.initialized = true
 	return nil
 }
 
 func (b *spiBus) setMode() error {
-	var mode = uint8(b.mode)
-	glog.V(3).Infof("spi: setting spi mode to %v", mode)
+	if err := b.mode.Validate(); err != nil {
+		return err
+	}
+
+	var mode = uint32(b.mode)
+	glog.V(3).Infof("spi: setting spi mode to %#x", mode)
 
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), spiIOCWrMode, uintptr(unsafe.Pointer(&mode)))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), spiIOCWrMode32, uintptr(unsafe.Pointer(&mode)))
+	if errno == syscall.ENOTTY && mode <= math.MaxUint8 {
+		// Kernels before 3.15 do not know SPI_IOC_WR_MODE32, fall back to
+		// the 8 bit variant when the mode allows it.
+		mode8 := uint8(mode)
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), spiIOCWrMode, uintptr(unsafe.Pointer(&mode8)))
+	}
 	if errno != 0 {
 		err := syscall.Errno(errno)
 		glog.V(3).Infof("spi: failed to set mode due to %v", err.Error())
 		return err
 	}
-	glog.V(3).Infof("spi: mode set to %v", mode)
+	glog.V(3).Infof("spi: mode set to %#x", mode)
 	return nil
 }
 
+func (b *spiBus) readMode() (embd.SPIMode, error) {
+	var mode uint32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), spiIOCRdMode32, uintptr(unsafe.Pointer(&mode)))
+	if errno == syscall.ENOTTY {
+		var mode8 uint8
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), spiIOCRdMode, uintptr(unsafe.Pointer(&mode8)))
+		mode = uint32(mode8)
+	}
+	if errno != 0 {
+		err := syscall.Errno(errno)
+		glog.V(3).Infof("spi: failed to read mode due to %v", err.Error())
+		return 0, err
+	}
+	return embd.SPIMode(mode), nil
+}
+
+// Mode returns the mode of the bus as read back from the controller.
+func (b *spiBus) Mode() (embd.SPIMode, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.init(); err != nil {
+		return 0, err
+	}
+
+	return b.readMode()
+}
+
 func (b *spiBus) setSpeed() error {
 	var speed uint32 = defaultSPISpeed
 	if b.speed > 0 {
@@ -199,6 +289,97 @@ func (b *spiBus) TransferAndReceiveData(dataBuffer []uint8) error {
 	return nil
 }
 
+// segmentTransfers converts the segments of a transaction into the
+// transfers submitted to the kernel, using the bus settings for the
+// parameters not overridden by the segments.
+func (b *spiBus) segmentTransfers(segments []embd.SPISegment) ([]spiIOCTransfer, error) {
+	if len(segments) == 0 {
+		return nil, errors.New("spi: transaction has no segments")
+	}
+
+	transfers := make([]spiIOCTransfer, len(segments))
+	for i := range segments {
+		seg := &segments[i]
+		if seg.Tx != nil && seg.Rx != nil && len(seg.Tx) != len(seg.Rx) {
+			return nil, fmt.Errorf("spi: segment %v has tx length %v but rx length %v", i, len(seg.Tx), len(seg.Rx))
+		}
+		if seg.Speed < 0 || seg.BPW < 0 || seg.BPW > math.MaxUint8 {
+			return nil, fmt.Errorf("spi: segment %v has invalid speed %v or bpw %v", i, seg.Speed, seg.BPW)
+		}
+		txNbits, err := segmentNbits(seg.TxNBits)
+		if err != nil {
+			return nil, fmt.Errorf("spi: segment %v: %v", i, err)
+		}
+		rxNbits, err := segmentNbits(seg.RxNBits)
+		if err != nil {
+			return nil, fmt.Errorf("spi: segment %v: %v", i, err)
+		}
+		delay := seg.Delay / time.Microsecond
+		if delay < 0 || delay > math.MaxUint16 {
+			return nil, fmt.Errorf("spi: segment %v delay %v is out of range", i, seg.Delay)
+		}
+
+		t := b.spiTransferData
+		t.length = uint32(seg.Len())
+		if len(seg.Tx) > 0 {
+			t.txBuf = uint64(uintptr(unsafe.Pointer(&seg.Tx[0])))
+		}
+		if len(seg.Rx) > 0 {
+			t.rxBuf = uint64(uintptr(unsafe.Pointer(&seg.Rx[0])))
+		}
+		if seg.Speed > 0 {
+			t.speedHz = uint32(seg.Speed)
+		}
+		if seg.BPW > 0 {
+			t.bitsPerWord = uint8(seg.BPW)
+		}
+		t.delayus = uint16(delay)
+		t.txNbits = txNbits
+		t.rxNbits = rxNbits
+		if seg.CSChange {
+			t.csChange = 1
+		}
+		transfers[i] = t
+	}
+
+	return transfers, nil
+}
+
+func segmentNbits(n int) (uint8, error) {
+	switch n {
+	case 0, 1, 2, 4:
+		return uint8(n), nil
+	default:
+		return 0, fmt.Errorf("%v data lines are not supported", n)
+	}
+}
+
+// Transaction submits all the segments to the kernel using a single
+// SPI_IOC_MESSAGE(N) ioctl.
+func (b *spiBus) Transaction(segments ...embd.SPISegment) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.init(); err != nil {
+		return err
+	}
+
+	transfers, err := b.segmentTransfers(segments)
+	if err != nil {
+		return err
+	}
+
+	glog.V(3).Infof("spi: sending transaction of %v segments", len(transfers))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), uintptr(spiIOCMessageN(uint32(len(transfers)))), uintptr(unsafe.Pointer(&transfers[0])))
+	runtime.KeepAlive(segments)
+	if errno != 0 {
+		err := syscall.Errno(errno)
+		glog.V(3).Infof("spi: failed to carry out transaction due to %v", err.Error())
+		return err
+	}
+	return nil
+}
+
 func (b *spiBus) ReceiveData(len int) ([]uint8, error) {
 	if err := b.init(); err != nil {
 		return nil, err
@@ -0,0 +1,47 @@
+package generic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+func TestSPISegmentTransfers(t *testing.T) {
+	b := &spiBus{spiTransferData: spiIOCTransfer{speedHz: 1000000, bitsPerWord: 8}}
+	cmd := []byte{0x03, 0x00, 0x10, 0x00}
+	data := make([]byte, 16)
+	transfers, err := b.segmentTransfers([]embd.SPISegment{
+		{Tx: cmd},
+		{Rx: data, RxNBits: 4, Speed: 500000, Delay: 10 * time.Microsecond, CSChange: true},
+	})
+	if err != nil {
+		t.Fatalf("Converting segments: got %v", err)
+	}
+	if len(transfers) != 2 {
+		t.Fatalf("Converting segments: got %v transfers, want 2", len(transfers))
+	}
+	cmdT, dataT := transfers[0], transfers[1]
+	if cmdT.length != 4 || cmdT.txBuf == 0 || cmdT.rxBuf != 0 || cmdT.speedHz != 1000000 || cmdT.bitsPerWord != 8 || cmdT.csChange != 0 {
+		t.Errorf("Converting command segment: got %+v", cmdT)
+	}
+	if dataT.length != 16 || dataT.txBuf != 0 || dataT.rxBuf == 0 || dataT.speedHz != 500000 || dataT.delayus != 10 || dataT.rxNbits != 4 || dataT.csChange != 1 {
+		t.Errorf("Converting data segment: got %+v", dataT)
+	}
+}
+
+func TestSPISegmentTransfersInvalid(t *testing.T) {
+	b := &spiBus{}
+	tests := [][]embd.SPISegment{
+		nil,
+		{{Tx: make([]byte, 2), Rx: make([]byte, 3)}},
+		{{Tx: make([]byte, 1), Delay: time.Second}},
+		{{Tx: make([]byte, 1), BPW: 256}},
+		{{Rx: make([]byte, 1), RxNBits: 3}},
+	}
+	for _, segments := range tests {
+		if _, err := b.segmentTransfers(segments); err == nil {
+			t.Errorf("Converting segments %+v: did not get error", segments)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Fast digital IO support.
+
+package rpi
+
+import (
+	"context"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/generic"
+)
+
+// FastGPIO selects the /dev/gpiomem backend for the digital pins created
+// afterwards. Pins then access the GPIO registers directly, allowing them
+// to toggle in the MHz range rather than the few kHz of the sysfs
+// interface. Interrupts are still delivered through sysfs.
+var FastGPIO = false
+
+func newDigitalPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.DigitalPin {
+	if !FastGPIO {
+		return generic.NewDigitalPin(pd, drv)
+	}
+	return &memDigitalPin{pd: pd, n: pd.DigitalLogical, drv: drv}
+}
+
+// nopDriver stands in for the GPIO driver of the sysfs pins used for
+// interrupts, which are not registered with the driver themselves.
+type nopDriver struct {
+	embd.GPIODriver
+}
+
+func (nopDriver) Unregister(id string) error {
+	return nil
+}
+
+type memDigitalPin struct {
+	pd  *embd.PinDesc
+	n   int
+	drv embd.GPIODriver
+
+	mem       *GPIOMem
+	activeLow bool
+
+	// sysfs is used to watch for interrupts.
+	sysfs embd.DigitalPin
+
+	initialized bool
+}
+
+func (p *memDigitalPin) N() int {
+	return p.n
+}
+
+func (p *memDigitalPin) init() error {
+	if p.initialized {
+		return nil
+	}
+
+	var err error
+	if p.mem, err = OpenGPIOMem(); err != nil {
+		return err
+	}
+	if err = checkGPIO(p.n); err != nil {
+		return err
+	}
+
+	p.initialized = true
+
+	return nil
+}
+
+func (p *memDigitalPin) mask() uint64 {
+	return 1 << uint(p.n)
+}
+
+func (p *memDigitalPin) SetDirection(dir embd.Direction) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	fn := uint32(fnInput)
+	if dir == embd.Out {
+		fn = fnOutput
+	}
+	return p.mem.setFunction(p.n, fn)
+}
+
+func (p *memDigitalPin) read() int {
+	v := embd.Low
+	if p.mem.Levels()&p.mask() != 0 {
+		v = embd.High
+	}
+	if p.activeLow {
+		v ^= embd.High
+	}
+	return v
+}
+
+func (p *memDigitalPin) Read() (int, error) {
+	if err := p.init(); err != nil {
+		return 0, err
+	}
+
+	return p.read(), nil
+}
+
+func (p *memDigitalPin) Write(val int) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if (val == embd.High) != p.activeLow {
+		p.mem.Set(p.mask())
+	} else {
+		p.mem.Clear(p.mask())
+	}
+	return nil
+}
+
+// TimePulse measures a pulse at state, giving up after embd.PulseTimeout.
+func (p *memDigitalPin) TimePulse(state int) (time.Duration, error) {
+	return embd.TimePulseTimeout(p, state, embd.PulseTimeout)
+}
+
+// TimePulses measures pulses by polling the GPIO registers until ctx is
+// done.
+func (p *memDigitalPin) TimePulses(ctx context.Context, state, n int) ([]time.Duration, error) {
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return embd.PollPulses(ctx, p, state, n)
+}
+
+func (p *memDigitalPin) ActiveLow(b bool) error {
+	p.activeLow = b
+	if p.sysfs != nil {
+		return p.sysfs.ActiveLow(b)
+	}
+	return nil
+}
+
+func (p *memDigitalPin) PullUp() error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	return p.mem.setPull(p.n, pullUp)
+}
+
+func (p *memDigitalPin) PullDown() error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	return p.mem.setPull(p.n, pullDown)
+}
+
+// PinGroup allows the pin to be written and read along with the other
+// memory mapped pins by embd.DigitalPort.
+func (p *memDigitalPin) PinGroup() (embd.PinGroup, uint64, bool, error) {
+	if err := p.init(); err != nil {
+		return nil, 0, false, err
+	}
+
+	return p.mem, p.mask(), p.activeLow, nil
+}
+
+func (p *memDigitalPin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	if p.sysfs == nil {
+		p.sysfs = generic.NewDigitalPin(p.pd, nopDriver{})
+		if err := p.sysfs.ActiveLow(p.activeLow); err != nil {
+			return err
+		}
+	}
+	return p.sysfs.Watch(edge, func(embd.DigitalPin) {
+		handler(p)
+	})
+}
+
+func (p *memDigitalPin) StopWatching() error {
+	if p.sysfs == nil {
+		return nil
+	}
+	return p.sysfs.StopWatching()
+}
+
+func (p *memDigitalPin) Close() error {
+	if p.sysfs != nil {
+		if err := p.sysfs.Close(); err != nil {
+			return err
+		}
+		p.sysfs = nil
+	}
+
+	return p.drv.Unregister(p.pd.ID)
+}
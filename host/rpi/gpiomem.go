@@ -0,0 +1,227 @@
+// Memory mapped GPIO support.
+
+package rpi
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	gpioMemPath = "/dev/gpiomem"
+	gpioMemSize = 4096
+
+	gpioMemNotFoundHint   = "/dev/gpiomem requires a Raspbian kernel 4.1 or later"
+	gpioMemPermissionHint = "run as root or add the user to the gpio group"
+
+	// NumGPIO is the number of GPIOs of the BCM283x.
+	NumGPIO = 54
+)
+
+// Register offsets, in 32 bit words, of the GPIO block.
+const (
+	gpfsel0   = 0x00 / 4 // Function select, 10 pins per register.
+	gpset0    = 0x1c / 4 // Output set.
+	gpclr0    = 0x28 / 4 // Output clear.
+	gplev0    = 0x34 / 4 // Pin level.
+	gppud     = 0x94 / 4 // Pull up/down (BCM2835-7).
+	gppudclk0 = 0x98 / 4 // Pull up/down clock (BCM2835-7).
+
+	gppuppdn0 = 0xe4 / 4 // Pull up/down, 16 pins per register (BCM2711).
+	gppuppdn3 = 0xf0 / 4
+
+	// gppuppdn3Legacy is read from gppuppdn3 on the chips without the
+	// BCM2711 pull registers ("gpio" in ASCII).
+	gppuppdn3Legacy = 0x6770696f
+)
+
+const (
+	fnInput  = 0
+	fnOutput = 1
+	fnMask   = 7
+)
+
+type pull int
+
+const (
+	pullNone pull = iota
+	pullDown
+	pullUp
+)
+
+// GPIOMem provides direct access to the GPIO registers mapped through
+// /dev/gpiomem. Pins are designated by masks with bit n set for GPIO n.
+type GPIOMem struct {
+	mu  sync.Mutex // Serializes read-modify-write sequences.
+	mem []byte
+}
+
+var (
+	gpioMem     *GPIOMem
+	gpioMemLock sync.Mutex
+)
+
+// OpenGPIOMem maps the GPIO registers. The mapping is shared and kept for
+// the lifetime of the process.
+func OpenGPIOMem() (*GPIOMem, error) {
+	gpioMemLock.Lock()
+	defer gpioMemLock.Unlock()
+
+	if gpioMem != nil {
+		return gpioMem, nil
+	}
+
+	file, err := os.OpenFile(gpioMemPath, os.O_RDWR|os.O_SYNC, 0)
+	switch {
+	case os.IsNotExist(err):
+		return nil, &embd.DeviceError{Path: gpioMemPath, Err: embd.ErrDeviceNotFound, Hint: gpioMemNotFoundHint}
+	case os.IsPermission(err):
+		return nil, &embd.DeviceError{Path: gpioMemPath, Err: embd.ErrPermissionDenied, Hint: gpioMemPermissionHint}
+	case err != nil:
+		return nil, err
+	}
+	defer file.Close()
+
+	mem, err := syscall.Mmap(int(file.Fd()), 0, gpioMemSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	glog.V(2).Infof("rpi: mapped %v", gpioMemPath)
+
+	gpioMem = newGPIOMem(mem)
+	return gpioMem, nil
+}
+
+func newGPIOMem(mem []byte) *GPIOMem {
+	return &GPIOMem{mem: mem}
+}
+
+// reg returns a pointer to the i-th register. The registers must be
+// accessed 32 bits at a time.
+func (g *GPIOMem) reg(i int) *uint32 {
+	return (*uint32)(unsafe.Pointer(&g.mem[i*4]))
+}
+
+func (g *GPIOMem) read(i int) uint32 {
+	return atomic.LoadUint32(g.reg(i))
+}
+
+func (g *GPIOMem) write(i int, v uint32) {
+	atomic.StoreUint32(g.reg(i), v)
+}
+
+func checkGPIO(n int) error {
+	if n < 0 || n >= NumGPIO {
+		return fmt.Errorf("rpi: gpio %v out of range", n)
+	}
+	return nil
+}
+
+func (g *GPIOMem) setFunction(n int, fn uint32) error {
+	if err := checkGPIO(n); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, shift := gpfsel0+n/10, uint(n%10)*3
+	g.write(r, g.read(r)&^(fnMask<<shift)|fn<<shift)
+	return nil
+}
+
+func (g *GPIOMem) function(n int) (uint32, error) {
+	if err := checkGPIO(n); err != nil {
+		return 0, err
+	}
+	return g.read(gpfsel0+n/10) >> (uint(n%10) * 3) & fnMask, nil
+}
+
+// is2711 reports whether the chip has the BCM2711 (Raspberry Pi 4) pull
+// registers.
+func (g *GPIOMem) is2711() bool {
+	return g.read(gppuppdn3) != gppuppdn3Legacy
+}
+
+func (g *GPIOMem) setPull(n int, p pull) error {
+	if err := checkGPIO(n); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.is2711() {
+		// The BCM2711 encodes pull up as 1 and pull down as 2.
+		v := uint32(p)
+		if p != pullNone {
+			v ^= 3
+		}
+		r, shift := gppuppdn0+n/16, uint(n%16)*2
+		g.write(r, g.read(r)&^(3<<shift)|v<<shift)
+		return nil
+	}
+
+	// The BCM2835 requires the control signal to be clocked into the pin,
+	// waiting at least 150 cycles in between.
+	clk := gppudclk0 + n/32
+	g.write(gppud, uint32(p))
+	time.Sleep(5 * time.Microsecond)
+	g.write(clk, 1<<uint(n%32))
+	time.Sleep(5 * time.Microsecond)
+	g.write(gppud, 0)
+	g.write(clk, 0)
+	return nil
+}
+
+// Set drives the output GPIOs in mask high, all at once.
+func (g *GPIOMem) Set(mask uint64) {
+	if v := uint32(mask); v != 0 {
+		g.write(gpset0, v)
+	}
+	if v := uint32(mask >> 32); v != 0 {
+		g.write(gpset0+1, v)
+	}
+}
+
+// Clear drives the output GPIOs in mask low, all at once.
+func (g *GPIOMem) Clear(mask uint64) {
+	if v := uint32(mask); v != 0 {
+		g.write(gpclr0, v)
+	}
+	if v := uint32(mask >> 32); v != 0 {
+		g.write(gpclr0+1, v)
+	}
+}
+
+// Write drives the output GPIOs in mask to the corresponding bits of
+// value.
+func (g *GPIOMem) Write(mask, value uint64) {
+	g.Set(mask & value)
+	g.Clear(mask &^ value)
+}
+
+// Levels returns the levels of all the GPIOs.
+func (g *GPIOMem) Levels() uint64 {
+	return uint64(g.read(gplev0)) | uint64(g.read(gplev0+1))<<32
+}
+
+// WriteGroup implements embd.PinGroup.
+func (g *GPIOMem) WriteGroup(mask, value uint64) error {
+	g.Write(mask, value)
+	return nil
+}
+
+// ReadGroup implements embd.PinGroup.
+func (g *GPIOMem) ReadGroup() (uint64, error) {
+	return g.Levels(), nil
+}
@@ -0,0 +1,80 @@
+package rpi
+
+import "testing"
+
+func newTestGPIOMem(bcm2711 bool) *GPIOMem {
+	g := newGPIOMem(make([]byte, gpioMemSize))
+	if !bcm2711 {
+		g.write(gppuppdn3, gppuppdn3Legacy)
+	}
+	return g
+}
+
+func TestGPIOMemFunction(t *testing.T) {
+	g := newTestGPIOMem(false)
+	g.write(gpfsel0+1, 0xffffffff)
+	if err := g.setFunction(17, fnOutput); err != nil {
+		t.Fatalf("Setting function of gpio 17: got %v", err)
+	}
+	// GPIO 17 is the 8th pin of GPFSEL1, bits 21-23.
+	if got, want := g.read(gpfsel0+1), uint32(0xffffffff)&^(7<<21)|1<<21; got != want {
+		t.Fatalf("Setting gpio 17 as output: GPFSEL1 is %#08x, want %#08x", got, want)
+	}
+	if fn, _ := g.function(17); fn != fnOutput {
+		t.Fatalf("Reading function of gpio 17: got %v, want %v", fn, fnOutput)
+	}
+	if err := g.setFunction(NumGPIO, fnInput); err == nil {
+		t.Fatalf("Setting function of gpio %v: got nil error", NumGPIO)
+	}
+}
+
+func TestGPIOMemSetClear(t *testing.T) {
+	g := newTestGPIOMem(false)
+	g.Write(1<<4|1<<17|1<<40, 1<<4|1<<40)
+	if got := g.read(gpset0); got != 1<<4 {
+		t.Errorf("Writing gpios 4, 17 and 40: GPSET0 is %#08x, want %#08x", got, 1<<4)
+	}
+	if got := g.read(gpset0 + 1); got != 1<<8 {
+		t.Errorf("Writing gpios 4, 17 and 40: GPSET1 is %#08x, want %#08x", got, 1<<8)
+	}
+	if got := g.read(gpclr0); got != 1<<17 {
+		t.Errorf("Writing gpios 4, 17 and 40: GPCLR0 is %#08x, want %#08x", got, 1<<17)
+	}
+	if got := g.read(gpclr0 + 1); got != 0 {
+		t.Errorf("Writing gpios 4, 17 and 40: GPCLR1 is %#08x, want 0", got)
+	}
+}
+
+func TestGPIOMemLevels(t *testing.T) {
+	g := newTestGPIOMem(false)
+	g.write(gplev0, 1<<3)
+	g.write(gplev0+1, 1<<21)
+	if got, want := g.Levels(), uint64(1<<3|1<<53); got != want {
+		t.Fatalf("Reading levels: got %#x, want %#x", got, want)
+	}
+}
+
+func TestGPIOMemPull(t *testing.T) {
+	g := newTestGPIOMem(false)
+	if err := g.setPull(33, pullUp); err != nil {
+		t.Fatalf("Pulling up gpio 33: got %v", err)
+	}
+	// The sequence ends by resetting the control and clock registers.
+	if g.read(gppud) != 0 || g.read(gppudclk0+1) != 0 {
+		t.Fatal("Pulling up gpio 33 on BCM2835: control signal left asserted")
+	}
+
+	g = newTestGPIOMem(true)
+	g.write(gppuppdn0+2, 0xffffffff)
+	if err := g.setPull(33, pullUp); err != nil {
+		t.Fatalf("Pulling up gpio 33: got %v", err)
+	}
+	// GPIO 33 is the 2nd pin of GPIO_PUP_PDN_CNTRL_REG2, bits 2-3.
+	if got, want := g.read(gppuppdn0+2), uint32(0xffffffff)&^(3<<2)|1<<2; got != want {
+		t.Fatalf("Pulling up gpio 33 on BCM2711: register is %#08x, want %#08x", got, want)
+	}
+	g.setPull(33, pullDown)
+	if got := g.read(gppuppdn0+2) >> 2 & 3; got != 2 {
+		t.Fatalf("Pulling down gpio 33 on BCM2711: got %v, want 2", got)
+	}
+}
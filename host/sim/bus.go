@@ -0,0 +1,55 @@
+// Bases for simulated buses.
+
+package sim
+
+import (
+	"errors"
+
+	"github.com/kidoman/embd"
+)
+
+// ErrNotImplemented is returned by the methods of I2CBus and SPIBus which
+// a simulated device does not implement.
+var ErrNotImplemented = errors.New("sim: not implemented")
+
+// I2CBus implements embd.I2CBus with methods which fail with
+// ErrNotImplemented, except for Close, which does nothing. Simulated
+// devices embed it and implement the methods their drivers use:
+//
+//	type fakeDAC struct {
+//		sim.I2CBus
+//		value uint16
+//	}
+//
+//	func (d *fakeDAC) WriteBytes(addr byte, value []byte) error { ... }
+type I2CBus struct{}
+
+var _ embd.I2CBus = I2CBus{}
+
+func (I2CBus) ReadByte(addr byte) (byte, error)                  { return 0, ErrNotImplemented }
+func (I2CBus) ReadBytes(addr byte, num int) ([]byte, error)      { return nil, ErrNotImplemented }
+func (I2CBus) WriteByte(addr, value byte) error                  { return ErrNotImplemented }
+func (I2CBus) WriteBytes(addr byte, value []byte) error          { return ErrNotImplemented }
+func (I2CBus) ReadFromReg(addr, reg byte, value []byte) error    { return ErrNotImplemented }
+func (I2CBus) ReadByteFromReg(addr, reg byte) (byte, error)      { return 0, ErrNotImplemented }
+func (I2CBus) ReadWordFromReg(addr, reg byte) (uint16, error)    { return 0, ErrNotImplemented }
+func (I2CBus) WriteToReg(addr, reg byte, value []byte) error     { return ErrNotImplemented }
+func (I2CBus) WriteByteToReg(addr, reg, value byte) error        { return ErrNotImplemented }
+func (I2CBus) WriteWordToReg(addr, reg byte, value uint16) error { return ErrNotImplemented }
+func (I2CBus) Close() error                                      { return nil }
+
+// SPIBus implements embd.SPIBus with methods which fail with
+// ErrNotImplemented, except for Close, which does nothing. Simulated
+// devices embed it as they do I2CBus.
+type SPIBus struct{}
+
+var _ embd.SPIBus = SPIBus{}
+
+func (SPIBus) Write(p []byte) (int, error)                    { return 0, ErrNotImplemented }
+func (SPIBus) TransferAndReceiveData(buf []byte) error        { return ErrNotImplemented }
+func (SPIBus) ReceiveData(len int) ([]byte, error)            { return nil, ErrNotImplemented }
+func (SPIBus) TransferAndReceiveByte(data byte) (byte, error) { return 0, ErrNotImplemented }
+func (SPIBus) ReceiveByte() (byte, error)                     { return 0, ErrNotImplemented }
+func (SPIBus) Transaction(segments ...embd.SPISegment) error  { return ErrNotImplemented }
+func (SPIBus) Mode() (embd.SPIMode, error)                    { return 0, ErrNotImplemented }
+func (SPIBus) Close() error                                   { return nil }
@@ -0,0 +1,303 @@
+// Simulated digital pins.
+
+package sim
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+// The Pull type represents the pull resistor configured on a pin.
+type Pull int
+
+const (
+	// PullNone leaves a line which nobody drives low.
+	PullNone Pull = iota
+
+	// PullUp pulls a line which nobody drives high.
+	PullUp
+
+	// PullDown pulls a line which nobody drives low.
+	PullDown
+)
+
+// Pin is a simulated digital pin. Besides implementing embd.DigitalPin, it
+// allows a simulated device to drive the line (Drive, Release) and to be
+// notified of level changes (OnChange).
+//
+// The line behaves as a wired-AND: it is low as soon as either the pin (as
+// an output) or the device drives it low. When neither drives it, the pull
+// resistor decides the level. This allows open-drain buses to be emulated
+// by switching the direction of the pin.
+//
+// Level change listeners and interrupt handlers are called synchronously
+// from the goroutine causing the change, which keeps tests deterministic.
+type Pin struct {
+	id string
+	n  int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	drv embd.GPIODriver
+
+	dir       embd.Direction
+	out       int
+	activeLow bool
+	pull      Pull
+
+	extDriven bool
+	ext       int
+
+	level int
+
+	edge    embd.Edge
+	handler func(embd.DigitalPin)
+
+	listeners []func(level int)
+}
+
+// NewPin returns a standalone simulated pin with the logical number n.
+func NewPin(n int) *Pin {
+	p := &Pin{id: fmt.Sprintf("SIM_%v", n), n: n}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// N returns the logical GPIO number.
+func (p *Pin) N() int {
+	return p.n
+}
+
+// resolve computes the level of the line. p.mu must be held.
+func (p *Pin) resolve() int {
+	driven := false
+	level := embd.High
+	if p.dir == embd.Out {
+		driven = true
+		level &= p.out
+	}
+	if p.extDriven {
+		driven = true
+		level &= p.ext
+	}
+	if driven {
+		return level
+	}
+	if p.pull == PullUp {
+		return embd.High
+	}
+	return embd.Low
+}
+
+// update recomputes the level of the line and, after releasing p.mu,
+// notifies the listeners and interrupt handler of any change. p.mu must
+// be held on entry; it is released on return.
+func (p *Pin) update() {
+	prev := p.level
+	p.level = p.resolve()
+	if p.level == prev {
+		p.mu.Unlock()
+		return
+	}
+	level := p.level
+	listeners := append([]func(int){}, p.listeners...)
+	var handler func(embd.DigitalPin)
+	switch {
+	case p.edge == embd.EdgeBoth,
+		p.edge == embd.EdgeRising && level == embd.High,
+		p.edge == embd.EdgeFalling && level == embd.Low:
+		handler = p.handler
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	for _, l := range listeners {
+		l(level)
+	}
+	if handler != nil {
+		handler(p)
+	}
+}
+
+// Drive makes the simulated device drive the line to level.
+func (p *Pin) Drive(level int) {
+	p.mu.Lock()
+	p.extDriven = true
+	p.ext = level
+	p.update()
+}
+
+// Release stops the simulated device from driving the line.
+func (p *Pin) Release() {
+	p.mu.Lock()
+	p.extDriven = false
+	p.update()
+}
+
+// Level returns the physical level of the line.
+func (p *Pin) Level() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.level
+}
+
+// SetPull configures the pull resistor of the line.
+func (p *Pin) SetPull(pull Pull) {
+	p.mu.Lock()
+	p.pull = pull
+	p.update()
+}
+
+// Direction returns the direction of the pin.
+func (p *Pin) Direction() embd.Direction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.dir
+}
+
+// OnChange registers f to be called with the new physical level whenever
+// the level of the line changes.
+func (p *Pin) OnChange(f func(level int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.listeners = append(p.listeners, f)
+}
+
+func (p *Pin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.handler != nil {
+		return fmt.Errorf("sim: pin %v is already being watched", p.n)
+	}
+	p.edge = edge
+	p.handler = handler
+	return nil
+}
+
+func (p *Pin) StopWatching() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.edge = embd.EdgeNone
+	p.handler = nil
+	return nil
+}
+
+func (p *Pin) Write(val int) error {
+	p.mu.Lock()
+	if p.dir != embd.Out {
+		p.mu.Unlock()
+		return fmt.Errorf("sim: pin %v is not an output", p.n)
+	}
+	if p.activeLow {
+		val ^= embd.High
+	}
+	p.out = val & embd.High
+	p.update()
+	return nil
+}
+
+func (p *Pin) Read() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.logical(p.level), nil
+}
+
+// logical converts a physical level to its logical value. p.mu must be
+// held.
+func (p *Pin) logical(level int) int {
+	if p.activeLow {
+		return level ^ embd.High
+	}
+	return level
+}
+
+// TimePulse measures the duration of a pulse on the pin, giving up after
+// embd.PulseTimeout.
+func (p *Pin) TimePulse(state int) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	expired := false
+	timer := time.AfterFunc(embd.PulseTimeout, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		expired = true
+		p.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	waitFor := func(v int) bool {
+		for p.logical(p.level) != v {
+			if expired {
+				return false
+			}
+			p.cond.Wait()
+		}
+		return true
+	}
+	timeout := &embd.PulseTimeoutError{State: state, Err: context.DeadlineExceeded}
+	around := state ^ embd.High
+	if !waitFor(around) || !waitFor(state) {
+		return 0, timeout
+	}
+	start := time.Now()
+	if !waitFor(around) {
+		return 0, timeout
+	}
+	return time.Since(start), nil
+}
+
+func (p *Pin) SetDirection(dir embd.Direction) error {
+	p.mu.Lock()
+	p.dir = dir
+	p.update()
+	return nil
+}
+
+func (p *Pin) ActiveLow(b bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.activeLow = b
+	return nil
+}
+
+func (p *Pin) PullUp() error {
+	p.SetPull(PullUp)
+	return nil
+}
+
+func (p *Pin) PullDown() error {
+	p.SetPull(PullDown)
+	return nil
+}
+
+// Close releases the pin. The state of the line is retained so that the
+// pin can be looked up again.
+func (p *Pin) Close() error {
+	if err := p.StopWatching(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	drv := p.drv
+	p.drv = nil
+	p.mu.Unlock()
+
+	if drv == nil {
+		return nil
+	}
+	return drv.Unregister(p.id)
+}
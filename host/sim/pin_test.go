@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+func TestPinWiredAnd(t *testing.T) {
+	p := NewPin(0)
+	p.SetPull(PullUp)
+	if p.Level() != embd.High {
+		t.Fatal("Released line with pull up: got low, want high")
+	}
+	p.SetDirection(embd.Out)
+	if p.Level() != embd.Low {
+		t.Fatal("Line driven by pin: got high, want low")
+	}
+	p.SetDirection(embd.In)
+	p.Drive(embd.Low)
+	if v, _ := p.Read(); v != embd.Low {
+		t.Fatal("Line driven low by device: read high, want low")
+	}
+	p.Release()
+	if v, _ := p.Read(); v != embd.High {
+		t.Fatal("Line released by device: read low, want high")
+	}
+}
+
+func TestPinWatch(t *testing.T) {
+	p := NewPin(0)
+	var rising, falling int
+	p.Watch(embd.EdgeRising, func(embd.DigitalPin) { rising++ })
+	p.OnChange(func(level int) {
+		if level == embd.Low {
+			falling++
+		}
+	})
+	p.SetDirection(embd.Out)
+	for i := 0; i < 3; i++ {
+		p.Write(embd.High)
+		p.Write(embd.Low)
+	}
+	if rising != 3 || falling != 3 {
+		t.Fatalf("Toggling pin 3 times: got %v rising and %v falling edges, want 3 and 3", rising, falling)
+	}
+}
+
+func TestHostDescriptor(t *testing.T) {
+	host := NewHost(4)
+	board := embd.NewBoard(host.Descriptor())
+	pin, err := board.NewDigitalPin("GPIO_2")
+	if err != nil {
+		t.Fatalf("Looking up pin GPIO_2: got %v", err)
+	}
+	if pin != embd.DigitalPin(host.Pin(2)) {
+		t.Fatal("Looking up pin GPIO_2: did not get the simulated pin 2")
+	}
+	if err := pin.Close(); err != nil {
+		t.Fatalf("Closing pin GPIO_2: got %v", err)
+	}
+}
+
+func TestPinTimePulseTimeout(t *testing.T) {
+	defer func(d time.Duration) { embd.PulseTimeout = d }(embd.PulseTimeout)
+	embd.PulseTimeout = 10 * time.Millisecond
+
+	p := NewPin(0)
+	if _, err := p.TimePulse(embd.High); !embd.IsPulseTimeout(err) {
+		t.Fatalf("Measuring pulse on idle pin: got %v, want a timeout", err)
+	}
+}
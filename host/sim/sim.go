@@ -0,0 +1,72 @@
+/*
+	Package sim provides a simulated host whose pins only exist in memory.
+	It allows drivers to be exercised (and tested) without hardware, either
+	directly through the simulated pins or through an embd.Board:
+
+	host := sim.NewHost(8)
+	board := embd.NewBoard(host.Descriptor())
+	pin, err := board.NewDigitalPin(3)
+
+	The following features are supported
+
+	GPIO (digital (rw), interrupts)
+	I²C, SPI (bases for simulated devices, see I2CBus and SPIBus)
+*/
+package sim
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kidoman/embd"
+)
+
+// Host is a simulated host with a number of digital pins.
+type Host struct {
+	pins   []*Pin
+	pinMap embd.PinMap
+}
+
+// NewHost returns a simulated host with n digital pins, numbered from 0.
+// Pin i can be referred to as i, "i", "GPIO_i" or "SIM_i".
+func NewHost(n int) *Host {
+	h := &Host{pins: make([]*Pin, n)}
+	for i := range h.pins {
+		h.pins[i] = NewPin(i)
+		h.pinMap = append(h.pinMap, &embd.PinDesc{
+			ID:             h.pins[i].id,
+			Aliases:        []string{strconv.Itoa(i), fmt.Sprintf("GPIO_%v", i)},
+			Caps:           embd.CapDigital,
+			DigitalLogical: i,
+		})
+	}
+	return h
+}
+
+// Pin returns the simulated pin with the logical number n, or nil if there
+// is no such pin.
+func (h *Host) Pin(n int) *Pin {
+	if n < 0 || n >= len(h.pins) {
+		return nil
+	}
+	return h.pins[n]
+}
+
+func (h *Host) newDigitalPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.DigitalPin {
+	p := h.pins[pd.DigitalLogical]
+
+	p.mu.Lock()
+	p.drv = drv
+	p.mu.Unlock()
+
+	return p
+}
+
+// Descriptor returns a descriptor for the host, for use with embd.NewBoard.
+func (h *Host) Descriptor() *embd.Descriptor {
+	return &embd.Descriptor{
+		GPIODriver: func() embd.GPIODriver {
+			return embd.NewGPIODriver(h.pinMap, h.newDigitalPin, nil, nil)
+		},
+	}
+}
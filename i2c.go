@@ -40,40 +40,98 @@ type I2CDriver interface {
 	Close() error
 }
 
-var i2cDriverInitialized bool
-var i2cDriverInstance I2CDriver
+// InitI2C initializes the I2C driver of the board.
+func (b *Board) InitI2C() error {
+	_, err := b.I2CDriver()
+	return err
+}
 
-// InitI2C initializes the I2C driver.
-func InitI2C() error {
-	if i2cDriverInitialized {
-		return nil
+// I2CDriver returns the I2C driver of the board, initializing it if
+// required.
+func (b *Board) I2CDriver() (I2CDriver, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.i2cDriver != nil {
+		return b.i2cDriver, nil
 	}
 
-	desc, err := DescribeHost()
+	desc, err := b.descriptor()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if desc.I2CDriver == nil {
-		return ErrFeatureNotSupported
+		return nil, ErrFeatureNotSupported
 	}
 
-	i2cDriverInstance = desc.I2CDriver()
-	i2cDriverInitialized = true
+	b.i2cDriver = desc.I2CDriver()
 
-	return nil
+	return b.i2cDriver, nil
 }
 
-// CloseI2C releases resources associated with the I2C driver.
-func CloseI2C() error {
-	return i2cDriverInstance.Close()
+// CloseI2C releases resources associated with the I2C driver of the board.
+func (b *Board) CloseI2C() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.i2cDriver == nil {
+		return nil
+	}
+
+	err := b.i2cDriver.Close()
+	b.i2cDriver = nil
+
+	return err
 }
 
-// NewI2CBus returns a I2CBus.
-func NewI2CBus(l byte) I2CBus {
-	if err := InitI2C(); err != nil {
+// OpenI2CBus returns the I2CBus l of the board. The underlying device is
+// opened immediately, so a missing device node or insufficient permissions
+// are reported as a *DeviceError here rather than on first use.
+func (b *Board) OpenI2CBus(l byte) (I2CBus, error) {
+	drv, err := b.I2CDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	bus := drv.Bus(l)
+	if o, ok := bus.(opener); ok {
+		if err := o.Open(); err != nil {
+			return nil, err
+		}
+	}
+
+	return bus, nil
+}
+
+// NewI2CBus returns a I2CBus of the board. It panics if the board does not
+// support I2C; errors opening the bus are only reported on first use. Use
+// OpenI2CBus to have all errors returned instead.
+func (b *Board) NewI2CBus(l byte) I2CBus {
+	drv, err := b.I2CDriver()
+	if err != nil {
 		panic(err)
 	}
 
-	return i2cDriverInstance.Bus(l)
+	return drv.Bus(l)
+}
+
+// InitI2C initializes the I2C driver.
+func InitI2C() error {
+	return defaultBoard.InitI2C()
+}
+
+// CloseI2C releases resources associated with the I2C driver.
+func CloseI2C() error {
+	return defaultBoard.CloseI2C()
+}
+
+// OpenI2CBus returns a I2CBus, reporting any error opening it up front.
+func OpenI2CBus(l byte) (I2CBus, error) {
+	return defaultBoard.OpenI2CBus(l)
+}
+
+// NewI2CBus returns a I2CBus. It panics if the host does not support I2C.
+func NewI2CBus(l byte) I2CBus {
+	return defaultBoard.NewI2CBus(l)
 }
@@ -0,0 +1,487 @@
+// Package matrix allows interfacing with matrix keypads of any size.
+//
+// The keys of a matrix keypad connect a row line to a column line. The
+// rows are read with their pull-up resistors enabled while the columns are
+// selected one at a time by driving them low, the other columns being left
+// floating. Every key is scanned, so any number of keys can be held at
+// once (n-key rollover) on keypads with a diode per key. Without diodes,
+// three keys on the corners of a rectangle make the fourth one appear
+// pressed; such scans are ambiguous and are ignored.
+//
+// When the row pins support interrupts, the keypad is only scanned while
+// a key is held. Otherwise it is polled.
+package matrix
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	// DefaultScanInterval is the interval between scans when ScanInterval
+	// is not set.
+	DefaultScanInterval = 10 * time.Millisecond
+
+	// DefaultDebounce is the time a key must be stable for when Debounce
+	// is not set.
+	DefaultDebounce = 20 * time.Millisecond
+
+	// DefaultLongPress is the time after which a held key is reported
+	// with a LongPress event when LongPress is not set.
+	DefaultLongPress = time.Second
+
+	// DefaultRepeatDelay and DefaultRepeatInterval control the Repeat
+	// events of a held key when RepeatDelay and RepeatInterval are not
+	// set.
+	DefaultRepeatDelay    = 500 * time.Millisecond
+	DefaultRepeatInterval = 100 * time.Millisecond
+
+	maxCols = 64
+)
+
+// The EventType type indicates what happened to a key.
+type EventType int
+
+const (
+	// Press is sent when a key is pressed.
+	Press EventType = iota
+
+	// Release is sent when a key is released.
+	Release
+
+	// LongPress is sent once when a key has been held for LongPress.
+	LongPress
+
+	// Repeat is sent every RepeatInterval once a key has been held for
+	// RepeatDelay.
+	Repeat
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Press:
+		return "press"
+	case Release:
+		return "release"
+	case LongPress:
+		return "long press"
+	case Repeat:
+		return "repeat"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes a change in the state of a key.
+type Event struct {
+	Type EventType
+
+	// Key is the name of the key in the key map, found at Row and Col.
+	Key      string
+	Row, Col int
+
+	// Time is the time of the scan which detected the event.
+	Time time.Time
+
+	// Held is the time the key has been held for, except for Press
+	// events.
+	Held time.Duration
+}
+
+// key holds the debouncing state of a key.
+type key struct {
+	raw      bool      // Last scanned state.
+	rawSince time.Time // Time raw last changed.
+	pressed  bool      // Debounced state.
+	since    time.Time // Time the key was pressed.
+	long     bool      // LongPress sent.
+	repeat   time.Time // Time of the next Repeat.
+}
+
+// Matrix represents a matrix keypad.
+type Matrix struct {
+	Rows, Cols []embd.DigitalPin
+
+	// Keymap names the key at each row and column.
+	Keymap [][]string
+
+	// Diodes indicates that each key has a diode, so that scans are never
+	// ambiguous.
+	Diodes bool
+
+	// ScanInterval is the interval between scans while a key is held, or
+	// at all times when the keypad is polled.
+	ScanInterval time.Duration
+
+	// Debounce is the time a key must be stable for its state to change.
+	Debounce time.Duration
+
+	// LongPress is the time after which a held key is reported with a
+	// LongPress event. If negative, no LongPress events are sent.
+	LongPress time.Duration
+
+	// RepeatDelay is the time after which a held key starts to be
+	// reported with a Repeat event every RepeatInterval. If negative, no
+	// Repeat events are sent.
+	RepeatDelay, RepeatInterval time.Duration
+
+	// Clock returns the current time; time.Now is used if nil.
+	Clock func() time.Time
+
+	// C receives the events once started.
+	C <-chan Event
+	c chan Event
+
+	mu   sync.Mutex
+	keys [][]key
+
+	watching bool
+	wake     chan struct{}
+	quit     chan struct{}
+	done     chan struct{}
+
+	started bool
+}
+
+// New returns a keypad whose keys connect rows to cols, named by keymap
+// which is indexed by row then column.
+func New(rows, cols []embd.DigitalPin, keymap [][]string) *Matrix {
+	c := make(chan Event, 32)
+	return &Matrix{Rows: rows, Cols: cols, Keymap: keymap, C: c, c: c}
+}
+
+func (m *Matrix) now() time.Time {
+	if m.Clock != nil {
+		return m.Clock()
+	}
+	return time.Now()
+}
+
+func duration(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+func (m *Matrix) setup() error {
+	if len(m.Rows) == 0 || len(m.Cols) == 0 {
+		return errors.New("matrix: no rows or columns")
+	}
+	if len(m.Cols) > maxCols {
+		return fmt.Errorf("matrix: %v columns, at most %v are supported", len(m.Cols), maxCols)
+	}
+	if len(m.Keymap) != len(m.Rows) {
+		return fmt.Errorf("matrix: key map has %v rows, want %v", len(m.Keymap), len(m.Rows))
+	}
+	for i, row := range m.Keymap {
+		if len(row) != len(m.Cols) {
+			return fmt.Errorf("matrix: key map row %v has %v keys, want %v", i, len(row), len(m.Cols))
+		}
+	}
+
+	for _, pin := range m.Rows {
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+		if err := pin.PullUp(); err != nil {
+			return err
+		}
+	}
+	for _, pin := range m.Cols {
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+	}
+
+	m.keys = make([][]key, len(m.Rows))
+	for i := range m.keys {
+		m.keys[i] = make([]key, len(m.Cols))
+	}
+
+	return nil
+}
+
+// Start starts scanning the keypad and sending events on C.
+func (m *Matrix) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started {
+		return nil
+	}
+
+	if err := m.setup(); err != nil {
+		return err
+	}
+
+	m.wake = make(chan struct{}, 1)
+	m.watching = true
+	for i, pin := range m.Rows {
+		if err := pin.Watch(embd.EdgeFalling, m.interrupt); err != nil {
+			glog.V(1).Infof("matrix: polling the keypad, watching row %v: %v", i, err)
+			for _, pin := range m.Rows[:i] {
+				pin.StopWatching()
+			}
+			m.watching = false
+			break
+		}
+	}
+
+	m.quit = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run()
+
+	m.started = true
+
+	return nil
+}
+
+// interrupt handles the interrupts of the row pins.
+func (m *Matrix) interrupt(embd.DigitalPin) {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Matrix) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(duration(m.ScanInterval, DefaultScanInterval))
+	defer ticker.Stop()
+
+	active := true
+	for {
+		if !active && m.watching {
+			idle, err := m.idle()
+			if err != nil {
+				glog.Errorf("matrix: arming the keypad: %v", err)
+			}
+			if idle {
+				select {
+				case <-m.wake:
+				case <-m.quit:
+					return
+				}
+			}
+			if err := m.release(); err != nil {
+				glog.Errorf("matrix: releasing the columns: %v", err)
+			}
+		}
+
+		events, act, err := m.scan(m.now())
+		if err != nil {
+			glog.Errorf("matrix: scanning the keypad: %v", err)
+		}
+		active = act || err != nil
+		for _, e := range events {
+			select {
+			case m.c <- e:
+			case <-m.quit:
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// idle selects all the columns so that pressing any key interrupts, and
+// reports whether no key is pressed. The columns are left selected until
+// release is called.
+func (m *Matrix) idle() (bool, error) {
+	for _, pin := range m.Cols {
+		if err := selectCol(pin); err != nil {
+			return false, err
+		}
+	}
+	// Drop the interrupts caused by the scans.
+	select {
+	case <-m.wake:
+	default:
+	}
+
+	idle := true
+	for _, pin := range m.Rows {
+		v, err := pin.Read()
+		if err != nil {
+			return false, err
+		}
+		if v == embd.Low {
+			idle = false
+		}
+	}
+	return idle, nil
+}
+
+// release leaves all the columns floating.
+func (m *Matrix) release() error {
+	for _, pin := range m.Cols {
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func selectCol(pin embd.DigitalPin) error {
+	if err := pin.SetDirection(embd.Out); err != nil {
+		return err
+	}
+	return pin.Write(embd.Low)
+}
+
+// read scans the keypad, returning the columns found pressed in each row.
+func (m *Matrix) read() ([]uint64, error) {
+	rows := make([]uint64, len(m.Rows))
+	for c, col := range m.Cols {
+		if err := selectCol(col); err != nil {
+			return nil, err
+		}
+		for r, row := range m.Rows {
+			v, err := row.Read()
+			if err != nil {
+				col.SetDirection(embd.In)
+				return nil, err
+			}
+			if v == embd.Low {
+				rows[r] |= 1 << uint(c)
+			}
+		}
+		if err := col.SetDirection(embd.In); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// ambiguous reports whether pressed keys are on the corners of a
+// rectangle, in which case one of them may be a ghost.
+func ambiguous(rows []uint64) bool {
+	for i := range rows {
+		for j := i + 1; j < len(rows); j++ {
+			common := rows[i] & rows[j]
+			if common&(common-1) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scan scans the keypad at t and returns the resulting events, and
+// whether any key is pressed or bouncing.
+func (m *Matrix) scan(t time.Time) ([]Event, bool, error) {
+	rows, err := m.read()
+	if err != nil {
+		return nil, false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.Diodes && ambiguous(rows) {
+		glog.V(2).Infof("matrix: ignoring ambiguous scan")
+		rows = nil
+	}
+
+	debounce := duration(m.Debounce, DefaultDebounce)
+	long := duration(m.LongPress, DefaultLongPress)
+	delay := duration(m.RepeatDelay, DefaultRepeatDelay)
+	interval := duration(m.RepeatInterval, DefaultRepeatInterval)
+
+	var events []Event
+	active := false
+	for r := range m.keys {
+		for c := range m.keys[r] {
+			k := &m.keys[r][c]
+			e := Event{Key: m.Keymap[r][c], Row: r, Col: c, Time: t}
+
+			if rows != nil {
+				if raw := rows[r]&(1<<uint(c)) != 0; raw != k.raw {
+					k.raw, k.rawSince = raw, t
+				}
+			}
+			if k.raw != k.pressed && t.Sub(k.rawSince) >= debounce {
+				k.pressed = k.raw
+				if k.pressed {
+					k.since, k.long = t, false
+					k.repeat = t.Add(delay)
+					e.Type = Press
+				} else {
+					e.Type, e.Held = Release, t.Sub(k.since)
+				}
+				events = append(events, e)
+			} else if k.pressed {
+				e.Held = t.Sub(k.since)
+				if long >= 0 && !k.long && e.Held >= long {
+					k.long = true
+					e.Type = LongPress
+					events = append(events, e)
+				}
+				if delay >= 0 && interval > 0 && !t.Before(k.repeat) {
+					k.repeat = k.repeat.Add(interval)
+					if k.repeat.Before(t) {
+						k.repeat = t.Add(interval)
+					}
+					e.Type = Repeat
+					events = append(events, e)
+				}
+			}
+			if k.raw || k.pressed {
+				active = true
+			}
+		}
+	}
+	return events, active, nil
+}
+
+// Pressed returns the names of the keys currently held, row by row.
+func (m *Matrix) Pressed() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for r := range m.keys {
+		for c := range m.keys[r] {
+			if m.keys[r][c].pressed {
+				keys = append(keys, m.Keymap[r][c])
+			}
+		}
+	}
+	return keys
+}
+
+// Stop stops scanning the keypad. The pins belong to the caller and are
+// left open.
+func (m *Matrix) Stop() error {
+	m.mu.Lock()
+	if !m.started {
+		m.mu.Unlock()
+		return nil
+	}
+	m.started = false
+	m.mu.Unlock()
+
+	close(m.quit)
+	<-m.done
+
+	if m.watching {
+		for _, pin := range m.Rows {
+			if err := pin.StopWatching(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
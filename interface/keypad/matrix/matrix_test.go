@@ -0,0 +1,312 @@
+package matrix
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+var keymap = [][]string{
+	{"1", "2", "3"},
+	{"4", "5", "6"},
+	{"7", "8", "9"},
+	{"*", "0", "#"},
+}
+
+// keypad emulates the wiring of a 4x3 keypad: a pressed key connects its
+// row and column lines. Without diodes, current also flows backwards
+// through the keys, so a row is pulled low by any selected column it is
+// connected to through pressed keys.
+type keypad struct {
+	rows, cols []*sim.Pin
+	diodes     bool
+
+	mu      sync.Mutex
+	pressed map[[2]int]bool
+}
+
+func newKeypad(diodes bool) *keypad {
+	k := &keypad{diodes: diodes, pressed: map[[2]int]bool{}}
+	for i := 0; i < 4; i++ {
+		k.rows = append(k.rows, sim.NewPin(i))
+	}
+	for i := 0; i < 3; i++ {
+		col := sim.NewPin(4 + i)
+		// Keep the floating columns high so that selecting one is seen.
+		col.SetPull(sim.PullUp)
+		col.OnChange(func(int) { k.update() })
+		k.cols = append(k.cols, col)
+	}
+	return k
+}
+
+func (k *keypad) pins() (rows, cols []embd.DigitalPin) {
+	for _, p := range k.rows {
+		rows = append(rows, p)
+	}
+	for _, p := range k.cols {
+		cols = append(cols, p)
+	}
+	return
+}
+
+func (k *keypad) set(row, col int, pressed bool) {
+	k.mu.Lock()
+	k.pressed[[2]int{row, col}] = pressed
+	k.mu.Unlock()
+
+	k.update()
+}
+
+// update drives the rows according to the selected columns.
+func (k *keypad) update() {
+	k.mu.Lock()
+	low := make([]bool, len(k.rows))
+	for c, col := range k.cols {
+		if col.Direction() != embd.Out || col.Level() != embd.Low {
+			continue
+		}
+		if k.diodes {
+			for r := range k.rows {
+				low[r] = low[r] || k.pressed[[2]int{r, c}]
+			}
+			continue
+		}
+		// Walk the lines connected to the column.
+		seenRows := make([]bool, len(k.rows))
+		seenCols := make([]bool, len(k.cols))
+		seenCols[c] = true
+		for changed := true; changed; {
+			changed = false
+			for key, p := range k.pressed {
+				if !p || seenRows[key[0]] == seenCols[key[1]] {
+					continue
+				}
+				seenRows[key[0]], seenCols[key[1]] = true, true
+				changed = true
+			}
+		}
+		for r := range low {
+			low[r] = low[r] || seenRows[r]
+		}
+	}
+	k.mu.Unlock()
+
+	for r, row := range k.rows {
+		if low[r] {
+			row.Drive(embd.Low)
+		} else {
+			row.Release()
+		}
+	}
+}
+
+type testMatrix struct {
+	*Matrix
+	pad *keypad
+	t   time.Time
+}
+
+func newTestMatrix(t *testing.T, diodes bool) *testMatrix {
+	pad := newKeypad(diodes)
+	rows, cols := pad.pins()
+	m := &testMatrix{Matrix: New(rows, cols, keymap), pad: pad, t: time.Unix(0, 0)}
+	m.Diodes = diodes
+	if err := m.setup(); err != nil {
+		t.Fatalf("Setting up keypad: got %v", err)
+	}
+	return m
+}
+
+// advance scans the keypad every 10ms for d.
+func (m *testMatrix) advance(t *testing.T, d time.Duration) []Event {
+	var all []Event
+	for end := m.t.Add(d); m.t.Before(end); {
+		m.t = m.t.Add(10 * time.Millisecond)
+		events, _, err := m.scan(m.t)
+		if err != nil {
+			t.Fatalf("Scanning keypad: got %v", err)
+		}
+		all = append(all, events...)
+	}
+	return all
+}
+
+func types(events []Event) []string {
+	var s []string
+	for _, e := range events {
+		s = append(s, e.Key+" "+e.Type.String())
+	}
+	return s
+}
+
+func TestPressRelease(t *testing.T) {
+	m := newTestMatrix(t, false)
+	m.LongPress = -1
+	m.RepeatDelay = -1
+
+	m.pad.set(1, 1, true)
+	events := m.advance(t, 100*time.Millisecond)
+	if got, want := types(events), []string{"5 press"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pressing 5: got events %q, want %q", got, want)
+	}
+	if e := events[0]; e.Row != 1 || e.Col != 1 || e.Time != time.Unix(0, int64(30*time.Millisecond)) {
+		t.Errorf("Pressing 5: got %+v", e)
+	}
+	if got := m.Pressed(); !reflect.DeepEqual(got, []string{"5"}) {
+		t.Errorf("Holding 5: got pressed %q", got)
+	}
+
+	m.pad.set(1, 1, false)
+	events = m.advance(t, 100*time.Millisecond)
+	if got, want := types(events), []string{"5 release"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Releasing 5: got events %q, want %q", got, want)
+	}
+	if held := events[0].Held; held != 100*time.Millisecond {
+		t.Errorf("Releasing 5: got held %v, want 100ms", held)
+	}
+}
+
+func TestBounce(t *testing.T) {
+	m := newTestMatrix(t, false)
+
+	m.pad.set(0, 0, true)
+	events := m.advance(t, 10*time.Millisecond)
+	m.pad.set(0, 0, false)
+	events = append(events, m.advance(t, 100*time.Millisecond)...)
+	if len(events) != 0 {
+		t.Errorf("Bouncing 1: got events %q, want none", types(events))
+	}
+}
+
+func TestLongPressRepeat(t *testing.T) {
+	m := newTestMatrix(t, false)
+	m.LongPress = 300 * time.Millisecond
+	m.RepeatDelay = 200 * time.Millisecond
+	m.RepeatInterval = 50 * time.Millisecond
+
+	m.pad.set(3, 2, true)
+	events := m.advance(t, 350*time.Millisecond)
+	want := []string{"# press", "# repeat", "# repeat", "# long press", "# repeat"}
+	if got := types(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Holding #: got events %q, want %q", got, want)
+	}
+	if held := events[3].Held; held != 300*time.Millisecond {
+		t.Errorf("Long press of #: got held %v, want 300ms", held)
+	}
+}
+
+func TestRollover(t *testing.T) {
+	m := newTestMatrix(t, true)
+	m.LongPress = -1
+	m.RepeatDelay = -1
+
+	m.pad.set(0, 0, true)
+	m.pad.set(0, 1, true)
+	m.pad.set(1, 0, true)
+	events := m.advance(t, 100*time.Millisecond)
+	if got, want := types(events), []string{"1 press", "2 press", "4 press"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pressing 1, 2 and 4: got events %q, want %q", got, want)
+	}
+	m.pad.set(1, 1, true)
+	events = m.advance(t, 100*time.Millisecond)
+	if got, want := types(events), []string{"5 press"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pressing 5: got events %q, want %q", got, want)
+	}
+}
+
+func TestGhosting(t *testing.T) {
+	m := newTestMatrix(t, false)
+	m.LongPress = -1
+	m.RepeatDelay = -1
+
+	m.pad.set(0, 0, true)
+	m.pad.set(0, 1, true)
+	events := m.advance(t, 100*time.Millisecond)
+	if got, want := types(events), []string{"1 press", "2 press"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pressing 1 and 2: got events %q, want %q", got, want)
+	}
+
+	// 5 appears pressed too.
+	m.pad.set(1, 0, true)
+	events = m.advance(t, 100*time.Millisecond)
+	if len(events) != 0 {
+		t.Fatalf("Pressing 4 with 1 and 2: got events %q, want none", types(events))
+	}
+
+	m.pad.set(0, 1, false)
+	events = m.advance(t, 100*time.Millisecond)
+	if got, want := types(events), []string{"2 release", "4 press"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Releasing 2: got events %q, want %q", got, want)
+	}
+}
+
+// noInterruptPin is a pin which does not support interrupts.
+type noInterruptPin struct {
+	*sim.Pin
+}
+
+func (noInterruptPin) Watch(embd.Edge, func(embd.DigitalPin)) error {
+	return errors.New("interrupts not supported")
+}
+
+func testStart(t *testing.T, m *Matrix, pad *keypad) {
+	m.ScanInterval = time.Millisecond
+	m.Debounce = 2 * time.Millisecond
+	m.LongPress = -1
+	m.RepeatDelay = -1
+	if err := m.Start(); err != nil {
+		t.Fatalf("Starting keypad: got %v", err)
+	}
+	defer m.Stop()
+
+	for _, want := range []Event{{Type: Press, Key: "8"}, {Type: Release, Key: "8"}} {
+		pad.set(2, 1, want.Type == Press)
+		select {
+		case e := <-m.C:
+			if e.Type != want.Type || e.Key != want.Key {
+				t.Errorf("Waiting for %v %v: got %v %v", want.Key, want.Type, e.Key, e.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Waiting for %v %v: timed out", want.Key, want.Type)
+		}
+	}
+}
+
+func TestStartInterrupts(t *testing.T) {
+	pad := newKeypad(false)
+	rows, cols := pad.pins()
+	m := New(rows, cols, keymap)
+	testStart(t, m, pad)
+	if !m.watching {
+		t.Error("Starting keypad: got polling, want interrupts")
+	}
+}
+
+func TestStartPolling(t *testing.T) {
+	pad := newKeypad(false)
+	rows, cols := pad.pins()
+	for i, p := range pad.rows {
+		rows[i] = noInterruptPin{p}
+	}
+	m := New(rows, cols, keymap)
+	testStart(t, m, pad)
+	if m.watching {
+		t.Error("Starting keypad without interrupts: got interrupts, want polling")
+	}
+}
+
+func TestKeymapSize(t *testing.T) {
+	pad := newKeypad(false)
+	rows, cols := pad.pins()
+	m := New(rows, cols, keymap[:3])
+	if err := m.Start(); err == nil {
+		m.Stop()
+		t.Error("Starting keypad with a 3x3 key map: got nil error")
+	}
+}
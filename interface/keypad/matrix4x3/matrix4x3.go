@@ -1,4 +1,6 @@
 // Package matrix4x3 allows interfacing 4x3 keypad with Raspberry pi.
+//
+// See package matrix for keypads of any size.
 package matrix4x3
 
 import (
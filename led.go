@@ -25,42 +25,75 @@ type LEDDriver interface {
 	Close() error
 }
 
-var ledDriverInitialized bool
-var ledDriverInstance LEDDriver
+// InitLED initializes the LED driver of the board.
+func (b *Board) InitLED() error {
+	_, err := b.LEDDriver()
+	return err
+}
 
-// InitLED initializes the LED driver.
-func InitLED() error {
-	if ledDriverInitialized {
-		return nil
+// LEDDriver returns the LED driver of the board, initializing it if
+// required.
+func (b *Board) LEDDriver() (LEDDriver, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ledDriver != nil {
+		return b.ledDriver, nil
 	}
 
-	desc, err := DescribeHost()
+	desc, err := b.descriptor()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if desc.LEDDriver == nil {
-		return ErrFeatureNotSupported
+		return nil, ErrFeatureNotSupported
+	}
+
+	b.ledDriver = desc.LEDDriver()
+
+	return b.ledDriver, nil
+}
+
+// CloseLED releases resources associated with the LED driver of the board.
+func (b *Board) CloseLED() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ledDriver == nil {
+		return nil
 	}
 
-	ledDriverInstance = desc.LEDDriver()
-	ledDriverInitialized = true
+	err := b.ledDriver.Close()
+	b.ledDriver = nil
 
-	return nil
+	return err
+}
+
+// NewLED returns a LED interface which allows control over the LED of
+// the board.
+func (b *Board) NewLED(key interface{}) (LED, error) {
+	drv, err := b.LEDDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return drv.LED(key)
+}
+
+// InitLED initializes the LED driver.
+func InitLED() error {
+	return defaultBoard.InitLED()
 }
 
 // CloseLED releases resources associated with the LED driver.
 func CloseLED() error {
-	return ledDriverInstance.Close()
+	return defaultBoard.CloseLED()
 }
 
 // NewLED returns a LED interface which allows control over the LED.
 func NewLED(key interface{}) (LED, error) {
-	if err := InitLED(); err != nil {
-		return nil, err
-	}
-
-	return ledDriverInstance.LED(key)
+	return defaultBoard.NewLED(key)
 }
 
 // LEDOn switches the LED on.
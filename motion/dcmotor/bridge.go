@@ -0,0 +1,183 @@
+// H-bridge wiring profiles.
+
+package dcmotor
+
+import "github.com/kidoman/embd"
+
+// Bridge drives the outputs of an H-bridge connected to a motor.
+type Bridge interface {
+	// Drive drives the motor forward or backward with duty, from 0 to
+	// 1, of the supply voltage.
+	Drive(forward bool, duty float64) error
+
+	// Brake shorts the terminals of the motor, stopping it quickly.
+	Brake() error
+
+	// Coast leaves the terminals of the motor floating, letting it spin
+	// down freely.
+	Coast() error
+
+	// Close coasts the motor. The pins remain owned by the caller.
+	Close() error
+}
+
+func setDuty(pwm embd.PWMPin, period int, duty float64) error {
+	return pwm.SetDuty(int(duty*float64(period) + 0.5))
+}
+
+// EnableBridge drives an H-bridge through a PWM enable input and two
+// direction inputs, like the L298N and the TB6612FNG.
+type EnableBridge struct {
+	pwm      embd.PWMPin
+	in1, in2 embd.DigitalPin
+	standby  embd.DigitalPin
+	period   int
+
+	// coastDisables is set when the motor coasts with the enable input
+	// low, rather than with both direction inputs low.
+	coastDisables bool
+}
+
+func newEnableBridge(pwm embd.PWMPin, in1, in2, standby embd.DigitalPin, period int) (*EnableBridge, error) {
+	b := &EnableBridge{pwm: pwm, in1: in1, in2: in2, standby: standby, period: period}
+	pins := []embd.DigitalPin{in1, in2}
+	if standby != nil {
+		pins = append(pins, standby)
+	}
+	for _, pin := range pins {
+		if err := pin.SetDirection(embd.Out); err != nil {
+			return nil, err
+		}
+	}
+	if err := pwm.SetPeriod(period); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// NewL298N returns a bridge driving a channel of an L298N (or L293D)
+// through its EN input, with PWM of the given period in ns, and its IN1
+// and IN2 inputs.
+func NewL298N(en embd.PWMPin, in1, in2 embd.DigitalPin, period int) (*EnableBridge, error) {
+	b, err := newEnableBridge(en, in1, in2, nil, period)
+	if err != nil {
+		return nil, err
+	}
+	b.coastDisables = true
+	return b, b.Coast()
+}
+
+// NewTB6612 returns a bridge driving a channel of a TB6612FNG through its
+// PWM input, with PWM of the given period in ns, and its IN1 and IN2
+// inputs. The standby pin is optional; when given, it is driven high to
+// enable the chip and low when the bridge is closed.
+func NewTB6612(pwm embd.PWMPin, in1, in2, standby embd.DigitalPin, period int) (*EnableBridge, error) {
+	b, err := newEnableBridge(pwm, in1, in2, standby, period)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Coast(); err != nil {
+		return nil, err
+	}
+	if standby != nil {
+		if err := standby.Write(embd.High); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *EnableBridge) write(in1, in2 int, duty float64) error {
+	if err := b.in1.Write(in1); err != nil {
+		return err
+	}
+	if err := b.in2.Write(in2); err != nil {
+		return err
+	}
+	return setDuty(b.pwm, b.period, duty)
+}
+
+// Drive drives the motor forward (IN1 high) or backward (IN2 high) with
+// duty.
+func (b *EnableBridge) Drive(forward bool, duty float64) error {
+	if forward {
+		return b.write(embd.High, embd.Low, duty)
+	}
+	return b.write(embd.Low, embd.High, duty)
+}
+
+// Brake drives both direction inputs high with the bridge enabled.
+func (b *EnableBridge) Brake() error {
+	return b.write(embd.High, embd.High, 1)
+}
+
+// Coast disables the bridge.
+func (b *EnableBridge) Coast() error {
+	if b.coastDisables {
+		return b.write(embd.Low, embd.Low, 0)
+	}
+	return b.write(embd.Low, embd.Low, 1)
+}
+
+// Close coasts the motor and puts the chip in standby if it has a standby
+// pin.
+func (b *EnableBridge) Close() error {
+	if err := b.Coast(); err != nil {
+		return err
+	}
+	if b.standby != nil {
+		return b.standby.Write(embd.Low)
+	}
+	return nil
+}
+
+// InputBridge drives an H-bridge through two PWM inputs, like the DRV8833,
+// DRV8871 and MX1508, in fast decay mode: the motor coasts during the off
+// time of the PWM.
+type InputBridge struct {
+	in1, in2 embd.PWMPin
+	period   int
+}
+
+// NewDRV8833 returns a bridge driving a channel of a DRV8833 through its
+// IN1 and IN2 inputs, with PWM of the given period in ns.
+func NewDRV8833(in1, in2 embd.PWMPin, period int) (*InputBridge, error) {
+	b := &InputBridge{in1: in1, in2: in2, period: period}
+	for _, pwm := range []embd.PWMPin{in1, in2} {
+		if err := pwm.SetPeriod(period); err != nil {
+			return nil, err
+		}
+	}
+	return b, b.Coast()
+}
+
+func (b *InputBridge) write(duty1, duty2 float64) error {
+	if err := setDuty(b.in1, b.period, duty1); err != nil {
+		return err
+	}
+	return setDuty(b.in2, b.period, duty2)
+}
+
+// Drive drives the motor forward (PWM on IN1) or backward (PWM on IN2)
+// with duty.
+func (b *InputBridge) Drive(forward bool, duty float64) error {
+	if forward {
+		return b.write(duty, 0)
+	}
+	return b.write(0, duty)
+}
+
+// Brake drives both inputs high.
+func (b *InputBridge) Brake() error {
+	return b.write(1, 1)
+}
+
+// Coast drives both inputs low.
+func (b *InputBridge) Coast() error {
+	return b.write(0, 0)
+}
+
+// Close coasts the motor.
+func (b *InputBridge) Close() error {
+	return b.Coast()
+}
@@ -0,0 +1,186 @@
+// Package dcmotor allows control of brushed DC motors through H-bridges.
+//
+// A Bridge drives the outputs of an H-bridge according to its wiring:
+// EnableBridge drives boards with a PWM enable input and two direction
+// inputs, such as the L298N and TB6612FNG, while InputBridge drives boards
+// with two PWM inputs, such as the DRV8833. The PWM can come from any
+// embd.PWMPin, including the channels of a PCA9685.
+//
+// A Motor runs a bridge at a speed from -1 (full backward) to 1 (full
+// forward), immediately or ramping within its acceleration limit, and
+// stops it by braking or coasting.
+package dcmotor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// RampStep is the interval between speed updates while ramping.
+const RampStep = 10 * time.Millisecond
+
+// ErrBusy is returned when changing the speed of a motor which is ramping.
+var ErrBusy = errors.New("dcmotor: motor is ramping")
+
+// Motor is a DC motor driven by an H-bridge.
+type Motor struct {
+	Bridge Bridge
+
+	// Acceleration limits the rate of change of the speed in RampTo, in
+	// full scale per second. RampTo sets the speed immediately if 0.
+	Acceleration float64
+
+	// MinDuty is the duty below which the motor stalls. Non-zero speeds
+	// are scaled to duties from MinDuty to 1.
+	MinDuty float64
+
+	// Invert reverses the direction of the motor.
+	Invert bool
+
+	mu      sync.Mutex
+	speed   float64
+	ramping bool
+}
+
+// New creates a new motor driven by b, at rest.
+func New(b Bridge) *Motor {
+	return &Motor{Bridge: b}
+}
+
+// Speed returns the speed of the motor, 0 after Brake and Coast.
+func (m *Motor) Speed() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.speed
+}
+
+func (m *Motor) drive(speed float64) error {
+	if speed < -1 || speed > 1 || math.IsNaN(speed) {
+		return fmt.Errorf("dcmotor: invalid speed %v", speed)
+	}
+
+	var err error
+	if speed == 0 {
+		err = m.Bridge.Coast()
+	} else {
+		forward := (speed > 0) != m.Invert
+		duty := m.MinDuty + math.Abs(speed)*(1-m.MinDuty)
+		err = m.Bridge.Drive(forward, duty)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.speed = speed
+	m.mu.Unlock()
+
+	glog.V(2).Infof("dcmotor: speed set to %v", speed)
+
+	return nil
+}
+
+func (m *Motor) acquire() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ramping {
+		return ErrBusy
+	}
+	m.ramping = true
+	return nil
+}
+
+func (m *Motor) release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ramping = false
+}
+
+// SetSpeed sets the speed of the motor immediately, from -1 to 1. A speed
+// of 0 coasts the motor.
+func (m *Motor) SetSpeed(speed float64) error {
+	if err := m.acquire(); err != nil {
+		return err
+	}
+	defer m.release()
+
+	return m.drive(speed)
+}
+
+// RampTo changes the speed of the motor to speed within Acceleration,
+// returning once it is reached or ctx is done.
+func (m *Motor) RampTo(ctx context.Context, speed float64) error {
+	if speed < -1 || speed > 1 || math.IsNaN(speed) {
+		return fmt.Errorf("dcmotor: invalid speed %v", speed)
+	}
+	if err := m.acquire(); err != nil {
+		return err
+	}
+	defer m.release()
+
+	if m.Acceleration <= 0 {
+		return m.drive(speed)
+	}
+
+	glog.V(1).Infof("dcmotor: ramping from %v to %v", m.Speed(), speed)
+
+	delta := m.Acceleration * RampStep.Seconds()
+	t := time.NewTicker(RampStep)
+	defer t.Stop()
+	for {
+		cur := m.Speed()
+		next := speed
+		if d := speed - cur; d > delta {
+			next = cur + delta
+		} else if d < -delta {
+			next = cur - delta
+		}
+		if err := m.drive(next); err != nil {
+			return err
+		}
+		if next == speed {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Brake stops the motor quickly by shorting its terminals.
+func (m *Motor) Brake() error {
+	if err := m.acquire(); err != nil {
+		return err
+	}
+	defer m.release()
+
+	if err := m.Bridge.Brake(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.speed = 0
+	m.mu.Unlock()
+	return nil
+}
+
+// Coast lets the motor spin down freely.
+func (m *Motor) Coast() error {
+	return m.SetSpeed(0)
+}
+
+// Close coasts the motor and closes its bridge.
+func (m *Motor) Close() error {
+	return m.Bridge.Close()
+}
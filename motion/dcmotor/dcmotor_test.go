@@ -0,0 +1,196 @@
+package dcmotor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// fakePWM records the period and duty it is set to.
+type fakePWM struct {
+	mu     sync.Mutex
+	period int
+	duty   int
+}
+
+func (p *fakePWM) N() string { return "fake" }
+
+func (p *fakePWM) SetPeriod(ns int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.period = ns
+	return nil
+}
+
+func (p *fakePWM) SetDuty(ns int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.duty = ns
+	return nil
+}
+
+func (p *fakePWM) Duty() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.duty
+}
+
+func (p *fakePWM) SetPolarity(pol embd.Polarity) error { return nil }
+func (p *fakePWM) SetMicroseconds(us int) error        { return nil }
+func (p *fakePWM) SetAnalog(value byte) error          { return nil }
+func (p *fakePWM) Close() error                        { return nil }
+
+const period = 1000000
+
+func TestL298N(t *testing.T) {
+	en, in1, in2 := &fakePWM{}, sim.NewPin(0), sim.NewPin(1)
+	b, err := NewL298N(en, in1, in2, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if en.period != period {
+		t.Errorf("Period: got %v, want %v", en.period, period)
+	}
+	m := New(b)
+
+	tests := []struct {
+		name     string
+		op       func() error
+		in1, in2 int
+		duty     int
+	}{
+		{"SetSpeed(0.5)", func() error { return m.SetSpeed(0.5) }, embd.High, embd.Low, period / 2},
+		{"SetSpeed(-0.25)", func() error { return m.SetSpeed(-0.25) }, embd.Low, embd.High, period / 4},
+		{"Brake", m.Brake, embd.High, embd.High, period},
+		{"Coast", m.Coast, embd.Low, embd.Low, 0},
+	}
+	for _, test := range tests {
+		if err := test.op(); err != nil {
+			t.Fatal(err)
+		}
+		if in1.Level() != test.in1 || in2.Level() != test.in2 || en.Duty() != test.duty {
+			t.Errorf("%v: got IN1 %v, IN2 %v, EN duty %v, want %v, %v, %v", test.name, in1.Level(), in2.Level(), en.Duty(), test.in1, test.in2, test.duty)
+		}
+	}
+
+	if err := m.SetSpeed(1.5); err == nil {
+		t.Error("SetSpeed(1.5): got no error")
+	}
+}
+
+func TestTB6612(t *testing.T) {
+	pwm, in1, in2, stby := &fakePWM{}, sim.NewPin(0), sim.NewPin(1), sim.NewPin(2)
+	b, err := NewTB6612(pwm, in1, in2, stby, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stby.Level() != embd.High {
+		t.Error("STBY: got low, want high")
+	}
+	m := New(b)
+	m.Invert = true
+	m.MinDuty = 0.2
+
+	if err := m.SetSpeed(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if in1.Level() != embd.Low || in2.Level() != embd.High || pwm.Duty() != period*6/10 {
+		t.Errorf("Inverted SetSpeed(0.5): got IN1 %v, IN2 %v, duty %v", in1.Level(), in2.Level(), pwm.Duty())
+	}
+
+	// The TB6612 brakes during the off time of the PWM, so coasting keeps
+	// it on.
+	if err := m.Coast(); err != nil {
+		t.Fatal(err)
+	}
+	if in1.Level() != embd.Low || in2.Level() != embd.Low || pwm.Duty() != period {
+		t.Errorf("Coast: got IN1 %v, IN2 %v, duty %v", in1.Level(), in2.Level(), pwm.Duty())
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stby.Level() != embd.Low {
+		t.Error("STBY: got high after Close, want low")
+	}
+}
+
+func TestDRV8833(t *testing.T) {
+	in1, in2 := &fakePWM{}, &fakePWM{}
+	b, err := NewDRV8833(in1, in2, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(b)
+
+	tests := []struct {
+		name         string
+		op           func() error
+		duty1, duty2 int
+	}{
+		{"SetSpeed(0.75)", func() error { return m.SetSpeed(0.75) }, period * 3 / 4, 0},
+		{"SetSpeed(-1)", func() error { return m.SetSpeed(-1) }, 0, period},
+		{"Brake", m.Brake, period, period},
+		{"Coast", m.Coast, 0, 0},
+	}
+	for _, test := range tests {
+		if err := test.op(); err != nil {
+			t.Fatal(err)
+		}
+		if in1.Duty() != test.duty1 || in2.Duty() != test.duty2 {
+			t.Errorf("%v: got duties %v and %v, want %v and %v", test.name, in1.Duty(), in2.Duty(), test.duty1, test.duty2)
+		}
+	}
+}
+
+func TestRampTo(t *testing.T) {
+	in1, in2 := &fakePWM{}, &fakePWM{}
+	b, err := NewDRV8833(in1, in2, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(b)
+	m.Acceleration = 10
+
+	// From 0.5 to -0.5 at 10/s takes 100ms.
+	if err := m.SetSpeed(0.5); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := m.RampTo(context.Background(), -0.5); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("RampTo(-0.5): took %v, want at least 90ms", d)
+	}
+	if s := m.Speed(); s != -0.5 {
+		t.Errorf("Speed: got %v, want -0.5", s)
+	}
+	if in1.Duty() != 0 || in2.Duty() != period/2 {
+		t.Errorf("RampTo(-0.5): got duties %v and %v", in1.Duty(), in2.Duty())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- m.RampTo(ctx, 0.5)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	if err := m.SetSpeed(0); err != ErrBusy {
+		t.Errorf("SetSpeed while ramping: got %v, want %v", err, ErrBusy)
+	}
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Fatalf("Canceled ramp: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if s := m.Speed(); s <= -0.5 || s >= 0.5 {
+		t.Errorf("Canceled ramp: got speed %v", s)
+	}
+}
@@ -0,0 +1,71 @@
+// Continuous rotation servo support.
+
+package servo
+
+import (
+	"math"
+
+	"github.com/golang/glog"
+)
+
+const (
+	centerus = 1500
+	rangeus  = 500
+)
+
+// Continuous represents a continuous rotation servo, whose pulse width
+// sets the speed and direction of rotation rather than the position.
+type Continuous struct {
+	PWM PWM
+
+	// Centerus is the pulse width stopping the servo. Rangeus is the
+	// difference in pulse width between stopped and full speed.
+	Centerus, Rangeus int
+
+	// Deadband is the width in us on either side of Centerus within which
+	// the servo does not turn. Speeds other than 0 are mapped beyond it.
+	Deadband int
+
+	// Invert reverses the direction of rotation.
+	Invert bool
+}
+
+// NewContinuous creates a new continuous rotation servo interface.
+func NewContinuous(pwm PWM) *Continuous {
+	return &Continuous{
+		PWM:      pwm,
+		Centerus: centerus,
+		Rangeus:  rangeus,
+	}
+}
+
+// Microseconds returns the pulse width turning the servo at speed.
+func (c *Continuous) Microseconds(speed float64) int {
+	speed = math.Max(-1, math.Min(1, speed))
+	if c.Invert {
+		speed = -speed
+	}
+	us := float64(c.Centerus)
+	switch {
+	case speed > 0:
+		us += float64(c.Deadband) + speed*float64(c.Rangeus-c.Deadband)
+	case speed < 0:
+		us -= float64(c.Deadband) - speed*float64(c.Rangeus-c.Deadband)
+	}
+	return int(math.Floor(us + 0.5))
+}
+
+// SetSpeed sets the speed of the servo, from -1 (full speed backward) to 1
+// (full speed forward).
+func (c *Continuous) SetSpeed(speed float64) error {
+	us := c.Microseconds(speed)
+
+	glog.V(1).Infof("servo: given speed %v calculated %v us", speed, us)
+
+	return c.PWM.SetMicroseconds(us)
+}
+
+// Stop stops the servo.
+func (c *Continuous) Stop() error {
+	return c.SetSpeed(0)
+}
@@ -0,0 +1,332 @@
+// Smooth motion support.
+
+package servo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// DefaultStep is the interval between updates of the servos during moves,
+// the period of the pulses at DefaultFreq.
+const DefaultStep = time.Second / DefaultFreq
+
+// ErrInterrupted is returned by Move.Wait when the move was interrupted
+// by another move or by setting the angle of one of its servos.
+var ErrInterrupted = errors.New("servo: move interrupted")
+
+// ErrCanceled is returned by Move.Wait when the move was canceled.
+var ErrCanceled = errors.New("servo: move canceled")
+
+// ErrDuplicate is returned by Move.Wait when the same servo was given
+// more than one target.
+var ErrDuplicate = errors.New("servo: servo targeted twice in one move")
+
+// Profile shapes the velocity of moves.
+type Profile int
+
+const (
+	// Trapezoidal accelerates at MaxAcceleration up to MaxVelocity,
+	// cruises, and decelerates at MaxAcceleration.
+	Trapezoidal Profile = iota
+
+	// SCurve follows a minimum jerk trajectory, whose acceleration
+	// changes smoothly, within MaxVelocity and MaxAcceleration.
+	SCurve
+)
+
+// S-curve peak velocity and acceleration for a unit move in unit time.
+const (
+	sCurveVelocity     = 1.875
+	sCurveAcceleration = 5.7735
+)
+
+// plan returns the shortest duration in seconds of a move over dist
+// degrees and, for trapezoidal moves, the fraction of it spent
+// accelerating.
+func (s *Servo) plan(dist float64) (float64, float64) {
+	v, a := s.MaxVelocity, s.MaxAcceleration
+	if dist == 0 || (v <= 0 && a <= 0) {
+		return 0, 0
+	}
+
+	if s.Profile == SCurve {
+		var t float64
+		if v > 0 {
+			t = sCurveVelocity * dist / v
+		}
+		if a > 0 {
+			t = math.Max(t, math.Sqrt(sCurveAcceleration*dist/a))
+		}
+		return t, 0
+	}
+
+	switch {
+	case a <= 0:
+		return dist / v, 0
+	case v <= 0 || dist <= v*v/a:
+		// Triangular: the velocity limit is never reached.
+		return 2 * math.Sqrt(dist/a), 0.5
+	}
+	t := dist/v + v/a
+	return t, v / a / t
+}
+
+// shape returns the fraction of the distance covered at the fraction f of
+// the duration of a move.
+func shape(profile Profile, r, f float64) float64 {
+	switch {
+	case f <= 0:
+		return 0
+	case f >= 1:
+		return 1
+	}
+	if profile == SCurve {
+		return f * f * f * (10 - 15*f + 6*f*f)
+	}
+	if r == 0 {
+		return f
+	}
+	v := 1 / (1 - r)
+	switch {
+	case f < r:
+		return v * f * f / (2 * r)
+	case f > 1-r:
+		return 1 - v*(1-f)*(1-f)/(2*r)
+	}
+	return v * (f - r/2)
+}
+
+// Target is the angle a servo moves to.
+type Target struct {
+	Servo *Servo
+	Angle float64
+}
+
+type axis struct {
+	s        *Servo
+	from, to float64
+	r        float64
+}
+
+// Move is a move of one or more servos executed in the background.
+type Move struct {
+	sched    *Scheduler
+	axes     []axis
+	start    time.Time
+	duration time.Duration
+
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel closed when the move is over.
+func (m *Move) Done() <-chan struct{} {
+	return m.done
+}
+
+// Wait waits for the move to be over, returning ErrInterrupted or
+// ErrCanceled if it did not complete, or the error writing the position
+// of a servo.
+func (m *Move) Wait() error {
+	<-m.done
+	return m.err
+}
+
+// Cancel stops the move, leaving the servos where they are.
+func (m *Move) Cancel() {
+	m.sched.mu.Lock()
+	defer m.sched.mu.Unlock()
+
+	m.finish(ErrCanceled)
+}
+
+// Duration returns the duration of the move.
+func (m *Move) Duration() time.Duration {
+	return m.duration
+}
+
+// finish ends the move with err. The scheduler lock must be held.
+func (m *Move) finish(err error) {
+	m.sched.remove(m)
+	for _, a := range m.axes {
+		if a.s.move == m {
+			a.s.move = nil
+		}
+	}
+	select {
+	case <-m.done:
+		return
+	default:
+	}
+	m.err = err
+	close(m.done)
+}
+
+// update positions the servos for time t, finishing the move when over.
+// The scheduler lock must be held.
+func (m *Move) update(t time.Time) {
+	f := 1.0
+	if m.duration > 0 {
+		f = float64(t.Sub(m.start)) / float64(m.duration)
+	}
+	for _, a := range m.axes {
+		angle := a.from + (a.to-a.from)*shape(a.s.Profile, a.r, f)
+		if err := a.s.write(angle); err != nil {
+			m.finish(err)
+			return
+		}
+	}
+	if f >= 1 {
+		m.finish(nil)
+	}
+}
+
+// Scheduler executes the moves of servos from a single goroutine, updating
+// the positions of the moving servos at a fixed interval. The goroutine
+// only runs while moves are in progress.
+type Scheduler struct {
+	step time.Duration
+
+	mu      sync.Mutex
+	moves   []*Move
+	running bool
+}
+
+// DefaultScheduler executes the moves of servos without a scheduler.
+var DefaultScheduler = NewScheduler(DefaultStep)
+
+// NewScheduler returns a scheduler updating the moving servos every step.
+func NewScheduler(step time.Duration) *Scheduler {
+	return &Scheduler{step: step}
+}
+
+// remove stops executing m. s.mu must be held.
+func (s *Scheduler) remove(m *Move) {
+	for i, o := range s.moves {
+		if o == m {
+			s.moves = append(s.moves[:i], s.moves[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *Scheduler) run() {
+	ticker := time.NewTicker(s.step)
+	defer ticker.Stop()
+
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		for _, m := range append([]*Move(nil), s.moves...) {
+			m.update(now)
+		}
+		if len(s.moves) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		<-ticker.C
+	}
+}
+
+// Start starts moving the servos, which must use the scheduler, to their
+// targets in the background, so that they all arrive at the same time,
+// none exceeding its velocity and acceleration limits. Servos which are
+// already moving are interrupted and start from where they are. Servos
+// which were never positioned jump to their targets. If a servo is given
+// more than one target, no servo moves and the move fails with
+// ErrDuplicate.
+func (s *Scheduler) Start(targets ...Target) *Move {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := &Move{sched: s, done: make(chan struct{})}
+
+	seen := make(map[*Servo]bool, len(targets))
+	for _, t := range targets {
+		if seen[t.Servo] {
+			m.finish(ErrDuplicate)
+			return m
+		}
+		seen[t.Servo] = true
+	}
+
+	var longest float64
+	for _, t := range targets {
+		if t.Servo.move != nil {
+			t.Servo.move.finish(ErrInterrupted)
+		}
+		to := t.Servo.clamp(t.Angle)
+		from := t.Servo.angle
+		if !t.Servo.known {
+			from = to
+		}
+		a := axis{s: t.Servo, from: from, to: to}
+		d, r := t.Servo.plan(math.Abs(to - from))
+		a.r = r
+		m.axes = append(m.axes, a)
+		if d > longest {
+			longest = d
+		}
+		t.Servo.move = m
+	}
+	// Stretching the profiles in time to the longest move keeps all
+	// servos within their limits.
+	m.duration = time.Duration(longest * float64(time.Second))
+	m.start = time.Now()
+
+	glog.V(1).Infof("servo: moving %v servos over %v", len(targets), m.duration)
+
+	s.moves = append(s.moves, m)
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+
+	return m
+}
+
+// Start starts moving the servo to angle in the background.
+func (s *Servo) Start(angle float64) *Move {
+	return s.scheduler().Start(Target{s, angle})
+}
+
+// MoveTo moves the servo to angle, returning once it is there or ctx is
+// done, in which case the move is canceled.
+func (s *Servo) MoveTo(ctx context.Context, angle float64) error {
+	return wait(ctx, s.Start(angle))
+}
+
+// MoveTogether moves the servos to their targets so that they all arrive
+// at the same time, returning once they are there or ctx is done, in which
+// case the move is canceled. The servos must share the same scheduler.
+func MoveTogether(ctx context.Context, targets ...Target) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	sched := targets[0].Servo.scheduler()
+	for _, t := range targets[1:] {
+		if t.Servo.scheduler() != sched {
+			return errors.New("servo: servos moved together must share a scheduler")
+		}
+	}
+	return wait(ctx, sched.Start(targets...))
+}
+
+func wait(ctx context.Context, m *Move) error {
+	select {
+	case <-m.Done():
+		return m.Wait()
+	case <-ctx.Done():
+		m.Cancel()
+		return ctx.Err()
+	}
+}
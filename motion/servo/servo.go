@@ -1,14 +1,27 @@
 // Package servo allows control of servos using a PWM controller.
+//
+// Servos are calibrated with the pulse widths at both ends of an arbitrary
+// angle range, a trim and an inversion. They either jump to a position
+// with SetAngle, or move there smoothly in the background, within limits
+// of angular velocity and acceleration, with Start or MoveTo. Several
+// servos can be moved together so that they all arrive at the same time.
+//
+// Continuous rotation servos, whose pulse width sets the speed rather than
+// the position, are driven with Continuous.
 package servo
 
 import (
+	"math"
+
 	"github.com/golang/glog"
-	"github.com/kidoman/embd/util"
 )
 
 const (
 	minus = 544
 	maxus = 2400
+
+	minAngle = 0
+	maxAngle = 180
 )
 
 const (
@@ -21,26 +34,134 @@ type PWM interface {
 	SetMicroseconds(us int) error
 }
 
+// Servo represents a servo positioned by the width of its pulses.
 type Servo struct {
 	PWM PWM
 
+	// Minus and Maxus are the pulse widths positioning the servo at
+	// MinAngle and MaxAngle.
 	Minus, Maxus int
+
+	// MinAngle and MaxAngle delimit the range of the servo in degrees.
+	// Angles outside of it are clamped. The range is 0-180 if both are
+	// equal.
+	MinAngle, MaxAngle float64
+
+	// Trim is added to the pulse widths, in us, to center the servo.
+	Trim int
+
+	// Invert reverses the direction of rotation, MinAngle then being
+	// reached with Maxus.
+	Invert bool
+
+	// MaxVelocity limits the angular velocity of moves, in degrees per
+	// second. It is unlimited if 0.
+	MaxVelocity float64
+
+	// MaxAcceleration limits the angular acceleration of moves, in
+	// degrees per second squared. It is unlimited if 0.
+	MaxAcceleration float64
+
+	// Profile shapes the velocity of moves.
+	Profile Profile
+
+	// Scheduler executes the moves of the servo; DefaultScheduler is used
+	// if nil.
+	Scheduler *Scheduler
+
+	// The following are guarded by the scheduler.
+	angle float64
+	known bool
+	move  *Move
 }
 
 // New creates a new Servo interface.
 func New(pwm PWM) *Servo {
 	return &Servo{
-		PWM:   pwm,
-		Minus: minus,
-		Maxus: maxus,
+		PWM:      pwm,
+		Minus:    minus,
+		Maxus:    maxus,
+		MinAngle: minAngle,
+		MaxAngle: maxAngle,
+	}
+}
+
+func (s *Servo) scheduler() *Scheduler {
+	if s.Scheduler != nil {
+		return s.Scheduler
+	}
+	return DefaultScheduler
+}
+
+// bounds returns the range of the servo.
+func (s *Servo) bounds() (float64, float64) {
+	if s.MinAngle == s.MaxAngle {
+		return minAngle, maxAngle
+	}
+	return s.MinAngle, s.MaxAngle
+}
+
+// clamp returns angle within the range of the servo.
+func (s *Servo) clamp(angle float64) float64 {
+	min, max := s.bounds()
+	if min > max {
+		min, max = max, min
+	}
+	return math.Max(min, math.Min(max, angle))
+}
+
+// Microseconds returns the pulse width positioning the servo at angle.
+func (s *Servo) Microseconds(angle float64) int {
+	min, max := s.bounds()
+	f := (s.clamp(angle) - min) / (max - min)
+	if s.Invert {
+		f = 1 - f
 	}
+	return int(math.Floor(float64(s.Minus)+f*float64(s.Maxus-s.Minus)+0.5)) + s.Trim
 }
 
-// SetAngle sets the servo angle.
+// write positions the servo at angle. The scheduler lock must be held.
+func (s *Servo) write(angle float64) error {
+	angle = s.clamp(angle)
+	us := s.Microseconds(angle)
+
+	glog.V(2).Infof("servo: given angle %v calculated %v us", angle, us)
+
+	if err := s.PWM.SetMicroseconds(us); err != nil {
+		return err
+	}
+	s.angle = angle
+	s.known = true
+	return nil
+}
+
+// SetAngle sets the servo angle, interrupting any move of the servo.
 func (s *Servo) SetAngle(angle int) error {
-	us := util.Map(int64(angle), 0, 180, int64(s.Minus), int64(s.Maxus))
+	return s.SetPosition(float64(angle))
+}
+
+// SetPosition sets the servo angle in degrees, interrupting any move of
+// the servo.
+func (s *Servo) SetPosition(angle float64) error {
+	sched := s.scheduler()
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	if s.move != nil {
+		s.move.finish(ErrInterrupted)
+	}
+
+	glog.V(1).Infof("servo: setting angle to %v", angle)
+
+	return s.write(angle)
+}
 
-	glog.V(1).Infof("servo: given angle %v calculated %v us", angle, us)
+// Angle returns the last angle the servo was set to, and whether it was
+// set at all.
+func (s *Servo) Angle() (float64, bool) {
+	sched := s.scheduler()
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
 
-	return s.PWM.SetMicroseconds(int(us))
+	return s.angle, s.known
 }
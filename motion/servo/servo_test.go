@@ -0,0 +1,268 @@
+package servo
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakePWM struct {
+	mu  sync.Mutex
+	uss []int
+}
+
+func (p *fakePWM) SetMicroseconds(us int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.uss = append(p.uss, us)
+	return nil
+}
+
+func (p *fakePWM) writes() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]int(nil), p.uss...)
+}
+
+func (p *fakePWM) last() int {
+	uss := p.writes()
+	return uss[len(uss)-1]
+}
+
+func TestSetAngle(t *testing.T) {
+	pwm := &fakePWM{}
+	s := New(pwm)
+	s.Scheduler = NewScheduler(time.Millisecond)
+	tests := []struct {
+		angle int
+		us    int
+	}{
+		{0, 544},
+		{90, 1472},
+		{180, 2400},
+		{200, 2400},
+		{-10, 544},
+	}
+	for _, test := range tests {
+		if err := s.SetAngle(test.angle); err != nil {
+			t.Fatal(err)
+		}
+		if us := pwm.last(); us != test.us {
+			t.Errorf("SetAngle(%v): got %v us, want %v us", test.angle, us, test.us)
+		}
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	s := &Servo{Minus: 1000, Maxus: 2000, MinAngle: -45, MaxAngle: 45, Trim: 10, Invert: true}
+	tests := []struct {
+		angle float64
+		us    int
+	}{
+		{-45, 2010},
+		{0, 1510},
+		{45, 1010},
+		{90, 1010},
+	}
+	for _, test := range tests {
+		if us := s.Microseconds(test.angle); us != test.us {
+			t.Errorf("Microseconds(%v): got %v, want %v", test.angle, us, test.us)
+		}
+	}
+
+	// Servos built without New keep the default range.
+	s = &Servo{Minus: 1000, Maxus: 2000}
+	if us := s.Microseconds(90); us != 1500 {
+		t.Errorf("Microseconds(90) with default range: got %v, want 1500", us)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		profile Profile
+		v, a    float64
+		dist    float64
+		t, r    float64
+	}{
+		{Trapezoidal, 0, 0, 90, 0, 0},
+		{Trapezoidal, 90, 0, 90, 1, 0},
+		{Trapezoidal, 0, 90, 90, 2, 0.5},
+		{Trapezoidal, 90, 180, 90, 1.5, 1.0 / 3},
+		{Trapezoidal, 90, 90, 45, 2 * math.Sqrt(0.5), 0.5},
+		{SCurve, 90, 0, 90, 1.875, 0},
+		{SCurve, 0, 90, 90, math.Sqrt(5.7735), 0},
+	}
+	for _, test := range tests {
+		s := &Servo{Profile: test.profile, MaxVelocity: test.v, MaxAcceleration: test.a}
+		d, r := s.plan(test.dist)
+		if math.Abs(d-test.t) > 1e-9 || math.Abs(r-test.r) > 1e-9 {
+			t.Errorf("plan(%v) at %v deg/s, %v deg/s²: got %v s, %v, want %v s, %v", test.dist, test.v, test.a, d, r, test.t, test.r)
+		}
+	}
+}
+
+func TestShape(t *testing.T) {
+	for _, profile := range []Profile{Trapezoidal, SCurve} {
+		for _, r := range []float64{0, 0.25, 0.5} {
+			prev := 0.0
+			for f := 0.0; f <= 1; f += 0.01 {
+				v := shape(profile, r, f)
+				if v < prev-1e-12 || v > 1 {
+					t.Fatalf("shape(%v, %v, %v): got %v after %v", profile, r, f, v, prev)
+				}
+				prev = v
+			}
+			if v := shape(profile, r, 0.5); math.Abs(v-0.5) > 1e-9 {
+				t.Errorf("shape(%v, %v, 0.5): got %v, want 0.5", profile, r, v)
+			}
+			if v := shape(profile, r, 1); v != 1 {
+				t.Errorf("shape(%v, %v, 1): got %v, want 1", profile, r, v)
+			}
+		}
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	pwm := &fakePWM{}
+	s := New(pwm)
+	s.Scheduler = NewScheduler(time.Millisecond)
+	s.MaxVelocity = 3600
+	if err := s.SetAngle(0); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := s.MoveTo(context.Background(), 180); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("MoveTo at 3600 deg/s: took %v, want at least 50ms", d)
+	}
+	uss := pwm.writes()
+	if len(uss) < 5 {
+		t.Errorf("MoveTo: got %v writes, want intermediate positions", len(uss))
+	}
+	for i := 1; i < len(uss); i++ {
+		if uss[i] < uss[i-1] {
+			t.Fatalf("MoveTo: got %v, want increasing pulse widths", uss)
+		}
+	}
+	if angle, _ := s.Angle(); angle != 180 || pwm.last() != 2400 {
+		t.Errorf("MoveTo(180): got angle %v, %v us", angle, pwm.last())
+	}
+}
+
+func TestCancel(t *testing.T) {
+	pwm := &fakePWM{}
+	s := New(pwm)
+	s.Scheduler = NewScheduler(time.Millisecond)
+	s.MaxVelocity = 90
+	if err := s.SetAngle(0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.MoveTo(ctx, 180); err != context.DeadlineExceeded {
+		t.Fatalf("MoveTo: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	angle, _ := s.Angle()
+	if angle <= 0 || angle >= 90 {
+		t.Errorf("Canceled move: got angle %v", angle)
+	}
+
+	m := s.Start(180)
+	if err := s.SetAngle(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Wait(); err != ErrInterrupted {
+		t.Errorf("Move interrupted by SetAngle: got %v, want %v", err, ErrInterrupted)
+	}
+
+	m = s.Start(180)
+	m.Cancel()
+	if err := m.Wait(); err != ErrCanceled {
+		t.Errorf("Canceled move: got %v, want %v", err, ErrCanceled)
+	}
+}
+
+func TestMoveTogether(t *testing.T) {
+	sched := NewScheduler(time.Millisecond)
+	pwm1, pwm2 := &fakePWM{}, &fakePWM{}
+	s1, s2 := New(pwm1), New(pwm2)
+	for _, s := range []*Servo{s1, s2} {
+		s.Scheduler = sched
+		s.MaxVelocity = 1800
+		s.MaxAcceleration = 36000
+		s.SetAngle(0)
+	}
+
+	m := sched.Start(Target{s1, 180}, Target{s2, 18})
+	if want := time.Duration((0.1 + 0.05) * float64(time.Second)); m.Duration() != want {
+		t.Errorf("Duration: got %v, want %v", m.Duration(), want)
+	}
+	time.Sleep(m.Duration() / 2)
+	a1, _ := s1.Angle()
+	a2, _ := s2.Angle()
+	if math.Abs(a1/10-a2) > 1 {
+		t.Errorf("Midway: got angles %v and %v, want proportional", a1, a2)
+	}
+	if err := m.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	a1, _ = s1.Angle()
+	a2, _ = s2.Angle()
+	if a1 != 180 || a2 != 18 {
+		t.Errorf("MoveTogether: got angles %v and %v, want 180 and 18", a1, a2)
+	}
+
+	s3 := New(&fakePWM{})
+	if err := MoveTogether(context.Background(), Target{s1, 0}, Target{s3, 0}); err == nil {
+		t.Error("MoveTogether across schedulers: got no error")
+	}
+
+	m = sched.Start(Target{s1, 90}, Target{s2, 90}, Target{s1, 0})
+	if err := m.Wait(); err != ErrDuplicate {
+		t.Errorf("Start with a duplicate servo: got %v, want %v", err, ErrDuplicate)
+	}
+	if err := MoveTogether(context.Background(), Target{s1, 90}, Target{s1, 0}); err != ErrDuplicate {
+		t.Errorf("MoveTogether with a duplicate servo: got %v, want %v", err, ErrDuplicate)
+	}
+	if a1, _ = s1.Angle(); a1 != 180 {
+		t.Errorf("Rejected move: got angle %v, want 180", a1)
+	}
+	sched.mu.Lock()
+	n := len(sched.moves)
+	sched.mu.Unlock()
+	if n != 0 {
+		t.Errorf("Rejected move: got %v moves scheduled, want 0", n)
+	}
+}
+
+func TestContinuous(t *testing.T) {
+	c := NewContinuous(&fakePWM{})
+	c.Deadband = 50
+	tests := []struct {
+		speed float64
+		us    int
+	}{
+		{0, 1500},
+		{1, 2000},
+		{-1, 1000},
+		{0.5, 1775},
+		{-0.5, 1225},
+		{2, 2000},
+	}
+	for _, test := range tests {
+		if us := c.Microseconds(test.speed); us != test.us {
+			t.Errorf("Microseconds(%v): got %v, want %v", test.speed, us, test.us)
+		}
+	}
+	c.Invert = true
+	if us := c.Microseconds(1); us != 1000 {
+		t.Errorf("Inverted Microseconds(1): got %v, want 1000", us)
+	}
+}
@@ -0,0 +1,202 @@
+// STEP/DIR driver support.
+
+package stepper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// Chip describes a STEP/DIR driver chip.
+type Chip struct {
+	Name string
+
+	// Microsteps maps the microstep divisors supported by the chip to the
+	// levels of its microstep selection pins, MS1 first.
+	Microsteps map[int][]int
+
+	// Pulse is the minimum width of the high and low levels of STEP.
+	Pulse time.Duration
+
+	// DirSetup is the minimum delay between a change of DIR and the
+	// rising edge of STEP.
+	DirSetup time.Duration
+}
+
+var (
+	// A4988 is the Allegro A4988, with microstep pins MS1, MS2 and MS3.
+	A4988 = &Chip{
+		Name: "a4988",
+		Microsteps: map[int][]int{
+			1:  {0, 0, 0},
+			2:  {1, 0, 0},
+			4:  {0, 1, 0},
+			8:  {1, 1, 0},
+			16: {1, 1, 1},
+		},
+		Pulse:    time.Microsecond,
+		DirSetup: 200 * time.Nanosecond,
+	}
+
+	// DRV8825 is the TI DRV8825, with microstep pins MODE0, MODE1 and
+	// MODE2.
+	DRV8825 = &Chip{
+		Name: "drv8825",
+		Microsteps: map[int][]int{
+			1:  {0, 0, 0},
+			2:  {1, 0, 0},
+			4:  {0, 1, 0},
+			8:  {1, 1, 0},
+			16: {0, 0, 1},
+			32: {1, 0, 1},
+		},
+		Pulse:    1900 * time.Nanosecond,
+		DirSetup: 650 * time.Nanosecond,
+	}
+
+	// TMC2208 is the Trinamic TMC2208 in STEP/DIR mode, with microstep
+	// pins MS1 and MS2.
+	TMC2208 = &Chip{
+		Name: "tmc2208",
+		Microsteps: map[int][]int{
+			2:  {1, 0},
+			4:  {0, 1},
+			8:  {0, 0},
+			16: {1, 1},
+		},
+		Pulse:    100 * time.Nanosecond,
+		DirSetup: 20 * time.Nanosecond,
+	}
+)
+
+// StepDir drives a stepper motor through the STEP and DIR inputs of a
+// driver chip. The DIR pin is high for forward steps; make it active low
+// to reverse the motor.
+type StepDir struct {
+	chip   *Chip
+	step   embd.DigitalPin
+	dir    embd.DigitalPin
+	enable embd.DigitalPin
+	ms     []embd.DigitalPin
+
+	mu        sync.Mutex
+	lastDir   Direction
+	microstep int
+}
+
+// NewStepDir returns a driver for chip on the step and dir pins. The
+// active low enable pin and the microstep selection pins are optional. The
+// pins are set as outputs and remain owned by the caller.
+func NewStepDir(chip *Chip, step, dir, enable embd.DigitalPin, ms ...embd.DigitalPin) (*StepDir, error) {
+	d := &StepDir{chip: chip, step: step, dir: dir, enable: enable, ms: ms}
+	pins := append([]embd.DigitalPin{step, dir}, ms...)
+	if enable != nil {
+		pins = append(pins, enable)
+	}
+	for _, pin := range pins {
+		if err := pin.SetDirection(embd.Out); err != nil {
+			return nil, err
+		}
+	}
+	if err := step.Write(embd.Low); err != nil {
+		return nil, err
+	}
+	if err := dir.Write(embd.High); err != nil {
+		return nil, err
+	}
+	d.lastDir = Forward
+	if enable != nil {
+		if err := enable.Write(embd.Low); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// SetMicrostep selects the microstep divisor, e.g. 16 for 1/16 steps,
+// through the microstep selection pins.
+func (d *StepDir) SetMicrostep(div int) error {
+	levels, ok := d.chip.Microsteps[div]
+	if !ok {
+		return fmt.Errorf("%v: unsupported microstep divisor %v", d.chip.Name, div)
+	}
+	if len(d.ms) != len(levels) {
+		return fmt.Errorf("%v: %v microstep pins, want %v", d.chip.Name, len(d.ms), len(levels))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, pin := range d.ms {
+		if err := pin.Write(levels[i]); err != nil {
+			return err
+		}
+	}
+	d.microstep = div
+
+	glog.V(1).Infof("%v: microstep divisor set to %v", d.chip.Name, div)
+
+	return nil
+}
+
+// Microstep returns the microstep divisor selected with SetMicrostep, or 0.
+func (d *StepDir) Microstep() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.microstep
+}
+
+// SetEnabled enables or disables the outputs of the chip through its
+// enable pin. A disabled motor turns freely.
+func (d *StepDir) SetEnabled(enabled bool) error {
+	if d.enable == nil {
+		return fmt.Errorf("%v: no enable pin", d.chip.Name)
+	}
+	level := embd.High
+	if enabled {
+		level = embd.Low
+	}
+	return d.enable.Write(level)
+}
+
+// Step pulses STEP to move the motor one step in dir.
+func (d *StepDir) Step(dir Direction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if dir != d.lastDir {
+		level := embd.High
+		if dir == Backward {
+			level = embd.Low
+		}
+		if err := d.dir.Write(level); err != nil {
+			return err
+		}
+		d.lastDir = dir
+		spin(d.chip.DirSetup)
+	}
+
+	if err := d.step.Write(embd.High); err != nil {
+		return err
+	}
+	spin(d.chip.Pulse)
+	if err := d.step.Write(embd.Low); err != nil {
+		return err
+	}
+	spin(d.chip.Pulse)
+
+	return nil
+}
+
+// Close disables the outputs of the chip if it has an enable pin.
+func (d *StepDir) Close() error {
+	if d.enable == nil {
+		return nil
+	}
+	return d.SetEnabled(false)
+}
@@ -0,0 +1,292 @@
+// Package stepper allows control of stepper motors.
+//
+// A Driver moves a motor one step at a time: Unipolar energizes the four
+// coils of a unipolar motor through digital pins, while StepDir pulses the
+// STEP and DIR inputs of driver chips such as the A4988, DRV8825 and
+// TMC2208.
+//
+// A Motor keeps track of the position of a driver and moves it to
+// absolute or relative positions, ramping the speed up and down within
+// its acceleration limit. It can be homed against a limit switch, and
+// several motors can be moved together so that they start and arrive at
+// the same time.
+package stepper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// DefaultMaxSpeed is the speed of a motor, in steps per second, until set.
+const DefaultMaxSpeed = 100
+
+// ErrBusy is returned when moving a motor which is already moving.
+var ErrBusy = errors.New("stepper: motor is moving")
+
+// Direction is the direction of a step.
+type Direction int
+
+const (
+	// Forward steps increase the position of the motor.
+	Forward Direction = 1
+
+	// Backward steps decrease the position of the motor.
+	Backward Direction = -1
+)
+
+// Driver moves a stepper motor one step at a time.
+type Driver interface {
+	// Step moves the motor one step in dir.
+	Step(dir Direction) error
+
+	// Close releases the resources associated with the driver.
+	Close() error
+}
+
+// Motor is a stepper motor whose position is tracked in steps of its
+// driver, microsteps with microstepping drivers.
+type Motor struct {
+	Driver Driver
+
+	// MaxSpeed is the speed of the moves, in steps per second.
+	MaxSpeed float64
+
+	// Acceleration limits the acceleration of the moves, in steps per
+	// second squared. The motor starts and stops at full speed if 0.
+	Acceleration float64
+
+	// HomingSpeed is the speed of homing, in steps per second. MaxSpeed
+	// is used if 0.
+	HomingSpeed float64
+
+	mu       sync.Mutex
+	position int64
+	moving   bool
+}
+
+// New creates a new motor driven by drv, at position 0.
+func New(drv Driver) *Motor {
+	return &Motor{Driver: drv, MaxSpeed: DefaultMaxSpeed}
+}
+
+// Position returns the position of the motor.
+func (m *Motor) Position() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.position
+}
+
+// SetPosition redefines the current position of the motor as pos.
+func (m *Motor) SetPosition(pos int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.position = pos
+}
+
+func (m *Motor) acquire() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.moving {
+		return ErrBusy
+	}
+	m.moving = true
+	return nil
+}
+
+func (m *Motor) release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.moving = false
+}
+
+func (m *Motor) step(dir Direction) error {
+	if err := m.Driver.Step(dir); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.position += int64(dir)
+	m.mu.Unlock()
+	return nil
+}
+
+// Move moves the motor by steps, forward if positive, returning once the
+// move is over or ctx is done.
+func (m *Motor) Move(ctx context.Context, steps int64) error {
+	return MoveTogether(ctx, Target{m, m.Position() + steps})
+}
+
+// MoveTo moves the motor to pos, returning once it is there or ctx is
+// done.
+func (m *Motor) MoveTo(ctx context.Context, pos int64) error {
+	return MoveTogether(ctx, Target{m, pos})
+}
+
+// Home moves the motor in dir at HomingSpeed until limit reads high, and
+// then makes that position 0. It fails after maxSteps steps without
+// reaching the limit switch, unless maxSteps is 0.
+func (m *Motor) Home(ctx context.Context, limit embd.DigitalPin, dir Direction, maxSteps int64) error {
+	speed := m.HomingSpeed
+	if speed == 0 {
+		speed = m.MaxSpeed
+	}
+	if speed <= 0 {
+		return fmt.Errorf("stepper: invalid homing speed %v", speed)
+	}
+	if err := m.acquire(); err != nil {
+		return err
+	}
+	defer m.release()
+
+	glog.V(1).Infof("stepper: homing at %v steps/s", speed)
+
+	interval := time.Duration(float64(time.Second) / speed)
+	due := time.Now()
+	for n := int64(0); ; n++ {
+		v, err := limit.Read()
+		if err != nil {
+			return err
+		}
+		if v == embd.High {
+			break
+		}
+		if maxSteps > 0 && n >= maxSteps {
+			return fmt.Errorf("stepper: limit switch not reached after %v steps", n)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sleepUntil(due)
+		if err := m.step(dir); err != nil {
+			return err
+		}
+		due = due.Add(interval)
+	}
+
+	m.SetPosition(0)
+
+	glog.V(1).Infof("stepper: homed")
+
+	return nil
+}
+
+// Target is the position a motor moves to.
+type Target struct {
+	Motor    *Motor
+	Position int64
+}
+
+type axis struct {
+	m     *Motor
+	dir   Direction
+	steps int64
+	acc   int64
+}
+
+// MoveTogether moves the motors to their targets, so that they start and
+// arrive at the same time, none exceeding its speed and acceleration
+// limits. It returns once the motors are there or ctx is done.
+func MoveTogether(ctx context.Context, targets ...Target) error {
+	for i, t := range targets {
+		if err := t.Motor.acquire(); err != nil {
+			for _, o := range targets[:i] {
+				o.Motor.release()
+			}
+			return err
+		}
+	}
+	defer func() {
+		for _, t := range targets {
+			t.Motor.release()
+		}
+	}()
+
+	var (
+		axes []*axis
+		n    int64
+	)
+	for _, t := range targets {
+		a := &axis{m: t.Motor, dir: Forward, steps: t.Position - t.Motor.Position()}
+		if a.steps < 0 {
+			a.dir, a.steps = Backward, -a.steps
+		}
+		if a.steps == 0 {
+			continue
+		}
+		axes = append(axes, a)
+		if a.steps > n {
+			n = a.steps
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+
+	// The speed and acceleration are those of the axis with the most
+	// steps, scaled down so that no other axis exceeds its limits.
+	speed, accel := math.Inf(1), math.Inf(1)
+	for _, a := range axes {
+		ratio := float64(n) / float64(a.steps)
+		if a.m.MaxSpeed <= 0 {
+			return fmt.Errorf("stepper: invalid speed %v", a.m.MaxSpeed)
+		}
+		speed = math.Min(speed, a.m.MaxSpeed*ratio)
+		if a.m.Acceleration > 0 {
+			accel = math.Min(accel, a.m.Acceleration*ratio)
+		}
+	}
+
+	glog.V(1).Infof("stepper: moving %v steps at %v steps/s", n, speed)
+
+	due := time.Now()
+	for i := int64(0); i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		v := speed
+		if !math.IsInf(accel, 1) {
+			// Ramp up from rest and down to rest.
+			v = math.Min(v, math.Sqrt(2*accel*float64(i+1)))
+			v = math.Min(v, math.Sqrt(2*accel*float64(n-i)))
+		}
+		due = due.Add(time.Duration(float64(time.Second) / v))
+		sleepUntil(due)
+
+		// Bresenham: every axis steps evenly along the longest one.
+		for _, a := range axes {
+			a.acc += a.steps
+			if a.acc < n {
+				continue
+			}
+			a.acc -= n
+			if err := a.m.step(a.dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sleepUntil sleeps until t.
+func sleepUntil(t time.Time) {
+	if d := t.Sub(time.Now()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// spin busy waits for d, too short for a sleep.
+func spin(d time.Duration) {
+	for end := time.Now().Add(d); time.Now().Before(end); {
+	}
+}
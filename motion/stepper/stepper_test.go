@@ -0,0 +1,266 @@
+package stepper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+// fakeDriver records the steps it is asked to make.
+type fakeDriver struct {
+	mu    sync.Mutex
+	steps []Direction
+}
+
+func (d *fakeDriver) Step(dir Direction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.steps = append(d.steps, dir)
+	return nil
+}
+
+func (d *fakeDriver) Close() error {
+	return nil
+}
+
+func (d *fakeDriver) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.steps)
+}
+
+func TestUnipolar(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		want []uint64
+	}{
+		{WaveDrive, []uint64{0x2, 0x4, 0x8, 0x1, 0x8}},
+		{FullStep, []uint64{0x6, 0xC, 0x9, 0x3, 0x9}},
+		{HalfStep, []uint64{0x3, 0x2, 0x6, 0x4, 0x6}},
+	}
+	for _, test := range tests {
+		pins := []*sim.Pin{sim.NewPin(0), sim.NewPin(1), sim.NewPin(2), sim.NewPin(3)}
+		u, err := NewUnipolar(test.mode, pins[0], pins[1], pins[2], pins[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		coils := func() uint64 {
+			var v uint64
+			for i, pin := range pins {
+				v |= uint64(pin.Level()) << uint(i)
+			}
+			return v
+		}
+		for i, want := range test.want {
+			dir := Forward
+			if i == len(test.want)-1 {
+				dir = Backward
+			}
+			if err := u.Step(dir); err != nil {
+				t.Fatal(err)
+			}
+			if got := coils(); got != want {
+				t.Errorf("Mode %v, step %v: got coils %04b, want %04b", test.mode, i, got, want)
+			}
+		}
+		if err := u.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := coils(); got != 0 {
+			t.Errorf("Mode %v, closed: got coils %04b, want 0", test.mode, got)
+		}
+	}
+}
+
+func TestStepDir(t *testing.T) {
+	step, dir, enable := sim.NewPin(0), sim.NewPin(1), sim.NewPin(2)
+	ms := []embd.DigitalPin{sim.NewPin(3), sim.NewPin(4), sim.NewPin(5)}
+	d, err := NewStepDir(A4988, step, dir, enable, ms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var forward, backward int
+	step.OnChange(func(level int) {
+		if level != embd.High {
+			return
+		}
+		if dir.Level() == embd.High {
+			forward++
+		} else {
+			backward++
+		}
+	})
+	for _, dir := range []Direction{Forward, Forward, Backward, Forward} {
+		if err := d.Step(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if forward != 3 || backward != 1 {
+		t.Errorf("Steps: got %v forward and %v backward, want 3 and 1", forward, backward)
+	}
+	if step.Level() != embd.Low {
+		t.Error("STEP: got high after steps, want low")
+	}
+
+	if err := d.SetMicrostep(8); err != nil {
+		t.Fatal(err)
+	}
+	var levels []int
+	for _, pin := range ms {
+		levels = append(levels, pin.(*sim.Pin).Level())
+	}
+	if levels[0] != 1 || levels[1] != 1 || levels[2] != 0 {
+		t.Errorf("SetMicrostep(8): got MS levels %v, want [1 1 0]", levels)
+	}
+	if d.Microstep() != 8 {
+		t.Errorf("Microstep: got %v, want 8", d.Microstep())
+	}
+	if err := d.SetMicrostep(32); err == nil {
+		t.Error("SetMicrostep(32) on a4988: got no error")
+	}
+
+	if enable.Level() != embd.Low {
+		t.Error("EN: got high, want enabled")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if enable.Level() != embd.High {
+		t.Error("EN: got low after Close, want disabled")
+	}
+}
+
+func TestMove(t *testing.T) {
+	drv := &fakeDriver{}
+	m := New(drv)
+	m.MaxSpeed = 5000
+	if err := m.MoveTo(context.Background(), 50); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Move(context.Background(), -20); err != nil {
+		t.Fatal(err)
+	}
+	if pos := m.Position(); pos != 30 {
+		t.Errorf("Position: got %v, want 30", pos)
+	}
+	if n := drv.count(); n != 70 {
+		t.Errorf("Steps: got %v, want 70", n)
+	}
+	if drv.steps[49] != Forward || drv.steps[50] != Backward {
+		t.Errorf("Steps: got %v, want 50 forward then 20 backward", drv.steps)
+	}
+}
+
+func TestAcceleration(t *testing.T) {
+	drv := &fakeDriver{}
+	m := New(drv)
+	m.MaxSpeed = 10000
+	m.Acceleration = 20000
+
+	// Accelerating to 2000 steps/s takes 0.1s and 100 steps, and so does
+	// decelerating.
+	start := time.Now()
+	if err := m.Move(context.Background(), 200); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("Move(200) at 20000 steps/s²: took %v, want at least 150ms", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Move(ctx, 1000); err != context.DeadlineExceeded {
+		t.Fatalf("Canceled move: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if pos := m.Position(); pos <= 200 || pos >= 1200 {
+		t.Errorf("Canceled move: got position %v", pos)
+	}
+}
+
+func TestMoveTogether(t *testing.T) {
+	drv1, drv2 := &fakeDriver{}, &fakeDriver{}
+	m1, m2 := New(drv1), New(drv2)
+	m1.MaxSpeed, m2.MaxSpeed = 10000, 1000
+
+	// m2 limits the speed of m1 to 4000 steps/s.
+	start := time.Now()
+	var counts [][2]int
+	m1.Driver = driverFunc(func(dir Direction) error {
+		counts = append(counts, [2]int{drv1.count() + 1, drv2.count()})
+		return drv1.Step(dir)
+	})
+	if err := MoveTogether(context.Background(), Target{m1, 100}, Target{m2, -25}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 24*time.Millisecond {
+		t.Errorf("MoveTogether: took %v, want at least 25ms", d)
+	}
+	if m1.Position() != 100 || m2.Position() != -25 {
+		t.Errorf("MoveTogether: got positions %v and %v, want 100 and -25", m1.Position(), m2.Position())
+	}
+	for _, c := range counts {
+		if c[0]/4-c[1] > 1 || c[1]-c[0]/4 > 1 {
+			t.Fatalf("MoveTogether: got %v steps of m2 after %v steps of m1", c[1], c[0])
+		}
+	}
+}
+
+type driverFunc func(dir Direction) error
+
+func (f driverFunc) Step(dir Direction) error { return f(dir) }
+func (f driverFunc) Close() error             { return nil }
+
+func TestHome(t *testing.T) {
+	limit := sim.NewPin(0)
+	drv := &fakeDriver{}
+	m := New(drv)
+	m.MaxSpeed = 10000
+	m.SetPosition(500)
+	m.Driver = driverFunc(func(dir Direction) error {
+		if err := drv.Step(dir); err != nil {
+			return err
+		}
+		if drv.count() == 30 {
+			limit.Drive(embd.High)
+		}
+		return nil
+	})
+
+	if err := m.Home(context.Background(), limit, Backward, 100); err != nil {
+		t.Fatal(err)
+	}
+	if n := drv.count(); n != 30 {
+		t.Errorf("Home: got %v steps, want 30", n)
+	}
+	if pos := m.Position(); pos != 0 {
+		t.Errorf("Home: got position %v, want 0", pos)
+	}
+
+	limit.Drive(embd.Low)
+	if err := m.Home(context.Background(), limit, Backward, 10); err == nil {
+		t.Error("Home without reaching the limit: got no error")
+	}
+}
+
+func TestBusy(t *testing.T) {
+	m := New(&fakeDriver{})
+	m.MaxSpeed = 1000
+	done := make(chan error)
+	go func() {
+		done <- m.Move(context.Background(), 50)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	if err := m.Move(context.Background(), 1); err != ErrBusy {
+		t.Errorf("Concurrent move: got %v, want %v", err, ErrBusy)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+// Unipolar stepper motor support.
+
+package stepper
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// Mode is the sequence in which the coils of a unipolar motor are
+// energized.
+type Mode int
+
+const (
+	// WaveDrive energizes one coil at a time, using the least power.
+	WaveDrive Mode = iota
+
+	// FullStep energizes two coils at a time, for the most torque.
+	FullStep
+
+	// HalfStep alternates between one and two coils, doubling the number
+	// of steps per revolution.
+	HalfStep
+)
+
+var sequences = [...][]uint64{
+	WaveDrive: {0x1, 0x2, 0x4, 0x8},
+	FullStep:  {0x3, 0x6, 0xC, 0x9},
+	HalfStep:  {0x1, 0x3, 0x2, 0x6, 0x4, 0xC, 0x8, 0x9},
+}
+
+// Unipolar drives a unipolar stepper motor, such as the 28BYJ-48, through
+// four digital pins connected to the coils, e.g. via a ULN2003 darlington
+// array.
+type Unipolar struct {
+	port *embd.DigitalPort
+	seq  []uint64
+
+	mu    sync.Mutex
+	phase int
+}
+
+// NewUnipolar returns a driver energizing the coils connected to the pins,
+// in order, according to mode. The pins are set as outputs and remain
+// owned by the caller.
+func NewUnipolar(mode Mode, a, b, c, d embd.DigitalPin) (*Unipolar, error) {
+	if mode < WaveDrive || mode > HalfStep {
+		return nil, fmt.Errorf("stepper: invalid mode %v", mode)
+	}
+	port, err := embd.NewDigitalPort(a, b, c, d)
+	if err != nil {
+		return nil, err
+	}
+	if err := port.SetDirection(embd.Out); err != nil {
+		return nil, err
+	}
+	if err := port.Write(0xF, 0); err != nil {
+		return nil, err
+	}
+	return &Unipolar{port: port, seq: sequences[mode]}, nil
+}
+
+// Step energizes the coils for the next step in dir.
+func (u *Unipolar) Step(dir Direction) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	phase := (u.phase + int(dir) + len(u.seq)) % len(u.seq)
+	if err := u.port.Write(0xF, u.seq[phase]); err != nil {
+		return err
+	}
+	u.phase = phase
+
+	glog.V(3).Infof("stepper: energized coils %04b", u.seq[phase])
+
+	return nil
+}
+
+// Release de-energizes the coils, letting the motor turn freely. The next
+// step resumes from the last phase.
+func (u *Unipolar) Release() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.port.Write(0xF, 0)
+}
+
+// Close de-energizes the coils.
+func (u *Unipolar) Close() error {
+	return u.Release()
+}
@@ -26,11 +26,15 @@ const (
 	// CapLCD represents pins used to carry LCD data.
 	CapLCD
 
-	// CapPWM represents pins with PWM capability.
+	// CapPWM represents pins with PWM hardware.
 	CapPWM
 
 	// CapAnalog represents pins with analog IO capability.
 	CapAnalog
+
+	// CapSoftPWM represents pins without PWM hardware, on which the host
+	// generates PWM in software.
+	CapSoftPWM
 )
 
 // PinDesc represents a pin descriptor.
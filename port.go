@@ -0,0 +1,173 @@
+// Digital port support.
+
+package embd
+
+import "errors"
+
+// PinGroup is implemented by backends able to write and read several of
+// their pins at once, e.g. through a single register write or a single
+// request for several lines of a GPIO chip. Masks and values carry one bit
+// per pin, as assigned by the backend, and physical levels.
+type PinGroup interface {
+	// WriteGroup drives the pins in mask to the corresponding bits of
+	// value, all at once.
+	WriteGroup(mask, value uint64) error
+
+	// ReadGroup returns the levels of all the pins of the group.
+	ReadGroup() (uint64, error)
+}
+
+// GroupPin is implemented by the digital pins of backends supporting pin
+// groups.
+type GroupPin interface {
+	DigitalPin
+
+	// PinGroup returns the group of the pin, identical (and comparable)
+	// for all the pins which can be accessed together, the bit of the pin
+	// in the masks of the group and whether the pin is active low.
+	PinGroup() (group PinGroup, bit uint64, activeLow bool, err error)
+}
+
+type portGroup struct {
+	group PinGroup
+
+	// bits and activeLow hold, for each pin of the group, its bit in the
+	// group and whether it is active low, indexed by the bit of the pin in
+	// the port.
+	bits      map[uint]uint64
+	activeLow map[uint]bool
+}
+
+// DigitalPort groups up to 64 digital pins so that they can be written
+// and read together. Bit i of the masks and values corresponds to the i-th
+// pin of the port.
+//
+// Pins whose backend supports pin groups are accessed together, in a
+// single operation per backend. Other pins are accessed one after the
+// other, in order. Whether the pins are active low is taken into account
+// as configured when the port is created.
+type DigitalPort struct {
+	pins   []DigitalPin
+	groups []*portGroup
+	single uint64 // Mask of the pins accessed one at a time.
+}
+
+// NewDigitalPort returns a port grouping the pins. The pins remain owned
+// by the caller.
+func NewDigitalPort(pins ...DigitalPin) (*DigitalPort, error) {
+	if len(pins) > 64 {
+		return nil, errors.New("gpio: a digital port has at most 64 pins")
+	}
+
+	port := &DigitalPort{pins: pins}
+	for i, pin := range pins {
+		gp, ok := pin.(GroupPin)
+		if !ok {
+			port.single |= 1 << uint(i)
+			continue
+		}
+		group, bit, activeLow, err := gp.PinGroup()
+		if err != nil {
+			return nil, err
+		}
+
+		var g *portGroup
+		for _, pg := range port.groups {
+			if pg.group == group {
+				g = pg
+				break
+			}
+		}
+		if g == nil {
+			g = &portGroup{group: group, bits: map[uint]uint64{}, activeLow: map[uint]bool{}}
+			port.groups = append(port.groups, g)
+		}
+		g.bits[uint(i)] = bit
+		g.activeLow[uint(i)] = activeLow
+	}
+
+	return port, nil
+}
+
+// Len returns the number of pins of the port.
+func (p *DigitalPort) Len() int {
+	return len(p.pins)
+}
+
+// Pin returns the i-th pin of the port.
+func (p *DigitalPort) Pin(i int) DigitalPin {
+	return p.pins[i]
+}
+
+// SetDirection sets the direction of all the pins of the port.
+func (p *DigitalPort) SetDirection(dir Direction) error {
+	for _, pin := range p.pins {
+		if err := pin.SetDirection(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write drives the pins in mask to the corresponding bits of value.
+func (p *DigitalPort) Write(mask, value uint64) error {
+	for _, g := range p.groups {
+		var gmask, gvalue uint64
+		for i, bit := range g.bits {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			gmask |= bit
+			if (value&(1<<i) != 0) != g.activeLow[i] {
+				gvalue |= bit
+			}
+		}
+		if gmask == 0 {
+			continue
+		}
+		if err := g.group.WriteGroup(gmask, gvalue); err != nil {
+			return err
+		}
+	}
+
+	for i, pin := range p.pins {
+		if (p.single&mask)&(1<<uint(i)) == 0 {
+			continue
+		}
+		if err := pin.Write(int(value>>uint(i)) & 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read returns the values of all the pins of the port.
+func (p *DigitalPort) Read() (uint64, error) {
+	var value uint64
+
+	for _, g := range p.groups {
+		levels, err := g.group.ReadGroup()
+		if err != nil {
+			return 0, err
+		}
+		for i, bit := range g.bits {
+			if (levels&bit != 0) != g.activeLow[i] {
+				value |= 1 << i
+			}
+		}
+	}
+
+	for i, pin := range p.pins {
+		if p.single&(1<<uint(i)) == 0 {
+			continue
+		}
+		v, err := pin.Read()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint64(v&1) << uint(i)
+	}
+
+	return value, nil
+}
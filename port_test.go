@@ -0,0 +1,93 @@
+package embd
+
+import "testing"
+
+type fakePinGroup struct {
+	levels uint64
+	writes int
+}
+
+func (g *fakePinGroup) WriteGroup(mask, value uint64) error {
+	g.levels = g.levels&^mask | value&mask
+	g.writes++
+	return nil
+}
+
+func (g *fakePinGroup) ReadGroup() (uint64, error) {
+	return g.levels, nil
+}
+
+type fakeGroupPin struct {
+	fakeDigitalPin
+	group     *fakePinGroup
+	bit       uint64
+	activeLow bool
+}
+
+func (p *fakeGroupPin) PinGroup() (PinGroup, uint64, bool, error) {
+	return p.group, p.bit, p.activeLow, nil
+}
+
+type fakeValuePin struct {
+	fakeDigitalPin
+	value  int
+	writes int
+}
+
+func (p *fakeValuePin) Write(val int) error {
+	p.value = val
+	p.writes++
+	return nil
+}
+
+func (p *fakeValuePin) Read() (int, error) {
+	return p.value, nil
+}
+
+func TestDigitalPortWrite(t *testing.T) {
+	g := &fakePinGroup{}
+	single := &fakeValuePin{}
+	port, err := NewDigitalPort(
+		&fakeGroupPin{group: g, bit: 1 << 17},
+		single,
+		&fakeGroupPin{group: g, bit: 1 << 4, activeLow: true},
+		&fakeGroupPin{group: g, bit: 1 << 40},
+	)
+	if err != nil {
+		t.Fatalf("Creating port: got %v", err)
+	}
+
+	if err := port.Write(0xf, 0x3); err != nil {
+		t.Fatalf("Writing port: got %v", err)
+	}
+	if g.writes != 1 {
+		t.Errorf("Writing port: got %v group writes, want 1", g.writes)
+	}
+	if want := uint64(1<<17 | 1<<4); g.levels != want {
+		t.Errorf("Writing port: group levels are %#x, want %#x", g.levels, want)
+	}
+	if single.value != High {
+		t.Errorf("Writing port: single pin is %v, want %v", single.value, High)
+	}
+
+	if err := port.Write(0x8, 0x8); err != nil {
+		t.Fatalf("Writing pin 3 of port: got %v", err)
+	}
+	if single.writes != 1 {
+		t.Errorf("Writing pin 3 of port: single pin written %v times, want 1", single.writes)
+	}
+
+	v, err := port.Read()
+	if err != nil {
+		t.Fatalf("Reading port: got %v", err)
+	}
+	if v != 0xb {
+		t.Errorf("Reading port: got %#x, want %#x", v, 0xb)
+	}
+}
+
+func TestDigitalPortTooManyPins(t *testing.T) {
+	if _, err := NewDigitalPort(make([]DigitalPin, 65)...); err == nil {
+		t.Error("Creating port of 65 pins: got nil error")
+	}
+}
@@ -0,0 +1,163 @@
+// Pulse measurement support.
+
+package embd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PulseTimeoutError is returned when a pulse measurement does not complete
+// before its context is done.
+type PulseTimeoutError struct {
+	// State is the state of the pulses being measured.
+	State int
+	// Measured is the number of pulses measured before giving up.
+	Measured int
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *PulseTimeoutError) Error() string {
+	return fmt.Sprintf("gpio: gave up waiting for pulse %v at state %v: %v", e.Measured+1, e.State, e.Err)
+}
+
+// Timeout reports whether the measurement gave up because of a deadline
+// rather than a cancellation.
+func (e *PulseTimeoutError) Timeout() bool {
+	return e.Err == context.DeadlineExceeded
+}
+
+// IsPulseTimeout reports whether err indicates that a pulse measurement
+// did not complete in time.
+func IsPulseTimeout(err error) bool {
+	_, ok := err.(*PulseTimeoutError)
+	return ok
+}
+
+// PulseTimeout bounds the wait for a pulse in the TimePulse methods of the
+// digital pins, which take no context.
+var PulseTimeout = time.Second
+
+// pulseClock is the clock of PollPulses, replaced by the tests.
+var pulseClock = time.Now
+
+// PulseTimer is implemented by digital pins able to measure pulses from
+// timestamped edge events rather than by polling their value.
+type PulseTimer interface {
+	// TimePulses measures the durations of n consecutive pulses at state.
+	TimePulses(ctx context.Context, state, n int) ([]time.Duration, error)
+}
+
+// TimePulse measures the duration of a pulse at state on the pin, waiting
+// for any pulse in progress to end first. It gives up with a
+// *PulseTimeoutError once ctx is done.
+func TimePulse(ctx context.Context, pin DigitalPin, state int) (time.Duration, error) {
+	durations, err := TimePulses(ctx, pin, state, 1)
+	if err != nil {
+		return 0, err
+	}
+	return durations[0], nil
+}
+
+// TimePulseTimeout is like TimePulse, giving up after timeout.
+func TimePulseTimeout(pin DigitalPin, state int, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return TimePulse(ctx, pin, state)
+}
+
+// TimePulses measures the durations of n consecutive pulses at state on
+// the pin, waiting for any pulse in progress to end first. Pins
+// implementing PulseTimer measure the pulses themselves; the others are
+// polled with PollPulses. It gives up with a *PulseTimeoutError once ctx
+// is done.
+func TimePulses(ctx context.Context, pin DigitalPin, state, n int) ([]time.Duration, error) {
+	if pt, ok := pin.(PulseTimer); ok {
+		return pt.TimePulses(ctx, state, n)
+	}
+	return PollPulses(ctx, pin, state, n)
+}
+
+// PollPulses measures the durations of n consecutive pulses at state on
+// the pin by continuously reading its value. It gives up with a
+// *PulseTimeoutError once ctx is done.
+func PollPulses(ctx context.Context, pin DigitalPin, state, n int) ([]time.Duration, error) {
+	aroundState := Low
+	if state == Low {
+		aroundState = High
+	}
+
+	durations := make([]time.Duration, 0, n)
+	waitFor := func(v int) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return &PulseTimeoutError{State: state, Measured: len(durations), Err: ctx.Err()}
+			default:
+			}
+			read, err := pin.Read()
+			if err != nil {
+				return err
+			}
+			if read == v {
+				return nil
+			}
+		}
+	}
+
+	// Wait for any previous pulse to end.
+	if err := waitFor(aroundState); err != nil {
+		return nil, err
+	}
+	for len(durations) < n {
+		if err := waitFor(state); err != nil {
+			return nil, err
+		}
+		start := pulseClock()
+		if err := waitFor(aroundState); err != nil {
+			return nil, err
+		}
+		durations = append(durations, pulseClock().Sub(start))
+	}
+
+	return durations, nil
+}
+
+// PulseEdge is a timestamped edge event, as used by MeasurePulses.
+type PulseEdge struct {
+	Time  time.Time
+	Value int
+}
+
+// MeasurePulses measures the durations of n consecutive pulses at state
+// from the edge events received on edges, the first of which carries the
+// value of the pin when the measurement starts. Events which do not
+// change the value, caused by edges missed in between, are skipped. It is
+// meant for pins implementing PulseTimer.
+func MeasurePulses(ctx context.Context, edges <-chan PulseEdge, state, n int) ([]time.Duration, error) {
+	durations := make([]time.Duration, 0, n)
+	value := -1
+	var start time.Time
+	for len(durations) < n {
+		select {
+		case <-ctx.Done():
+			return nil, &PulseTimeoutError{State: state, Measured: len(durations), Err: ctx.Err()}
+		case e := <-edges:
+			switch {
+			case e.Value == value:
+				continue
+			case value == -1:
+				// The initial value; any pulse in progress is skipped.
+			case e.Value == state:
+				start = e.Time
+			case !start.IsZero():
+				durations = append(durations, e.Time.Sub(start))
+			}
+			value = e.Value
+		}
+	}
+	return durations, nil
+}
@@ -0,0 +1,91 @@
+package embd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// scriptedPin reads high during the given windows of a simulated time,
+// which advances by step at each read.
+type scriptedPin struct {
+	fakeDigitalPin
+	t       time.Duration
+	step    time.Duration
+	windows [][2]time.Duration
+}
+
+func (p *scriptedPin) Read() (int, error) {
+	v := Low
+	for _, w := range p.windows {
+		if p.t >= w[0] && p.t < w[1] {
+			v = High
+		}
+	}
+	p.t += p.step
+	return v, nil
+}
+
+func TestPollPulses(t *testing.T) {
+	ms := time.Millisecond
+	pin := &scriptedPin{
+		step: ms / 10,
+		windows: [][2]time.Duration{
+			{0, 2 * ms}, // In progress, skipped.
+			{5 * ms, 10 * ms},
+			{15 * ms, 25 * ms},
+		},
+	}
+	base := time.Now()
+	defer func(c func() time.Time) { pulseClock = c }(pulseClock)
+	pulseClock = func() time.Time { return base.Add(pin.t) }
+
+	durations, err := TimePulses(context.Background(), pin, High, 2)
+	if err != nil {
+		t.Fatalf("Measuring 2 pulses: got %v", err)
+	}
+	if durations[0] != 5*ms || durations[1] != 10*ms {
+		t.Errorf("Measuring 2 pulses: got %v, want [5ms 10ms]", durations)
+	}
+}
+
+func TestTimePulseTimeout(t *testing.T) {
+	pin := &scriptedPin{step: time.Microsecond}
+	_, err := TimePulseTimeout(pin, High, 10*time.Millisecond)
+	if !IsPulseTimeout(err) {
+		t.Fatalf("Measuring pulse on idle pin: got %v, want a timeout", err)
+	}
+	if e := err.(*PulseTimeoutError); !e.Timeout() || e.Measured != 0 {
+		t.Fatalf("Measuring pulse on idle pin: got %+v, want a timeout after 0 pulses", e)
+	}
+}
+
+func TestMeasurePulses(t *testing.T) {
+	base := time.Now()
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	edges := make(chan PulseEdge, 8)
+	for _, e := range []PulseEdge{
+		{at(0), High}, // In progress, skipped.
+		{at(1), Low},
+		{at(2), High},
+		{at(5), Low},
+		{at(6), Low}, // Missed edges.
+		{at(7), High},
+		{at(8), Low},
+	} {
+		edges <- e
+	}
+	durations, err := MeasurePulses(context.Background(), edges, High, 2)
+	if err != nil {
+		t.Fatalf("Measuring pulses from edges: got %v", err)
+	}
+	if durations[0] != 3*time.Millisecond || durations[1] != time.Millisecond {
+		t.Fatalf("Measuring pulses from edges: got %v, want [3ms 1ms]", durations)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := MeasurePulses(ctx, edges, High, 1); !IsPulseTimeout(err) {
+		t.Fatalf("Measuring pulses with cancelled context: got %v, want a timeout", err)
+	}
+}
@@ -12,14 +12,14 @@ package main
 // (link privides additional instructions for wiring your pi)
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"os"
 	"os/signal"
-	"time"
 
 	"github.com/kidoman/embd"
 	_ "github.com/kidoman/embd/host/rpi"
+	"github.com/kidoman/embd/motion/stepper"
 )
 
 func main() {
@@ -43,67 +43,39 @@ func main() {
 			panic(err)
 		}
 		defer pin.Close()
-		if err := pin.SetDirection(embd.Out); err != nil {
-			panic(err)
-		}
-		if err := pin.Write(embd.Low); err != nil {
-			panic(err)
-		}
 		defer pin.SetDirection(embd.In)
 
 		stepPins[i] = pin
 	}
 
-	// Define sequence described in manufacturer's datasheet
-	seq := [][]int{
-		[]int{1, 0, 0, 0},
-		[]int{1, 1, 0, 0},
-		[]int{0, 1, 0, 0},
-		[]int{0, 1, 1, 0},
-		[]int{0, 0, 1, 0},
-		[]int{0, 0, 1, 1},
-		[]int{0, 0, 0, 1},
-		[]int{1, 0, 0, 1},
+	// Use the half step sequence described in manufacturer's datasheet
+	drv, err := stepper.NewUnipolar(stepper.HalfStep, stepPins[0], stepPins[1], stepPins[2], stepPins[3])
+	if err != nil {
+		panic(err)
 	}
-	stepCount := len(seq) - 1
-	stepDir := 2 // Set to 1 or 2 for clockwise, -1 or -2 for counter-clockwise
+	defer drv.Close()
+
+	motor := stepper.New(drv)
+	motor.MaxSpeed = 1000 / float64(*stepDelay)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, os.Kill)
 	defer signal.Stop(quit)
 
-	// Start main loop
-	ticker := time.NewTicker(time.Duration(*stepDelay) * time.Millisecond)
-	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-quit
+		cancel()
+	}()
 
-	var stepCounter int
+	// Turn clockwise, a revolution (4096 half steps) at a time, until
+	// interrupted.
 	for {
-		select {
-		case <-ticker.C:
-			// set pins to appropriate values for given position in the sequence
-			for i, pin := range stepPins {
-				if seq[stepCounter][i] != 0 {
-					fmt.Printf("Enable pin %d, step %d\n", i, stepCounter)
-					if err := pin.Write(embd.High); err != nil {
-						panic(err)
-					}
-				} else {
-					if err := pin.Write(embd.Low); err != nil {
-						panic(err)
-					}
-				}
-			}
-			stepCounter += stepDir
-
-			// If we reach the end of the sequence start again
-			if stepCounter >= stepCount {
-				stepCounter = 0
-			} else if stepCounter < 0 {
-				stepCounter = stepCount
+		if err := motor.Move(ctx, 4096); err != nil {
+			if err == context.Canceled {
+				return
 			}
-
-		case <-quit:
-			return
+			panic(err)
 		}
 	}
 }
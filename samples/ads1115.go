@@ -0,0 +1,41 @@
+// +build ignore
+
+// this sample uses the ads1x15 package to read the voltages on the inputs of an ads1115 16-bit ADC
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/convertors/ads1x15"
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	flag.Parse()
+
+	if err := embd.InitI2C(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseI2C()
+
+	bus := embd.NewI2CBus(1)
+
+	adc := ads1x15.NewADS1115(bus, ads1x15.DefaultAddr)
+	adc.Gain = ads1x15.Gain1
+
+	for {
+		for _, in := range []ads1x15.Input{ads1x15.AIN0, ads1x15.AIN1, ads1x15.Diff23} {
+			v, err := adc.Voltage(in)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Printf("%v: %.4fV  ", in, v)
+		}
+		fmt.Println()
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -27,11 +27,11 @@ func main() {
 	defer sensor.Close()
 
 	for {
-		lighting, err := sensor.Lighting()
+		lighting, err := sensor.Illuminance()
 		if err != nil {
 			panic(err)
 		}
-		fmt.Printf("Lighting is %v lx\n", lighting)
+		fmt.Printf("Lighting is %v\n", lighting)
 
 		time.Sleep(500 * time.Millisecond)
 	}
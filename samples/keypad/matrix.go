@@ -0,0 +1,55 @@
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/keypad/matrix"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	flag.Parse()
+
+	if err := embd.InitGPIO(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseGPIO()
+
+	var rows, cols []embd.DigitalPin
+	for _, n := range []int{4, 17, 27, 22} {
+		pin, err := embd.NewDigitalPin(n)
+		if err != nil {
+			panic(err)
+		}
+		defer pin.Close()
+		rows = append(rows, pin)
+	}
+	for _, n := range []int{23, 24, 25, 18} {
+		pin, err := embd.NewDigitalPin(n)
+		if err != nil {
+			panic(err)
+		}
+		defer pin.Close()
+		cols = append(cols, pin)
+	}
+
+	keypad := matrix.New(rows, cols, [][]string{
+		{"1", "2", "3", "A"},
+		{"4", "5", "6", "B"},
+		{"7", "8", "9", "C"},
+		{"*", "0", "#", "D"},
+	})
+	if err := keypad.Start(); err != nil {
+		panic(err)
+	}
+	defer keypad.Stop()
+
+	for e := range keypad.C {
+		fmt.Printf("%v: %v (held %v)\n", e.Key, e.Type, e.Held)
+	}
+}
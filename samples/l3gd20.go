@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/kidoman/embd"
 	"github.com/kidoman/embd/sensor/l3gd20"
+	"github.com/kidoman/embd/sensor/poll"
 
 	_ "github.com/kidoman/embd/host/all"
 )
@@ -28,22 +30,29 @@ func main() {
 	gyro := l3gd20.New(bus, l3gd20.R250DPS)
 	defer gyro.Close()
 
-	gyro.Start()
+	s := poll.NewScheduler()
+	if err := s.Add(gyro.Source("gyro")); err != nil {
+		panic(err)
+	}
+	sub := s.Subscribe(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, os.Kill)
 
-	orientations, err := gyro.Orientations()
-	if err != nil {
-		panic(err)
-	}
-
 	timer := time.Tick(250 * time.Millisecond)
-
 	for {
 		select {
 		case <-timer:
-			orientation := <-orientations
+			r := <-sub.C
+			if r.Err != nil {
+				fmt.Println(r.Err)
+				continue
+			}
+			orientation := r.Value.(l3gd20.Orientation)
 			fmt.Printf("x: %v, y: %v, z: %v\n", orientation.X, orientation.Y, orientation.Z)
 		case <-quit:
 			return
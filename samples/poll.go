@@ -0,0 +1,66 @@
+// +build ignore
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor/bh1750fvi"
+	"github.com/kidoman/embd/sensor/bmp180"
+	"github.com/kidoman/embd/sensor/poll"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	flag.Parse()
+
+	if err := embd.InitI2C(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseI2C()
+
+	bus := embd.NewI2CBus(1)
+
+	baro := bmp180.New(bus)
+	defer baro.Close()
+	light := bh1750fvi.NewHighMode(bus)
+	defer light.Close()
+
+	s := poll.NewScheduler()
+	sources := []poll.Source{
+		{Name: "temperature", Interval: time.Second, Bus: bus, Read: poll.Thermometer(baro)},
+		{Name: "pressure", Interval: 5 * time.Second, Bus: bus, Read: poll.Barometer(baro)},
+		{Name: "lighting", Interval: 500 * time.Millisecond, Bus: bus, Read: poll.Luxmeter(light)},
+	}
+	for _, src := range sources {
+		if err := s.Add(src); err != nil {
+			panic(err)
+		}
+	}
+	sub := s.Subscribe(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, os.Kill)
+	go func() {
+		<-quit
+		cancel()
+	}()
+
+	for r := range sub.C {
+		if r.Err != nil {
+			fmt.Printf("%v: %v failed: %v\n", r.Time.Format(time.StampMilli), r.Source, r.Err)
+			continue
+		}
+		fmt.Printf("%v: %v is %v\n", r.Time.Format(time.StampMilli), r.Source, r.Value)
+	}
+}
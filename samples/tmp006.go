@@ -3,12 +3,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor/poll"
 	"github.com/kidoman/embd/sensor/tmp006"
 
 	_ "github.com/kidoman/embd/host/all"
@@ -32,7 +34,13 @@ func main() {
 	}
 	defer sensor.Close()
 
-	sensor.Start()
+	s := poll.NewScheduler()
+	if err := sensor.Start(s); err != nil {
+		panic(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, os.Kill)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/units"
 )
 
 //accuracy = sensorValue/actualValue] (min = 0.96, typ = 1.2, max = 1.44
@@ -26,14 +27,15 @@ const (
 
 // BH1750FVI represents a BH1750FVI ambient light sensor.
 type BH1750FVI struct {
-	Bus  embd.I2CBus
+	Bus embd.I2CBus
+
+	// Poll is unused.
+	//
+	// Deprecated: poll the sensor with a poll.Scheduler instead.
 	Poll int
 
 	mu sync.RWMutex
 
-	lightingReadings chan float64
-	quit             chan bool
-
 	i2cAddr       byte
 	operationCode byte
 }
@@ -76,47 +78,21 @@ func (d *BH1750FVI) measureLighting() (float64, error) {
 
 // Lighting returns the ambient lighting in lx.
 func (d *BH1750FVI) Lighting() (float64, error) {
-	select {
-	case lighting := <-d.lightingReadings:
-		return lighting, nil
-	default:
-		return d.measureLighting()
-	}
+	return d.measureLighting()
 }
 
-// Run starts continuous sensor data acquisition loop.
+// Illuminance returns the ambient illuminance.
+func (d *BH1750FVI) Illuminance() (units.Lux, error) {
+	l, err := d.Lighting()
+	return units.Lux(l), err
+}
+
+// Run does nothing: the readings are taken when requested.
+//
+// Deprecated: poll the sensor with a poll.Scheduler instead.
 func (d *BH1750FVI) Run() {
-	go func() {
-		d.quit = make(chan bool)
-
-		timer := time.Tick(time.Duration(d.Poll) * time.Millisecond)
-
-		var lighting float64
-
-		for {
-			select {
-			case d.lightingReadings <- lighting:
-			case <-timer:
-				l, err := d.measureLighting()
-				if err == nil {
-					lighting = l
-				}
-				if err == nil && d.lightingReadings == nil {
-					d.lightingReadings = make(chan float64)
-				}
-			case <-d.quit:
-				d.lightingReadings = nil
-				return
-			}
-		}
-	}()
-	return
 }
 
 // Close.
 func (d *BH1750FVI) Close() {
-	if d.quit != nil {
-		d.quit <- true
-	}
-	return
 }
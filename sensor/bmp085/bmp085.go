@@ -9,6 +9,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/units"
 )
 
 const (
@@ -33,6 +34,10 @@ const (
 
 	tempReadDelay = 5 * time.Millisecond
 
+	// tempMaxAge is the age beyond which the temperature the pressure is
+	// compensated with is measured again.
+	tempMaxAge = time.Second
+
 	p0 = 101325
 
 	pollDelay = 250
@@ -40,7 +45,11 @@ const (
 
 // BMP085 represents a Bosch BMP085 barometric sensor.
 type BMP085 struct {
-	Bus  embd.I2CBus
+	Bus embd.I2CBus
+
+	// Poll is unused.
+	//
+	// Deprecated: poll the sensor with a poll.Scheduler instead.
 	Poll int
 
 	oss uint
@@ -49,13 +58,9 @@ type BMP085 struct {
 	ac4, ac5, ac6      uint16
 	b1, b2, mb, mc, md int16
 	b5                 int32
+	b5Time             time.Time
 	calibrated         bool
 	cmu                sync.RWMutex
-
-	temps     chan uint16
-	pressures chan int32
-	altitudes chan float64
-	quit      chan struct{}
 }
 
 // New returns a handle to a BMP085 sensor.
@@ -174,6 +179,7 @@ func (d *BMP085) calcTemp(utemp uint16) uint16 {
 
 	d.cmu.Lock()
 	d.b5 = int32(x1 + x2)
+	d.b5Time = time.Now()
 	d.cmu.Unlock()
 
 	return uint16((d.b5 + 8) >> 4)
@@ -195,20 +201,12 @@ func (d *BMP085) measureTemp() (uint16, error) {
 }
 
 // Temperature returns the current temperature reading.
-func (d *BMP085) Temperature() (float64, error) {
-	select {
-	case t := <-d.temps:
-		temp := float64(t) / 10
-		return temp, nil
-	default:
-		glog.V(1).Infof("bcm085: no temps available... measuring")
-		t, err := d.measureTemp()
-		if err != nil {
-			return 0, err
-		}
-		temp := float64(t) / 10
-		return temp, nil
+func (d *BMP085) Temperature() (units.Celsius, error) {
+	t, err := d.measureTemp()
+	if err != nil {
+		return 0, err
 	}
+	return units.Celsius(t) / 10, nil
 }
 
 func (d *BMP085) readUncompensatedPressure() (uint32, error) {
@@ -291,6 +289,16 @@ func (d *BMP085) measurePressureAndAltitude() (int32, float64, error) {
 		return 0, 0, err
 	}
 
+	// The pressure is compensated with the temperature, which changes
+	// slowly enough to be measured at most once a second.
+	d.cmu.RLock()
+	age := time.Since(d.b5Time)
+	d.cmu.RUnlock()
+	if age > tempMaxAge {
+		if _, err := d.measureTemp(); err != nil {
+			return 0, 0, err
+		}
+	}
 	upressure, err := d.readUncompensatedPressure()
 	if err != nil {
 		return 0, 0, err
@@ -304,90 +312,37 @@ func (d *BMP085) measurePressureAndAltitude() (int32, float64, error) {
 }
 
 // Pressure returns the current pressure reading.
-func (d *BMP085) Pressure() (int, error) {
+func (d *BMP085) Pressure() (units.Pascal, error) {
 	if err := d.calibrate(); err != nil {
 		return 0, err
 	}
 
-	select {
-	case p := <-d.pressures:
-		return int(p), nil
-	default:
-		glog.V(1).Infof("bcm085: no pressures available... measuring")
-		p, _, err := d.measurePressureAndAltitude()
-		if err != nil {
-			return 0, err
-		}
-		return int(p), nil
+	p, _, err := d.measurePressureAndAltitude()
+	if err != nil {
+		return 0, err
 	}
+	return units.Pascal(p), nil
 }
 
 // Altitude returns the current altitude reading.
-func (d *BMP085) Altitude() (float64, error) {
+func (d *BMP085) Altitude() (units.Meters, error) {
 	if err := d.calibrate(); err != nil {
 		return 0, err
 	}
 
-	select {
-	case altitude := <-d.altitudes:
-		return altitude, nil
-	default:
-		glog.V(1).Info("bcm085: no altitudes available... measuring")
-		_, altitude, err := d.measurePressureAndAltitude()
-		if err != nil {
-			return 0, err
-		}
-		return altitude, nil
+	_, altitude, err := d.measurePressureAndAltitude()
+	if err != nil {
+		return 0, err
 	}
+	return units.Meters(altitude), nil
 }
 
-// Run starts the sensor data acquisition loop.
+// Run does nothing: the readings are taken when requested.
+//
+// Deprecated: poll the sensor with a poll.Scheduler instead.
 func (d *BMP085) Run() {
-	go func() {
-		d.quit = make(chan struct{})
-		timer := time.Tick(time.Duration(d.Poll) * time.Millisecond)
-
-		var temp uint16
-		var pressure int32
-		var altitude float64
-
-		for {
-			select {
-			case <-timer:
-				t, err := d.measureTemp()
-				if err == nil {
-					temp = t
-				}
-				if err == nil && d.temps == nil {
-					d.temps = make(chan uint16)
-				}
-				p, a, err := d.measurePressureAndAltitude()
-				if err == nil {
-					pressure = p
-					altitude = a
-				}
-				if err == nil && d.pressures == nil && d.altitudes == nil {
-					d.pressures = make(chan int32)
-					d.altitudes = make(chan float64)
-				}
-			case d.temps <- temp:
-			case d.pressures <- pressure:
-			case d.altitudes <- altitude:
-			case <-d.quit:
-				d.temps = nil
-				d.pressures = nil
-				d.altitudes = nil
-				return
-			}
-		}
-	}()
-
-	return
 }
 
 // Close.
 func (d *BMP085) Close() {
-	if d.quit != nil {
-		d.quit <- struct{}{}
-	}
 }
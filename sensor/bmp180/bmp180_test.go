@@ -0,0 +1,77 @@
+package bmp180
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kidoman/embd/host/sim"
+)
+
+// fakeBus is a sensor with the calibration and readings of the example of
+// the datasheet: 15.0°C and 69964Pa.
+type fakeBus struct {
+	sim.I2CBus
+
+	tempConversions int
+	tempErr         error
+}
+
+var calibration = map[byte]int16{
+	calAc1: 408, calAc2: -72, calAc3: -14383, calAc4: 32741, calAc5: 32757, calAc6: 23153,
+	calB1: 6190, calB2: 4, calMB: -32768, calMC: -8711, calMD: 2868,
+}
+
+func (b *fakeBus) ReadWordFromReg(addr, reg byte) (uint16, error) {
+	if reg == tempData {
+		return 27898, b.tempErr
+	}
+	v, ok := calibration[reg]
+	if !ok {
+		return 0, errors.New("unexpected read")
+	}
+	return uint16(v), nil
+}
+
+func (b *fakeBus) ReadFromReg(addr, reg byte, value []byte) error {
+	copy(value, []byte{0x5D, 0x23, 0x00})
+	return nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	if reg == control && value == readTempCmd {
+		b.tempConversions++
+	}
+	return nil
+}
+
+func TestPressure(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus)
+
+	p, err := d.Pressure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != 69964 {
+		t.Errorf("Pressure: got %v, want 69964Pa", float64(p))
+	}
+	if bus.tempConversions != 1 {
+		t.Errorf("Pressure: got %v temperature conversions, want 1", bus.tempConversions)
+	}
+
+	if _, err := d.Pressure(); err != nil {
+		t.Fatal(err)
+	}
+	if bus.tempConversions != 1 {
+		t.Errorf("Pressure within a second: got %v temperature conversions, want 1", bus.tempConversions)
+	}
+}
+
+func TestPressureTempError(t *testing.T) {
+	errRead := errors.New("read failed")
+	d := New(&fakeBus{tempErr: errRead})
+
+	if _, err := d.Pressure(); err != errRead {
+		t.Errorf("Pressure with a failing temperature read: got %v, want %v", err, errRead)
+	}
+}
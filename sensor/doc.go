@@ -1,2 +1,9 @@
 // Package sensor contains the various sensors modules for use on your platform.
+//
+// The interfaces of this package are implemented by the sensors measuring
+// the same quantity, in the types of package units, so that application
+// code can use any of them:
+//
+//	var t sensor.Thermometer = bmp180.New(bus)
+//	temp, err := t.Temperature()
 package sensor
@@ -0,0 +1,44 @@
+// Package encoder allows interfacing with quadrature encoders, be it rotary
+// knobs or the feedback encoders of motors.
+//
+// The A/B channels are decoded in software from the interrupts of two
+// digital pins, or by the eQEP modules of the BeagleBone Black when they
+// are enabled.
+package encoder
+
+import "github.com/kidoman/embd"
+
+// Encoder is implemented by the quadrature decoders of this package.
+// Positions are expressed in counts, four per cycle of the A/B channels.
+type Encoder interface {
+	// Position returns the position of the encoder.
+	Position() (int64, error)
+
+	// SetPosition sets the current position of the encoder.
+	SetPosition(pos int64) error
+
+	// Velocity returns the velocity of the encoder in counts per second.
+	Velocity() (float64, error)
+
+	// Close releases the resources associated with the encoder.
+	Close() error
+}
+
+// Open returns an encoder using the BeagleBone Black eQEP module unit if
+// the host provides it, and decoding a, b and index (which may be nil) in
+// software otherwise. Errors other than the module not being found, such
+// as failing to enable it, are returned.
+func Open(unit int, a, b, index embd.DigitalPin) (Encoder, error) {
+	e, err := NewEQEP(unit)
+	if err == nil {
+		return e, nil
+	}
+	if !embd.IsDeviceNotFound(err) {
+		return nil, err
+	}
+	q := NewQuadrature(a, b, index)
+	if err := q.Start(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,198 @@
+package encoder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+type testEncoder struct {
+	*Quadrature
+	a, b, index *sim.Pin
+	clock       *fakeClock
+	state       int
+}
+
+func newTestEncoder(t *testing.T) *testEncoder {
+	e := &testEncoder{
+		a:     sim.NewPin(0),
+		b:     sim.NewPin(1),
+		index: sim.NewPin(2),
+		clock: &fakeClock{t: time.Unix(0, 0)},
+	}
+	e.Quadrature = NewQuadrature(e.a, e.b, e.index)
+	e.Clock = e.clock.now
+	if err := e.Start(); err != nil {
+		t.Fatalf("Starting encoder: got %v", err)
+	}
+	return e
+}
+
+// gray is the A/B sequence when A leads B.
+var gray = []int{0, 2, 3, 1}
+
+// step moves the simulated encoder by n counts, every d.
+func (e *testEncoder) step(n int, d time.Duration) {
+	dir := 1
+	if n < 0 {
+		dir, n = -1, -n
+	}
+	for i := 0; i < n; i++ {
+		e.clock.t = e.clock.t.Add(d)
+		e.state = (e.state + dir + 4) % 4
+		e.set(gray[e.state])
+	}
+}
+
+// set drives the channels to the A/B state ab, A first.
+func (e *testEncoder) set(ab int) {
+	e.a.Drive(ab >> 1)
+	e.b.Drive(ab & 1)
+}
+
+func TestQuadraturePosition(t *testing.T) {
+	e := newTestEncoder(t)
+	defer e.Close()
+
+	e.step(10, time.Millisecond)
+	if pos, _ := e.Position(); pos != 10 {
+		t.Errorf("10 counts forward: got position %v, want 10", pos)
+	}
+	e.step(-25, time.Millisecond)
+	if pos, _ := e.Position(); pos != -15 {
+		t.Errorf("25 counts backward: got position %v, want -15", pos)
+	}
+	if n := e.Errors(); n != 0 {
+		t.Errorf("Valid transitions: got %v errors, want 0", n)
+	}
+}
+
+func TestQuadratureInvalidTransition(t *testing.T) {
+	e := newTestEncoder(t)
+	e.Close()
+
+	// Both channels changing at once, from 00 to 11, before the
+	// interrupt is handled.
+	e.set(3)
+	e.edge(e.a)
+	if pos, _ := e.Position(); pos != 0 {
+		t.Errorf("Transition from 00 to 11: got position %v, want 0", pos)
+	}
+	if n := e.Errors(); n != 1 {
+		t.Fatalf("Transition from 00 to 11: got %v errors, want 1", n)
+	}
+}
+
+func TestQuadratureVelocity(t *testing.T) {
+	e := newTestEncoder(t)
+	defer e.Close()
+	e.VelocityWindow = 100 * time.Millisecond
+
+	e.step(200, time.Millisecond)
+	if v, _ := e.Velocity(); v != 1000 {
+		t.Errorf("1 count per ms: got velocity %v, want 1000", v)
+	}
+	e.step(-50, 2*time.Millisecond)
+	if v, _ := e.Velocity(); v != -500 {
+		t.Errorf("-1 count per 2ms: got velocity %v, want -500", v)
+	}
+	e.clock.t = e.clock.t.Add(time.Second)
+	if v, _ := e.Velocity(); v != 0 {
+		t.Errorf("Still encoder: got velocity %v, want 0", v)
+	}
+}
+
+func TestQuadratureIndex(t *testing.T) {
+	e := newTestEncoder(t)
+	defer e.Close()
+	e.ResetOnIndex = true
+
+	e.step(7, time.Millisecond)
+	e.index.Drive(embd.High)
+	e.index.Drive(embd.Low)
+	if n, at := e.IndexCount(); n != 1 || at != 7 {
+		t.Errorf("Index pulse: got %v pulses at %v, want 1 at 7", n, at)
+	}
+	if pos, _ := e.Position(); pos != 0 {
+		t.Errorf("Index pulse: got position %v, want 0", pos)
+	}
+}
+
+func TestEQEP(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "eqep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := filepath.Join(tmp, "ocp.3", "48302000.epwmss", "48302180.eqep")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "position"), []byte("1234\n"), 0644)
+
+	defer func(p string) { eqepPattern = p }(eqepPattern)
+	eqepPattern = filepath.Join(tmp, "ocp.*", "*.epwmss", "%v.eqep")
+
+	if _, err := NewEQEP(0); !embd.IsDeviceNotFound(err) {
+		t.Errorf("Opening missing eqep unit 0: got %v, want a device not found error", err)
+	}
+	e, err := NewEQEP(1)
+	if err != nil {
+		t.Fatalf("Opening eqep unit 1: got %v", err)
+	}
+	if pos, err := e.Position(); err != nil || pos != 1234 {
+		t.Errorf("Reading eqep position: got %v, %v, want 1234", pos, err)
+	}
+	if err := e.SetPosition(-5); err != nil {
+		t.Fatalf("Setting eqep position: got %v", err)
+	}
+	if pos, _ := e.Position(); pos != -5 {
+		t.Errorf("Reading eqep position after setting it: got %v, want -5", pos)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "enabled")); string(data) != "1" {
+		t.Errorf("Opening eqep unit 1: enabled is %q, want %q", data, "1")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "eqep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// Unit 0 has a directory which cannot be written to, unit 1 has none.
+	dir := filepath.Join(tmp, "ocp.3", "48300000.epwmss", "48300180.eqep", "mode")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(p string) { eqepPattern = p }(eqepPattern)
+	eqepPattern = filepath.Join(tmp, "ocp.*", "*.epwmss", "%v.eqep")
+
+	if _, err := Open(0, sim.NewPin(0), sim.NewPin(1), nil); err == nil {
+		t.Error("Opening an encoder on a failing eqep unit: got nil error")
+	}
+	e, err := Open(1, sim.NewPin(0), sim.NewPin(1), nil)
+	if err != nil {
+		t.Fatalf("Opening an encoder without eqep unit: got %v", err)
+	}
+	defer e.Close()
+	if _, ok := e.(*Quadrature); !ok {
+		t.Errorf("Opening an encoder without eqep unit: got %T, want *Quadrature", e)
+	}
+}
@@ -0,0 +1,111 @@
+// eQEP support on the BBB.
+
+package encoder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+// eqepAddrs are the addresses of the eQEP modules of the AM335x.
+var eqepAddrs = []string{"48300180", "48302180", "48304180"}
+
+// eqepPattern locates the sysfs directory of an eQEP module given its
+// address.
+var eqepPattern = "/sys/devices/ocp.*/*.epwmss/%v.eqep"
+
+// EQEP reads the position counter of an eQEP module of the BeagleBone
+// Black, which decodes the channels in hardware. The module must be
+// enabled by a device tree overlay (e.g. bone_eqep1).
+type EQEP struct {
+	dir string
+
+	mu      sync.Mutex
+	last    int64
+	lastT   time.Time
+	sampled bool
+}
+
+// NewEQEP returns the eQEP module unit (0, 1 or 2), failing with an error
+// satisfying embd.IsDeviceNotFound if the host does not provide it.
+func NewEQEP(unit int) (*EQEP, error) {
+	if unit < 0 || unit >= len(eqepAddrs) {
+		return nil, fmt.Errorf("encoder: no eqep unit %v", unit)
+	}
+	pattern := fmt.Sprintf(eqepPattern, eqepAddrs[unit])
+	dir, err := embd.FindFirstMatchingFile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return nil, &embd.DeviceError{Path: pattern, Err: embd.ErrDeviceNotFound, Hint: "load the eQEP overlay of the unit"}
+	}
+
+	e := &EQEP{dir: dir}
+	// The counter is absolute (mode 0) and must be enabled.
+	if err := e.write("mode", 0); err != nil {
+		return nil, err
+	}
+	if err := e.write("enabled", 1); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *EQEP) read(name string) (int64, error) {
+	data, err := ioutil.ReadFile(path.Join(e.dir, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (e *EQEP) write(name string, v int64) error {
+	return ioutil.WriteFile(path.Join(e.dir, name), []byte(strconv.FormatInt(v, 10)), 0644)
+}
+
+// Position returns the position counter of the module.
+func (e *EQEP) Position() (int64, error) {
+	return e.read("position")
+}
+
+// SetPosition sets the position counter of the module.
+func (e *EQEP) SetPosition(pos int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.sampled = false
+	return e.write("position", pos)
+}
+
+// Velocity returns the velocity in counts per second since the previous
+// call, or 0 on the first call.
+func (e *EQEP) Velocity() (float64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pos, err := e.read("position")
+	if err != nil {
+		return 0, err
+	}
+	t := time.Now()
+
+	var v float64
+	if e.sampled && t.After(e.lastT) {
+		v = float64(pos-e.last) / t.Sub(e.lastT).Seconds()
+	}
+	e.last, e.lastT, e.sampled = pos, t, true
+	return v, nil
+}
+
+// Close disables the module.
+func (e *EQEP) Close() error {
+	return e.write("enabled", 0)
+}
@@ -0,0 +1,293 @@
+// Software quadrature decoding.
+
+package encoder
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// DefaultVelocityWindow is the window over which the velocity is averaged
+// when VelocityWindow is not set.
+const DefaultVelocityWindow = 100 * time.Millisecond
+
+const invalid = 2
+
+// transitions maps a transition between two A/B states (A<<1 | B),
+// indexed by prev<<2 | cur, to a count: +1 when A leads B, -1 when B
+// leads A, or invalid when both channels changed at once.
+var transitions = [16]int{
+	0, -1, +1, invalid,
+	+1, 0, invalid, -1,
+	-1, invalid, 0, +1,
+	invalid, +1, -1, 0,
+}
+
+type sample struct {
+	t   time.Time
+	pos int64
+}
+
+// Quadrature decodes the A/B channels of an encoder from the interrupts of
+// two digital pins. An optional index pin marks a reference position.
+type Quadrature struct {
+	A, B, Index embd.DigitalPin
+
+	// ResetOnIndex resets the position to zero on each rising edge of the
+	// index.
+	ResetOnIndex bool
+
+	// VelocityWindow is the window over which the velocity is averaged.
+	VelocityWindow time.Duration
+
+	// Clock returns the current time; time.Now is used if nil.
+	Clock func() time.Time
+
+	mu       sync.Mutex
+	state    int
+	pos      int64
+	errors   uint64
+	indexes  uint64
+	indexPos int64
+	index    int
+	samples  []sample
+
+	started bool
+}
+
+// NewQuadrature returns a software decoder of the A/B channels on a and
+// b. index may be nil.
+func NewQuadrature(a, b, index embd.DigitalPin) *Quadrature {
+	return &Quadrature{A: a, B: b, Index: index}
+}
+
+func (q *Quadrature) now() time.Time {
+	if q.Clock != nil {
+		return q.Clock()
+	}
+	return time.Now()
+}
+
+func (q *Quadrature) read() (int, error) {
+	a, err := q.A.Read()
+	if err != nil {
+		return 0, err
+	}
+	b, err := q.B.Read()
+	if err != nil {
+		return 0, err
+	}
+	return a<<1 | b, nil
+}
+
+// Start watches the pins.
+func (q *Quadrature) Start() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		return nil
+	}
+
+	pins := []embd.DigitalPin{q.A, q.B}
+	if q.Index != nil {
+		pins = append(pins, q.Index)
+	}
+	for _, pin := range pins {
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if q.state, err = q.read(); err != nil {
+		return err
+	}
+	if q.Index != nil {
+		if q.index, err = q.Index.Read(); err != nil {
+			return err
+		}
+	}
+
+	q.samples = []sample{{q.now(), q.pos}}
+
+	if err := q.A.Watch(embd.EdgeBoth, q.edge); err != nil {
+		return err
+	}
+	if err := q.B.Watch(embd.EdgeBoth, q.edge); err != nil {
+		q.A.StopWatching()
+		return err
+	}
+	if q.Index != nil {
+		if err := q.Index.Watch(embd.EdgeBoth, q.indexEdge); err != nil {
+			q.A.StopWatching()
+			q.B.StopWatching()
+			return err
+		}
+	}
+
+	q.started = true
+
+	return nil
+}
+
+// edge handles the interrupts of the A and B pins.
+func (q *Quadrature) edge(embd.DigitalPin) {
+	state, err := q.read()
+	if err != nil {
+		glog.Errorf("encoder: reading channels: %v", err)
+		return
+	}
+	t := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch d := transitions[q.state<<2|state]; d {
+	case 0:
+		return
+	case invalid:
+		q.errors++
+		glog.V(1).Infof("encoder: invalid transition from %02b to %02b", q.state, state)
+	default:
+		q.pos += int64(d)
+		q.record(t)
+	}
+	q.state = state
+}
+
+// indexEdge handles the interrupts of the index pin.
+func (q *Quadrature) indexEdge(pin embd.DigitalPin) {
+	v, err := pin.Read()
+	if err != nil {
+		glog.Errorf("encoder: reading index: %v", err)
+		return
+	}
+	t := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	rising := v == embd.High && q.index == embd.Low
+	q.index = v
+	if !rising {
+		return
+	}
+	q.indexes++
+	q.indexPos = q.pos
+	if q.ResetOnIndex {
+		q.pos = 0
+		q.samples = []sample{{t, 0}}
+	}
+}
+
+// record adds a position sample at t, dropping the samples older than the
+// velocity window. q.mu must be held.
+func (q *Quadrature) record(t time.Time) {
+	q.samples = append(q.samples, sample{t, q.pos})
+	q.trim(t)
+}
+
+func (q *Quadrature) window() time.Duration {
+	if q.VelocityWindow > 0 {
+		return q.VelocityWindow
+	}
+	return DefaultVelocityWindow
+}
+
+// trim drops the samples older than the velocity window, keeping one to
+// measure from. q.mu must be held.
+func (q *Quadrature) trim(t time.Time) {
+	from := t.Add(-q.window())
+	i := 0
+	for i < len(q.samples)-1 && !q.samples[i+1].t.After(from) {
+		i++
+	}
+	q.samples = q.samples[i:]
+}
+
+// Position returns the position of the encoder.
+func (q *Quadrature) Position() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.pos, nil
+}
+
+// SetPosition sets the current position of the encoder.
+func (q *Quadrature) SetPosition(pos int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pos = pos
+	q.samples = []sample{{q.now(), pos}}
+	return nil
+}
+
+// Velocity returns the velocity of the encoder in counts per second,
+// averaged over the velocity window.
+func (q *Quadrature) Velocity() (float64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t := q.now()
+	q.trim(t)
+	if len(q.samples) == 0 {
+		return 0, nil
+	}
+	first := q.samples[0]
+	if first.t.Before(t.Add(-q.window())) {
+		// The encoder has been still since.
+		first.t = t.Add(-q.window())
+	}
+	dt := t.Sub(first.t)
+	if dt <= 0 {
+		return 0, nil
+	}
+	return float64(q.pos-first.pos) / dt.Seconds(), nil
+}
+
+// Errors returns the number of invalid transitions seen, where both
+// channels changed at once, usually because of noise or an edge missed
+// at high speed.
+func (q *Quadrature) Errors() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.errors
+}
+
+// IndexCount returns the number of index pulses seen and the position at
+// the last one, before any reset.
+func (q *Quadrature) IndexCount() (uint64, int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.indexes, q.indexPos
+}
+
+// Close stops watching the pins. The pins themselves belong to the caller
+// and are left open.
+func (q *Quadrature) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.started {
+		return nil
+	}
+	q.started = false
+
+	if err := q.A.StopWatching(); err != nil {
+		return err
+	}
+	if err := q.B.StopWatching(); err != nil {
+		return err
+	}
+	if q.Index != nil {
+		return q.Index.StopWatching()
+	}
+	return nil
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor/poll"
+	"github.com/kidoman/embd/units"
 )
 
 const (
@@ -50,9 +52,8 @@ const (
 
 	zyxAvailable = 0x08
 
-	odr       = 95
-	mult      = 1.0 / odr
-	pollDelay = mult * 1000 * 1000
+	odr  = 95
+	mult = 1.0 / odr
 )
 
 // Range represents a L3GD20 range setting.
@@ -120,8 +121,9 @@ type L3GD20 struct {
 
 	xac, yac, zac axisCalibration
 
+	orientation  Orientation
+	last         time.Time
 	orientations chan Orientation
-	closing      chan chan struct{}
 }
 
 // New creates a new L3GD20 interface. The bus variable controls
@@ -197,7 +199,9 @@ func (d *L3GD20) setup() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	d.orientations = make(chan Orientation)
+	if d.orientations == nil {
+		d.orientations = make(chan Orientation, 1)
+	}
 
 	if err := d.Bus.WriteByteToReg(address, ctrlReg1, ctrlReg1Default); err != nil {
 		return err
@@ -299,8 +303,18 @@ func (d *L3GD20) OrientationDelta() (float64, float64, float64, error) {
 	return d.measureOrientationDelta()
 }
 
-// Temperature returns the current temperature reading.
-func (d *L3GD20) Temperature() (int, error) {
+// AngularRate returns the calibrated angular rate around each axis.
+func (d *L3GD20) AngularRate() (x, y, z units.DegreesPerSecond, err error) {
+	dx, dy, dz, err := d.measureOrientationDelta()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return units.DegreesPerSecond(dx), units.DegreesPerSecond(dy), units.DegreesPerSecond(dz), nil
+}
+
+// Temperature returns the current temperature reading. The sensor is not
+// factory calibrated: the reading is off by a constant for each part.
+func (d *L3GD20) Temperature() (units.Celsius, error) {
 	if err := d.setup(); err != nil {
 		return 0, err
 	}
@@ -310,12 +324,13 @@ func (d *L3GD20) Temperature() (int, error) {
 		return 0, err
 	}
 
-	temp := int(int8(data))
+	temp := units.Celsius(int8(data))
 
 	return temp, nil
 }
 
-// Orientations returns a channel which will have the current temperature reading.
+// Orientations returns a channel receiving the latest orientation
+// integrated by the Source of the gyroscope.
 func (d *L3GD20) Orientations() (<-chan Orientation, error) {
 	if err := d.setup(); err != nil {
 		return nil, err
@@ -324,54 +339,62 @@ func (d *L3GD20) Orientations() (<-chan Orientation, error) {
 	return d.orientations, nil
 }
 
-// Start starts the data acquisition loop.
-func (d *L3GD20) Start() error {
-	if err := d.setup(); err != nil {
-		return err
+// Source returns a source named name reading the angular rate at the
+// output data rate of the gyroscope, and integrating it into the
+// orientation. The values of the readings are Orientations.
+func (d *L3GD20) Source(name string) poll.Source {
+	return poll.Source{
+		Name:     name,
+		Interval: time.Second / odr,
+		Bus:      d.Bus,
+		Read: func() (interface{}, error) {
+			return d.integrate()
+		},
 	}
+}
 
-	d.closing = make(chan chan struct{})
-
-	go func() {
-		var x, y, z float64
-		var orientations chan Orientation
-
-		timer := time.Tick(time.Duration(math.Floor(pollDelay)) * time.Microsecond)
-
-		for {
-			select {
-			case <-timer:
-				dx, dy, dz, err := d.measureOrientationDelta()
-				if err != nil {
-					glog.Errorf("l3gd20: %v", err)
-				} else {
-					x += dx * mult
-					y += dy * mult
-					z += dz * mult
-					orientations = d.orientations
-				}
-			case orientations <- Orientation{x, y, z}:
-				orientations = nil
-			case waitc := <-d.closing:
-				waitc <- struct{}{}
-				close(d.orientations)
-				return
-			}
+func (d *L3GD20) integrate() (Orientation, error) {
+	x, y, z, err := d.AngularRate()
+	if err != nil {
+		return Orientation{}, err
+	}
+	now := time.Now()
 
-		}
-	}()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	return nil
+	dt := mult
+	if !d.last.IsZero() {
+		dt = now.Sub(d.last).Seconds()
+	}
+	d.last = now
+	d.orientation.X += float64(x) * dt
+	d.orientation.Y += float64(y) * dt
+	d.orientation.Z += float64(z) * dt
+
+	// Replace the orientation not received yet, if any.
+	select {
+	case <-d.orientations:
+	default:
+	}
+	d.orientations <- d.orientation
+
+	return d.orientation, nil
 }
 
-// Stop the data acquisition loop.
-func (d *L3GD20) Stop() error {
-	if d.closing != nil {
-		waitc := make(chan struct{})
-		d.closing <- waitc
-		<-waitc
-		d.closing = nil
+// Start adds the Source of the gyroscope, named "l3gd20", to s, which must
+// not be running yet. The orientations are integrated while s runs.
+func (d *L3GD20) Start(s *poll.Scheduler) error {
+	if err := d.setup(); err != nil {
+		return err
 	}
+
+	return s.Add(d.Source("l3gd20"))
+}
+
+// Stop powers the gyroscope down. The scheduler reading it must be stopped
+// first.
+func (d *L3GD20) Stop() error {
 	if err := d.Bus.WriteByteToReg(address, ctrlReg1, ctrlReg1Finished); err != nil {
 		return err
 	}
@@ -0,0 +1,109 @@
+package l3gd20
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/host/sim"
+)
+
+type fakeBus struct {
+	sim.I2CBus
+
+	mu   sync.Mutex
+	regs [256]byte
+}
+
+func newFakeBus() *fakeBus {
+	b := &fakeBus{}
+	b.regs[statusReg] = zyxAvailable | 0x07
+	return b
+}
+
+// setRates sets the raw rates of the axes.
+func (b *fakeBus) setRates(x, y, z int16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, v := range []int16{x, y, z} {
+		b.regs[xlReg+2*i] = byte(v)
+		b.regs[xhReg+2*i] = byte(v >> 8)
+	}
+}
+
+func (b *fakeBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	if addr != address {
+		return 0, errors.New("no device at address")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.regs[reg], nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	if addr != address {
+		return errors.New("no device at address")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.regs[reg] = value
+	return nil
+}
+
+func TestAngularRate(t *testing.T) {
+	bus := newFakeBus()
+	// The zero rate offsets measured while calibrating.
+	bus.setRates(2, -3, 0)
+	d := New(bus, R250DPS)
+	if err := d.setup(); err != nil {
+		t.Fatal(err)
+	}
+	if ctrl := bus.regs[ctrlReg4]; ctrl != R250DPS.value {
+		t.Errorf("Control register 4: got %#x, want %#x", ctrl, R250DPS.value)
+	}
+
+	bus.setRates(1000, -500, 0)
+	x, y, z, err := d.AngularRate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantX, wantY := (1000-2)*R250DPS.sensitivity, (-500+3)*R250DPS.sensitivity
+	if math.Abs(float64(x)-wantX) > 1e-9 || math.Abs(float64(y)-wantY) > 1e-9 || z != 0 {
+		t.Errorf("AngularRate: got %v, %v, %v, want %v, %v, 0", x, y, z, wantX, wantY)
+	}
+}
+
+func TestSource(t *testing.T) {
+	bus := newFakeBus()
+	d := New(bus, R250DPS)
+	src := d.Source("gyro")
+	if src.Interval != time.Second/odr || src.Bus != bus {
+		t.Errorf("Source: got interval %v and bus %v", src.Interval, src.Bus)
+	}
+	orientations, err := d.Orientations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1000 LSB is 8.75°/s, integrated over a first period of 1/95s.
+	bus.setRates(1000, 0, 0)
+	v, err := src.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 1000 * R250DPS.sensitivity * mult
+	if o := v.(Orientation); math.Abs(o.X-want) > 1e-9 || o.Y != 0 || o.Z != 0 {
+		t.Errorf("Reading: got %+v, want X %v", o, want)
+	}
+	if _, err := src.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if o := <-orientations; o.X <= want {
+		t.Errorf("Orientations: got %+v, want the latest orientation, past X %v", o, want)
+	}
+}
@@ -1,13 +1,13 @@
-// Package lsm303 allows interfacing with the LSM303 magnetometer.
+// Package lsm303 allows interfacing with the LSM303 magnetometer and
+// accelerometer.
 package lsm303
 
 import (
 	"math"
 	"sync"
-	"time"
 
-	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/units"
 )
 
 const (
@@ -39,20 +39,35 @@ const (
 	magDataSignal = 0x02
 	magData       = 0x03
 
+	// Gains at the default range of ±1.3 gauss, in LSB per gauss.
+	magGainXY = 1055
+	magGainZ  = 950
+
+	accelAddress  = 0x19
+	accelCtrlReg1 = 0x20
+	accelData     = 0x28 | 0x80 // Auto increment.
+
+	accelCtrlReg1Default = 0x27 // All axes enabled, normal mode.
+
+	// accelScale is the acceleration of a LSB at the default range of
+	// ±2g, the 12 bits of data being left aligned.
+	accelScale = units.StandardGravity / 1000 / 16
+
 	pollDelay = 250
 )
 
-// LSM303 represents a LSM303 magnetometer.
+// LSM303 represents a LSM303 magnetometer and accelerometer.
 type LSM303 struct {
-	Bus  embd.I2CBus
-	Poll int
-
-	initialized bool
-	mu          sync.RWMutex
+	Bus embd.I2CBus
 
-	headings chan float64
+	// Poll is unused.
+	//
+	// Deprecated: poll the sensor with a poll.Scheduler instead.
+	Poll int
 
-	quit chan struct{}
+	initialized      bool
+	accelInitialized bool
+	mu               sync.RWMutex
 }
 
 // New creates a new LSM303 interface. The bus variable controls
@@ -110,52 +125,80 @@ func (d *LSM303) measureHeading() (float64, error) {
 	return heading, nil
 }
 
-// Heading returns the current heading [0, 360).
-func (d *LSM303) Heading() (float64, error) {
-	select {
-	case heading := <-d.headings:
-		return heading, nil
-	default:
-		glog.V(2).Infof("lsm303: no headings available... measuring")
-		return d.measureHeading()
+// MagneticField returns the magnetic field along each axis.
+func (d *LSM303) MagneticField() (x, y, z units.Gauss, err error) {
+	if err := d.setup(); err != nil {
+		return 0, 0, 0, err
 	}
+
+	data := make([]byte, 6)
+	if err := d.Bus.ReadFromReg(magAddress, magData, data); err != nil {
+		return 0, 0, 0, err
+	}
+
+	x = units.Gauss(int16(data[0])<<8|int16(data[1])) / magGainXY
+	y = units.Gauss(int16(data[2])<<8|int16(data[3])) / magGainXY
+	z = units.Gauss(int16(data[4])<<8|int16(data[5])) / magGainZ
+
+	return x, y, z, nil
 }
 
-// Run starts the sensor data acquisition loop.
-func (d *LSM303) Run() error {
-	go func() {
-		d.quit = make(chan struct{})
-
-		timer := time.Tick(time.Duration(d.Poll) * time.Millisecond)
-
-		var heading float64
-
-		for {
-			select {
-			case <-timer:
-				h, err := d.measureHeading()
-				if err == nil {
-					heading = h
-				}
-				if err == nil && d.headings == nil {
-					d.headings = make(chan float64)
-				}
-			case d.headings <- heading:
-			case <-d.quit:
-				d.headings = nil
-				return
-			}
+func (d *LSM303) setupAccel() error {
+	d.mu.RLock()
+	if d.accelInitialized {
+		d.mu.RUnlock()
+		return nil
+	}
+	d.mu.RUnlock()
 
-		}
-	}()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.Bus.WriteByteToReg(accelAddress, accelCtrlReg1, accelCtrlReg1Default); err != nil {
+		return err
+	}
+
+	d.accelInitialized = true
 
 	return nil
 }
 
-// Close the sensor data acquisition loop and put the LSM303 into sleep mode.
+// Acceleration returns the acceleration along each axis.
+func (d *LSM303) Acceleration() (x, y, z units.MetersPerSecondSquared, err error) {
+	if err := d.setupAccel(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	data := make([]byte, 6)
+	if err := d.Bus.ReadFromReg(accelAddress, accelData, data); err != nil {
+		return 0, 0, 0, err
+	}
+
+	x = units.MetersPerSecondSquared(int16(data[1])<<8|int16(data[0])) * accelScale
+	y = units.MetersPerSecondSquared(int16(data[3])<<8|int16(data[2])) * accelScale
+	z = units.MetersPerSecondSquared(int16(data[5])<<8|int16(data[4])) * accelScale
+
+	return x, y, z, nil
+}
+
+// Heading returns the current heading [0, 360).
+func (d *LSM303) Heading() (float64, error) {
+	return d.measureHeading()
+}
+
+// Run does nothing: the readings are taken when requested.
+//
+// Deprecated: poll the sensor with a poll.Scheduler instead.
+func (d *LSM303) Run() error {
+	return nil
+}
+
+// Close puts the LSM303 into sleep mode.
 func (d *LSM303) Close() error {
-	if d.quit != nil {
-		d.quit <- struct{}{}
+	if d.accelInitialized {
+		if err := d.Bus.WriteByteToReg(accelAddress, accelCtrlReg1, 0); err != nil {
+			return err
+		}
 	}
 	return d.Bus.WriteByteToReg(magAddress, magModeReg, MagSleep)
 }
@@ -0,0 +1,82 @@
+package lsm303
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/kidoman/embd/host/sim"
+	"github.com/kidoman/embd/units"
+)
+
+type fakeBus struct {
+	sim.I2CBus
+
+	mag, accel []byte
+	writes     map[[2]byte]byte
+}
+
+func newFakeBus() *fakeBus {
+	return &fakeBus{writes: make(map[[2]byte]byte)}
+}
+
+func (b *fakeBus) ReadFromReg(addr, reg byte, value []byte) error {
+	switch {
+	case addr == magAddress && reg == magData:
+		copy(value, b.mag)
+	case addr == accelAddress && reg == accelData:
+		copy(value, b.accel)
+	default:
+		return errors.New("unexpected read")
+	}
+	return nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	b.writes[[2]byte{addr, reg}] = value
+	return nil
+}
+
+func TestMagneticField(t *testing.T) {
+	bus := newFakeBus()
+	// X, Y and Z, big endian: 1055, -2110 and 475.
+	bus.mag = []byte{0x04, 0x1F, 0xF7, 0xC2, 0x01, 0xDB}
+	d := New(bus)
+
+	x, y, z, err := d.MagneticField()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != 1 || y != -2 || z != 0.5 {
+		t.Errorf("MagneticField: got %v, %v, %v, want 1, -2, 0.5 gauss", x, y, z)
+	}
+	if mode := bus.writes[[2]byte{magAddress, magModeReg}]; mode != MagContinuous {
+		t.Errorf("Mode: got %#x, want %#x", mode, MagContinuous)
+	}
+}
+
+func TestAcceleration(t *testing.T) {
+	bus := newFakeBus()
+	// X, Y and Z, little endian and left aligned: 1000, -500 and 0 mg.
+	bus.accel = []byte{0x80, 0x3E, 0xC0, 0xE0, 0x00, 0x00}
+	d := New(bus)
+
+	x, y, z, err := d.Acceleration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := units.StandardGravity
+	if math.Abs(float64(x-g)) > 1e-9 || math.Abs(float64(y+g/2)) > 1e-9 || z != 0 {
+		t.Errorf("Acceleration: got %v, %v, %v, want %v, %v, 0", x, y, z, g, -g/2)
+	}
+	if ctrl := bus.writes[[2]byte{accelAddress, accelCtrlReg1}]; ctrl != accelCtrlReg1Default {
+		t.Errorf("Control register 1: got %#x, want %#x", ctrl, accelCtrlReg1Default)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ctrl := bus.writes[[2]byte{accelAddress, accelCtrlReg1}]; ctrl != 0 {
+		t.Errorf("Control register 1 after Close: got %#x, want 0", ctrl)
+	}
+}
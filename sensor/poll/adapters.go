@@ -0,0 +1,80 @@
+// Read functions for the sensor interfaces.
+
+package poll
+
+import (
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
+)
+
+// Thermometer returns a read function whose values are units.Celsius.
+func Thermometer(t sensor.Thermometer) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return t.Temperature()
+	}
+}
+
+// Barometer returns a read function whose values are units.Pascal.
+func Barometer(b sensor.Barometer) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return b.Pressure()
+	}
+}
+
+// Hygrometer returns a read function whose values are
+// units.RelativeHumidity.
+func Hygrometer(h sensor.Hygrometer) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return h.Humidity()
+	}
+}
+
+// Luxmeter returns a read function whose values are units.Lux.
+func Luxmeter(l sensor.Luxmeter) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return l.Illuminance()
+	}
+}
+
+// Gyroscope returns a read function whose values are
+// [3]units.DegreesPerSecond, X first.
+func Gyroscope(g sensor.Gyroscope) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		x, y, z, err := g.AngularRate()
+		if err != nil {
+			return nil, err
+		}
+		return [3]units.DegreesPerSecond{x, y, z}, nil
+	}
+}
+
+// Accelerometer returns a read function whose values are
+// [3]units.MetersPerSecondSquared, X first.
+func Accelerometer(a sensor.Accelerometer) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		x, y, z, err := a.Acceleration()
+		if err != nil {
+			return nil, err
+		}
+		return [3]units.MetersPerSecondSquared{x, y, z}, nil
+	}
+}
+
+// Magnetometer returns a read function whose values are [3]units.Gauss, X
+// first.
+func Magnetometer(m sensor.Magnetometer) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		x, y, z, err := m.MagneticField()
+		if err != nil {
+			return nil, err
+		}
+		return [3]units.Gauss{x, y, z}, nil
+	}
+}
+
+// DistanceSensor returns a read function whose values are units.Meters.
+func DistanceSensor(d sensor.DistanceSensor) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return d.Distance()
+	}
+}
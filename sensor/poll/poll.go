@@ -0,0 +1,293 @@
+// Package poll acquires the readings of sensors in the background, each at
+// its own rate, and publishes them to subscribers.
+//
+// A Scheduler reads the sources added to it from one goroutine per bus, so
+// that sensors sharing a bus are never read concurrently, while sensors on
+// different buses are read independently. Each reading is timestamped and
+// carries the error of the read, if any: a failed read is reported rather
+// than replaced by the last good value.
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// ErrRunning is returned when adding a source to, or running, a scheduler
+// which is already running.
+var ErrRunning = errors.New("poll: scheduler is running")
+
+// Reading is the result of reading a source.
+type Reading struct {
+	// Source is the name of the source read.
+	Source string
+
+	// Time is when the read completed.
+	Time time.Time
+
+	// Value is the value read, nil if the read failed.
+	Value interface{}
+
+	// Err is the error of the read, if any.
+	Err error
+}
+
+// Source is a sensor read by a scheduler.
+type Source struct {
+	// Name identifies the readings of the source.
+	Name string
+
+	// Interval is the interval between reads.
+	Interval time.Duration
+
+	// Bus is the bus the sensor is on, e.g. an embd.I2CBus. Sources on
+	// the same bus are read one at a time. Sources without a bus are read
+	// independently of all others. Bus must be comparable.
+	Bus interface{}
+
+	// Read reads the sensor. The adapters of this package, such as
+	// Thermometer, read the sensor interfaces.
+	Read func() (interface{}, error)
+}
+
+// Scheduler reads its sources at their intervals while running.
+type Scheduler struct {
+	mu      sync.Mutex
+	sources []Source
+	subs    map[*Subscription]struct{}
+	last    map[string]Reading
+	running bool
+}
+
+// NewScheduler creates a new scheduler without sources.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		subs: make(map[*Subscription]struct{}),
+		last: make(map[string]Reading),
+	}
+}
+
+// Add adds src to the sources of the scheduler. Sources cannot be added
+// while the scheduler is running.
+func (s *Scheduler) Add(src Source) error {
+	if src.Interval <= 0 {
+		return fmt.Errorf("poll: invalid interval %v for %q", src.Interval, src.Name)
+	}
+	if src.Read == nil {
+		return fmt.Errorf("poll: no read function for %q", src.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return ErrRunning
+	}
+	for _, o := range s.sources {
+		if o.Name == src.Name {
+			return fmt.Errorf("poll: duplicate source %q", src.Name)
+		}
+	}
+	s.sources = append(s.sources, src)
+	return nil
+}
+
+// Last returns the last reading of the named source, if it was read.
+func (s *Scheduler) Last(name string) (Reading, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.last[name]
+	return r, ok
+}
+
+// Run reads the sources until ctx is done, returning ctx.Err(). The
+// channels of the subscriptions are closed when Run returns.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrRunning
+	}
+	s.running = true
+
+	var groups [][]Source
+	buses := make(map[interface{}]int)
+	for _, src := range s.sources {
+		if src.Bus == nil {
+			groups = append(groups, []Source{src})
+			continue
+		}
+		i, ok := buses[src.Bus]
+		if !ok {
+			i = len(groups)
+			buses[src.Bus] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], src)
+	}
+	s.mu.Unlock()
+
+	glog.V(1).Infof("poll: reading %v sources on %v buses", len(s.sources), len(groups))
+
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		wg.Add(1)
+		go func(g []Source) {
+			defer wg.Done()
+			s.poll(ctx, g)
+		}(g)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = false
+	for sub := range s.subs {
+		sub.close()
+	}
+
+	return ctx.Err()
+}
+
+// poll reads srcs, which share a bus, one at a time until ctx is done.
+func (s *Scheduler) poll(ctx context.Context, srcs []Source) {
+	due := make([]time.Time, len(srcs))
+	now := time.Now()
+	for i := range due {
+		due[i] = now
+	}
+
+	for {
+		next := 0
+		for i := range due {
+			if due[i].Before(due[next]) {
+				next = i
+			}
+		}
+		if d := due[next].Sub(time.Now()); d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		src := srcs[next]
+		v, err := src.Read()
+		r := Reading{Source: src.Name, Time: time.Now(), Value: v, Err: err}
+		if err != nil {
+			r.Value = nil
+			glog.V(1).Infof("poll: reading %v: %v", src.Name, err)
+		}
+		s.publish(r)
+
+		// Skip the reads missed while falling behind, rather than rushing
+		// through them.
+		due[next] = due[next].Add(src.Interval)
+		if due[next].Before(r.Time) {
+			due[next] = r.Time
+		}
+	}
+}
+
+func (s *Scheduler) publish(r Reading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last[r.Source] = r
+	for sub := range s.subs {
+		sub.send(r)
+	}
+}
+
+// Subscribe returns a subscription to the readings of the named sources,
+// or of all sources if none are named, buffering up to n readings.
+func (s *Scheduler) Subscribe(n int, names ...string) *Subscription {
+	if n < 1 {
+		n = 1
+	}
+	c := make(chan Reading, n)
+	sub := &Subscription{C: c, c: c, s: s}
+	if len(names) > 0 {
+		sub.names = make(map[string]bool)
+		for _, name := range names {
+			sub.names[name] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subs[sub] = struct{}{}
+	return sub
+}
+
+// Subscription receives the readings of a scheduler.
+type Subscription struct {
+	// C receives the readings. When its buffer is full, the oldest
+	// reading is dropped to make room for the newest.
+	C <-chan Reading
+	c chan Reading
+
+	s       *Scheduler
+	names   map[string]bool
+	dropped uint64
+	closed  bool
+}
+
+// send sends r without blocking. The scheduler lock is held.
+func (sub *Subscription) send(r Reading) {
+	if sub.names != nil && !sub.names[r.Source] {
+		return
+	}
+	for {
+		select {
+		case sub.c <- r:
+			return
+		default:
+		}
+		select {
+		case <-sub.c:
+			sub.dropped++
+		default:
+		}
+	}
+}
+
+// close closes C. The scheduler lock is held.
+func (sub *Subscription) close() {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(sub.s.subs, sub)
+	close(sub.c)
+}
+
+// Dropped returns the number of readings dropped as C was not read in
+// time.
+func (sub *Subscription) Dropped() uint64 {
+	sub.s.mu.Lock()
+	defer sub.s.mu.Unlock()
+
+	return sub.dropped
+}
+
+// Close stops the subscription and closes C.
+func (sub *Subscription) Close() {
+	sub.s.mu.Lock()
+	defer sub.s.mu.Unlock()
+
+	sub.close()
+}
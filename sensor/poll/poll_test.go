@@ -0,0 +1,149 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/units"
+)
+
+// fakeBus tracks the reads in progress on a bus.
+type fakeBus struct {
+	mu      sync.Mutex
+	active  int
+	overlap bool
+}
+
+func (b *fakeBus) read(d time.Duration) {
+	b.mu.Lock()
+	b.active++
+	if b.active > 1 {
+		b.overlap = true
+	}
+	b.mu.Unlock()
+
+	time.Sleep(d)
+
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+}
+
+type fakeThermometer struct {
+	bus *fakeBus
+	err error
+}
+
+func (t *fakeThermometer) Temperature() (units.Celsius, error) {
+	t.bus.read(time.Millisecond)
+
+	t.bus.mu.Lock()
+	defer t.bus.mu.Unlock()
+
+	return 21.5, t.err
+}
+
+func TestScheduler(t *testing.T) {
+	bus1, bus2 := &fakeBus{}, &fakeBus{}
+	s := NewScheduler()
+	sources := []Source{
+		{Name: "fast", Interval: 5 * time.Millisecond, Bus: bus1, Read: Thermometer(&fakeThermometer{bus: bus1})},
+		{Name: "slow", Interval: 20 * time.Millisecond, Bus: bus1, Read: Thermometer(&fakeThermometer{bus: bus1})},
+		{Name: "other", Interval: 5 * time.Millisecond, Bus: bus2, Read: Thermometer(&fakeThermometer{bus: bus2})},
+	}
+	for _, src := range sources {
+		if err := s.Add(src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(sources[0]); err == nil {
+		t.Error("Add of a duplicate source: got no error")
+	}
+
+	sub := s.Subscribe(100)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	counts := make(map[string]int)
+	for r := range sub.C {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if v, ok := r.Value.(units.Celsius); !ok || v != 21.5 {
+			t.Errorf("Reading of %v: got %v, want 21.5°C", r.Source, r.Value)
+		}
+		if r.Time.IsZero() {
+			t.Errorf("Reading of %v: got no time", r.Source)
+		}
+		counts[r.Source]++
+	}
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Errorf("Run: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if counts["fast"] < 10 || counts["fast"] > 22 {
+		t.Errorf("Readings of fast: got %v in 100ms every 5ms", counts["fast"])
+	}
+	if counts["slow"] < 3 || counts["slow"] > 6 {
+		t.Errorf("Readings of slow: got %v in 100ms every 20ms", counts["slow"])
+	}
+	if bus1.overlap {
+		t.Error("Bus: got concurrent reads")
+	}
+	if r, ok := s.Last("other"); !ok || r.Value != units.Celsius(21.5) {
+		t.Errorf("Last: got %v, %v", r, ok)
+	}
+}
+
+func TestSchedulerErrors(t *testing.T) {
+	errRead := errors.New("read failed")
+	bus := &fakeBus{}
+	therm := &fakeThermometer{bus: bus}
+	s := NewScheduler()
+	if err := s.Add(Source{Name: "t", Interval: 5 * time.Millisecond, Read: Thermometer(therm)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Source{Name: "bad", Read: Thermometer(therm)}); err == nil {
+		t.Error("Add without interval: got no error")
+	}
+
+	sub := s.Subscribe(1, "t")
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx)
+
+	r := <-sub.C
+	if r.Err != nil || r.Value != units.Celsius(21.5) {
+		t.Fatalf("First reading: got %v", r)
+	}
+	bus.mu.Lock()
+	therm.err = errRead
+	bus.mu.Unlock()
+	for r = range sub.C {
+		if r.Err != nil {
+			break
+		}
+	}
+	if r.Err != errRead || r.Value != nil {
+		t.Errorf("Failed reading: got value %v and error %v, want no value and %v", r.Value, r.Err, errRead)
+	}
+	if err := s.Add(Source{Name: "late", Interval: time.Second, Read: Thermometer(therm)}); err != ErrRunning {
+		t.Errorf("Add while running: got %v, want %v", err, ErrRunning)
+	}
+
+	// A subscription which is not read drops its oldest readings.
+	time.Sleep(20 * time.Millisecond)
+	if sub.Dropped() == 0 {
+		t.Error("Dropped: got 0")
+	}
+
+	cancel()
+	for range sub.C {
+	}
+}
@@ -0,0 +1,44 @@
+package sensor
+
+import "github.com/kidoman/embd/units"
+
+// Thermometer measures temperature.
+type Thermometer interface {
+	Temperature() (units.Celsius, error)
+}
+
+// Barometer measures atmospheric pressure.
+type Barometer interface {
+	Pressure() (units.Pascal, error)
+}
+
+// Hygrometer measures relative humidity.
+type Hygrometer interface {
+	Humidity() (units.RelativeHumidity, error)
+}
+
+// Luxmeter measures illuminance.
+type Luxmeter interface {
+	Illuminance() (units.Lux, error)
+}
+
+// Gyroscope measures the angular rate around its X, Y and Z axes.
+type Gyroscope interface {
+	AngularRate() (x, y, z units.DegreesPerSecond, err error)
+}
+
+// Accelerometer measures the acceleration along its X, Y and Z axes,
+// including gravity.
+type Accelerometer interface {
+	Acceleration() (x, y, z units.MetersPerSecondSquared, err error)
+}
+
+// Magnetometer measures the magnetic field along its X, Y and Z axes.
+type Magnetometer interface {
+	MagneticField() (x, y, z units.Gauss, err error)
+}
+
+// DistanceSensor measures the distance to the closest obstruction.
+type DistanceSensor interface {
+	Distance() (units.Meters, error)
+}
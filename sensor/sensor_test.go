@@ -0,0 +1,31 @@
+package sensor_test
+
+import (
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/sensor/bh1750fvi"
+	"github.com/kidoman/embd/sensor/bmp085"
+	"github.com/kidoman/embd/sensor/bmp180"
+	"github.com/kidoman/embd/sensor/l3gd20"
+	"github.com/kidoman/embd/sensor/lsm303"
+	"github.com/kidoman/embd/sensor/tmp006"
+	"github.com/kidoman/embd/sensor/us020"
+)
+
+// The in-tree sensors implement the interfaces of the quantities they
+// measure.
+var (
+	_ sensor.Thermometer = (*bmp085.BMP085)(nil)
+	_ sensor.Barometer   = (*bmp085.BMP085)(nil)
+	_ sensor.Thermometer = (*bmp180.BMP180)(nil)
+	_ sensor.Barometer   = (*bmp180.BMP180)(nil)
+	_ sensor.Luxmeter    = (*bh1750fvi.BH1750FVI)(nil)
+
+	_ sensor.Gyroscope   = (*l3gd20.L3GD20)(nil)
+	_ sensor.Thermometer = (*l3gd20.L3GD20)(nil)
+
+	_ sensor.Magnetometer  = (*lsm303.LSM303)(nil)
+	_ sensor.Accelerometer = (*lsm303.LSM303)(nil)
+
+	_ sensor.Thermometer    = (*tmp006.TMP006)(nil)
+	_ sensor.DistanceSensor = (*us020.US020)(nil)
+)
@@ -10,6 +10,8 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor/poll"
+	"github.com/kidoman/embd/units"
 )
 
 const (
@@ -65,7 +67,7 @@ type TMP006 struct {
 
 	rawDieTemps chan float64
 	objTemps    chan float64
-	closing     chan chan struct{}
+	closed      bool
 }
 
 // New creates a new TMP006 sensor.
@@ -86,16 +88,20 @@ func (d *TMP006) validate() error {
 	return nil
 }
 
-// Close puts the device into low power mode.
+// Close puts the device into low power mode, and closes the channels of
+// RawDieTemps and ObjTemps. The scheduler reading the sensor should be
+// stopped first: the readings taken after Close are not sent.
 func (d *TMP006) Close() error {
 	if err := d.setup(); err != nil {
 		return err
 	}
-	if d.closing != nil {
-		waitc := make(chan struct{})
-		d.closing <- waitc
-		<-waitc
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.rawDieTemps)
+		close(d.objTemps)
 	}
+	d.mu.Unlock()
 	glog.V(1).Infof("tmp006: resetting")
 	if err := d.Bus.WriteWordToReg(d.Addr, configReg, reset); err != nil {
 		return err
@@ -146,6 +152,10 @@ func (d *TMP006) setup() error {
 		glog.V(1).Infof("tmp006: sample rate = nil, using SR16")
 		d.SampleRate = SR16
 	}
+	if d.rawDieTemps == nil {
+		d.rawDieTemps = make(chan float64, 1)
+		d.objTemps = make(chan float64, 1)
+	}
 	glog.V(1).Infof("tmp006: configuring with %#04x", configRegDefault|d.SampleRate.enabler)
 	if err := d.Bus.WriteWordToReg(d.Addr, configReg, configRegDefault|d.SampleRate.enabler); err != nil {
 		return err
@@ -223,92 +233,92 @@ func (d *TMP006) measureObjTemp() (float64, error) {
 
 // RawDieTemp returns the current raw die temp reading.
 func (d *TMP006) RawDieTemp() (float64, error) {
-	select {
-	case temp := <-d.rawDieTemps:
-		return temp, nil
-	default:
-		return d.measureRawDieTemp()
-	}
+	return d.measureRawDieTemp()
 }
 
-// RawDieTemps returns a channel to get future raw die temps from.
+// RawDieTemps returns a channel receiving the latest raw die temp read by
+// the Sources of the sensor, until Close closes it.
 func (d *TMP006) RawDieTemps() <-chan float64 {
 	return d.rawDieTemps
 }
 
 // ObjTemp returns the current obj temp reading.
 func (d *TMP006) ObjTemp() (float64, error) {
-	select {
-	case temp := <-d.objTemps:
-		return temp, nil
-	default:
-		return d.measureObjTemp()
-	}
+	return d.measureObjTemp()
+}
+
+// Temperature returns the temperature of the object in view, as ObjTemp.
+func (d *TMP006) Temperature() (units.Celsius, error) {
+	t, err := d.ObjTemp()
+	return units.Celsius(t), err
 }
 
-// ObjTemps returns a channel to fetch obj temps from.
+// ObjTemps returns a channel receiving the latest obj temp read by the
+// Sources of the sensor, until Close closes it.
 func (d *TMP006) ObjTemps() <-chan float64 {
 	return d.objTemps
 }
 
-// Start starts the data acquisition loop.
-func (d *TMP006) Start() error {
-	if err := d.setup(); err != nil {
-		return err
+// Sources returns two sources reading the sensor at its sample rate,
+// named name+".die" for the raw die temp and name+".obj" for the obj temp.
+// The values of the readings are float64s.
+func (d *TMP006) Sources(name string) []poll.Source {
+	interval := time.Duration(d.sampleRate().timeRequired*1000) * time.Millisecond
+	return []poll.Source{
+		{Name: name + ".die", Interval: interval, Bus: d.Bus, Read: func() (interface{}, error) {
+			t, err := d.measureRawDieTemp()
+			if err == nil {
+				d.publish(d.rawDieTemps, t)
+			}
+			return t, err
+		}},
+		{Name: name + ".obj", Interval: interval, Bus: d.Bus, Read: func() (interface{}, error) {
+			t, err := d.measureObjTemp()
+			if err == nil {
+				d.publish(d.objTemps, t)
+			}
+			return t, err
+		}},
 	}
+}
 
-	d.rawDieTemps = make(chan float64)
-	d.objTemps = make(chan float64)
+func (d *TMP006) sampleRate() *SampleRate {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	go func() {
-		var rawDieTemp, objTemp float64
-		var rdtAvlb, otAvlb bool
-		var err error
-		var timer <-chan time.Time
-		resetTimer := func() {
-			timer = time.After(time.Duration(d.SampleRate.timeRequired*1000) * time.Millisecond)
-		}
-		resetTimer()
+	if d.SampleRate == nil {
+		return SR16
+	}
+	return d.SampleRate
+}
 
-		for {
-			var rawDieTemps, objTemps chan float64
+// publish sends t on c, replacing the value not received yet, if any.
+func (d *TMP006) publish(c chan float64, t float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-			if rdtAvlb {
-				rawDieTemps = d.rawDieTemps
-			}
-			if otAvlb {
-				objTemps = d.objTemps
-			}
+	if d.closed {
+		return
+	}
+	select {
+	case <-c:
+	default:
+	}
+	c <- t
+}
 
-			select {
-			case <-timer:
-				var rdt float64
-				if rdt, err = d.measureRawDieTemp(); err != nil {
-					glog.Errorf("tmp006: %v", err)
-				} else {
-					rawDieTemp = rdt
-					rdtAvlb = true
-				}
-				var ot float64
-				if ot, err = d.measureObjTemp(); err != nil {
-					glog.Errorf("tmp006: %v", err)
-				} else {
-					objTemp = ot
-					otAvlb = true
-				}
-				resetTimer()
-			case rawDieTemps <- rawDieTemp:
-				rdtAvlb = false
-			case objTemps <- objTemp:
-				otAvlb = false
-			case waitc := <-d.closing:
-				waitc <- struct{}{}
-				close(d.rawDieTemps)
-				close(d.objTemps)
-				return
-			}
-		}
-	}()
+// Start adds the Sources of the sensor, named "tmp006.die" and
+// "tmp006.obj", to s, which must not be running yet. The readings are sent
+// on RawDieTemps and ObjTemps while s runs.
+func (d *TMP006) Start(s *poll.Scheduler) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
 
+	for _, src := range d.Sources("tmp006") {
+		if err := s.Add(src); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,100 @@
+package tmp006
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/host/sim"
+	"github.com/kidoman/embd/sensor/poll"
+)
+
+const testAddr = 0x40
+
+// fakeBus is a sensor whose die is at 25°C.
+type fakeBus struct {
+	sim.I2CBus
+
+	config uint16
+}
+
+func (b *fakeBus) ReadWordFromReg(addr, reg byte) (uint16, error) {
+	switch reg {
+	case tempAmbReg:
+		return 800 << 2, nil
+	case vObjReg:
+		return 0, nil
+	}
+	return 0, errors.New("unexpected read")
+}
+
+func (b *fakeBus) WriteWordToReg(addr, reg byte, value uint16) error {
+	if addr != testAddr || reg != configReg {
+		return errors.New("unexpected write")
+	}
+	b.config = value
+	return nil
+}
+
+func TestSources(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus, testAddr)
+	srcs := d.Sources("ir")
+	if len(srcs) != 2 || srcs[0].Name != "ir.die" || srcs[1].Name != "ir.obj" {
+		t.Fatalf("Sources: got %+v", srcs)
+	}
+	for _, src := range srcs {
+		if src.Interval != 4*time.Second || src.Bus != bus {
+			t.Errorf("Source %v: got interval %v and bus %v, want 4s and the bus of the sensor", src.Name, src.Interval, src.Bus)
+		}
+	}
+
+	v, err := srcs[0].Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 25.0 {
+		t.Errorf("Reading of ir.die: got %v, want 25", v)
+	}
+	if bus.config != configRegDefault|SR16.enabler {
+		t.Errorf("Config: got %#04x, want %#04x", bus.config, configRegDefault|SR16.enabler)
+	}
+	obj, err := srcs[1].Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := d.ObjTemp(); obj != want {
+		t.Errorf("Reading of ir.obj: got %v, want %v", obj, want)
+	}
+	if die := <-d.RawDieTemps(); die != 25 {
+		t.Errorf("RawDieTemps: got %v, want 25", die)
+	}
+	if o := <-d.ObjTemps(); o != obj {
+		t.Errorf("ObjTemps: got %v, want %v", o, obj)
+	}
+
+	s := poll.NewScheduler()
+	if err := d.Start(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(d.Sources("tmp006")[0]); err == nil {
+		t.Error("Start: tmp006.die was not added to the scheduler")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bus.config != reset {
+		t.Errorf("Config after Close: got %#04x, want %#04x", bus.config, reset)
+	}
+	// Readings after Close are not sent, and the channels are closed.
+	if _, err := srcs[0].Read(); err != nil {
+		t.Fatal(err)
+	}
+	for range d.RawDieTemps() {
+		t.Error("RawDieTemps after Close: got a reading")
+	}
+	for range d.ObjTemps() {
+		t.Error("ObjTemps after Close: got a reading")
+	}
+}
@@ -2,26 +2,35 @@
 package us020
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
 )
 
 const (
 	pulseDelay  = 30000 * time.Nanosecond
 	defaultTemp = 25
+
+	// DefaultTimeout is the time to wait for an echo when Timeout is not
+	// set. Without an obstruction in range, the echo lasts about 38ms.
+	DefaultTimeout = 100 * time.Millisecond
 )
 
+// Thermometer gives the temperature of the air, which the speed of sound
+// depends on.
 type Thermometer interface {
-	Temperature() (float64, error)
+	sensor.Thermometer
 }
 
 type nullThermometer struct {
 }
 
-func (*nullThermometer) Temperature() (float64, error) {
+func (*nullThermometer) Temperature() (units.Celsius, error) {
 	return defaultTemp, nil
 }
 
@@ -33,6 +42,9 @@ type US020 struct {
 
 	Thermometer Thermometer
 
+	// Timeout bounds the wait for an echo in Distance.
+	Timeout time.Duration
+
 	speedSound float64
 
 	initialized bool
@@ -64,7 +76,7 @@ func (d *US020) setup() error {
 	}
 
 	if temp, err := d.Thermometer.Temperature(); err == nil {
-		d.speedSound = 331.3 + 0.606*temp
+		d.speedSound = 331.3 + 0.606*float64(temp)
 
 		glog.V(1).Infof("us020: read a temperature of %v, so speed of sound = %v", temp, d.speedSound)
 	} else {
@@ -77,7 +89,21 @@ func (d *US020) setup() error {
 }
 
 // Distance computes the distance of the bot from the closest obstruction.
-func (d *US020) Distance() (float64, error) {
+// It fails with an *embd.PulseTimeoutError if no echo is received within
+// Timeout, e.g. when the sensor is disconnected.
+func (d *US020) Distance() (units.Meters, error) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return d.DistanceContext(ctx)
+}
+
+// DistanceContext is like Distance, waiting for the echo until ctx is done.
+func (d *US020) DistanceContext(ctx context.Context) (units.Meters, error) {
 	if err := d.setup(); err != nil {
 		return 0, err
 	}
@@ -91,13 +117,13 @@ func (d *US020) Distance() (float64, error) {
 
 	glog.V(2).Infof("us020: waiting for echo to go high")
 
-	duration, err := d.EchoPin.TimePulse(embd.High)
+	duration, err := embd.TimePulse(ctx, d.EchoPin, embd.High)
 	if err != nil {
 		return 0, err
 	}
 
 	// Calculate the distance based on the time computed
-	distance := float64(duration.Nanoseconds()) / 10000000 * (d.speedSound / 2)
+	distance := units.Meters(duration.Seconds() * d.speedSound / 2)
 
 	return distance, nil
 }
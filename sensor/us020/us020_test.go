@@ -0,0 +1,51 @@
+package us020
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/host/sim"
+	"github.com/kidoman/embd/units"
+)
+
+// echoPin answers every trigger with an echo lasting echo.
+type echoPin struct {
+	*sim.Pin
+	echo time.Duration
+}
+
+func (p *echoPin) TimePulses(ctx context.Context, state, n int) ([]time.Duration, error) {
+	return []time.Duration{p.echo}, nil
+}
+
+type fakeThermometer units.Celsius
+
+func (t fakeThermometer) Temperature() (units.Celsius, error) {
+	return units.Celsius(t), nil
+}
+
+func TestDistance(t *testing.T) {
+	echo := &echoPin{Pin: sim.NewPin(0), echo: 5 * time.Millisecond}
+	d := New(echo, sim.NewPin(1), fakeThermometer(20))
+
+	dist, err := d.Distance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sound travels at 343.42m/s at 20°C, and to the obstruction and back.
+	if want := 0.005 * 343.42 / 2; math.Abs(float64(dist)-want) > 1e-9 {
+		t.Errorf("Distance: got %v, want %vm", dist, want)
+	}
+}
+
+func TestDistanceTimeout(t *testing.T) {
+	d := New(sim.NewPin(0), sim.NewPin(1), nil)
+	d.Timeout = 10 * time.Millisecond
+
+	if _, err := d.Distance(); !embd.IsPulseTimeout(err) {
+		t.Errorf("Distance without echo: got %v, want a pulse timeout", err)
+	}
+}
@@ -3,15 +3,21 @@
 package embd
 
 import (
+	"fmt"
 	"io"
+	"time"
 )
 
+// The SPIMode type represents the mode of a spi device: one of SPIMode0-3,
+// optionally combined with the flags below.
+type SPIMode uint32
+
 const (
-	spiCpha = 0x01
-	spiCpol = 0x02
+	spiCpha SPIMode = 0x01
+	spiCpol SPIMode = 0x02
 
 	// SPIMode0 represents the mode0 operation (CPOL=0 CPHA=0) of spi.
-	SPIMode0 = (0 | 0)
+	SPIMode0 SPIMode = (0 | 0)
 
 	// SPIMode1 represents the mode0 operation (CPOL=0 CPHA=1) of spi.
 	SPIMode1 = (0 | spiCpha)
@@ -21,8 +27,95 @@ const (
 
 	// SPIMode3 represents the mode0 operation (CPOL=1 CPHA=1) of spi.
 	SPIMode3 = (spiCpol | spiCpha)
+
+	// SPICSHigh makes the chip select active high.
+	SPICSHigh SPIMode = 0x04
+
+	// SPILSBFirst transmits the least significant bit of each word first.
+	SPILSBFirst SPIMode = 0x08
+
+	// SPI3Wire shares a single data line (SI/SO) between both directions.
+	SPI3Wire SPIMode = 0x10
+
+	// SPINoCS does not drive the chip select during transfers.
+	SPINoCS SPIMode = 0x40
+
+	// SPITxDual transmits over two data lines.
+	SPITxDual SPIMode = 0x100
+
+	// SPITxQuad transmits over four data lines.
+	SPITxQuad SPIMode = 0x200
+
+	// SPIRxDual receives over two data lines.
+	SPIRxDual SPIMode = 0x400
+
+	// SPIRxQuad receives over four data lines.
+	SPIRxQuad SPIMode = 0x800
+
+	spiModeMask = spiCpha | spiCpol | SPICSHigh | SPILSBFirst | SPI3Wire | SPINoCS | SPITxDual | SPITxQuad | SPIRxDual | SPIRxQuad
 )
 
+// Validate checks that the mode only carries known flags and that the
+// flags do not contradict each other.
+func (m SPIMode) Validate() error {
+	if m&^spiModeMask != 0 {
+		return fmt.Errorf("spi: mode %#x has unknown flags %#x", uint32(m), uint32(m&^spiModeMask))
+	}
+	if m&SPITxDual != 0 && m&SPITxQuad != 0 {
+		return fmt.Errorf("spi: mode %#x has both dual and quad tx", uint32(m))
+	}
+	if m&SPIRxDual != 0 && m&SPIRxQuad != 0 {
+		return fmt.Errorf("spi: mode %#x has both dual and quad rx", uint32(m))
+	}
+	if m&SPI3Wire != 0 && m&(SPITxDual|SPITxQuad|SPIRxDual|SPIRxQuad) != 0 {
+		return fmt.Errorf("spi: mode %#x combines 3-wire with dual/quad transfers", uint32(m))
+	}
+	return nil
+}
+
+// SPISegment describes one segment of a SPI transaction. All the segments
+// of a transaction are carried out under a single chip select assertion,
+// unless CSChange is set.
+type SPISegment struct {
+	// Tx holds the data to transmit. If nil, zeros are transmitted.
+	Tx []byte
+
+	// Rx receives the data read during the segment. If nil, the data read
+	// is discarded. If both Tx and Rx are provided, they must be of the
+	// same length.
+	Rx []byte
+
+	// Speed overrides the speed (in Hz) of the bus for the segment when
+	// non zero.
+	Speed int
+
+	// BPW overrides the bits per word of the bus for the segment when
+	// non zero.
+	BPW int
+
+	// Delay is the time to wait after the segment before starting the next
+	// segment or deasserting the chip select.
+	Delay time.Duration
+
+	// TxNBits and RxNBits select the number of data lines (1, 2 or 4) used
+	// to transmit and receive during the segment, when the mode of the bus
+	// allows dual or quad transfers. Zero means a single line.
+	TxNBits, RxNBits int
+
+	// CSChange deasserts the chip select after the segment. If set on the
+	// last segment, the chip select is left asserted after the transaction
+	// on controllers which support it.
+	CSChange bool
+}
+
+// Len returns the number of bytes transferred during the segment.
+func (s *SPISegment) Len() int {
+	if len(s.Tx) > len(s.Rx) {
+		return len(s.Tx)
+	}
+	return len(s.Rx)
+}
+
 // SPIBus interface allows interaction with the SPI bus.
 type SPIBus interface {
 	io.Writer
@@ -39,6 +132,14 @@ type SPIBus interface {
 	// ReceiveByte receives a byte data.
 	ReceiveByte() (byte, error)
 
+	// Transaction carries out the segments as a single transaction,
+	// allowing devices which need separate command and data phases under
+	// one chip select to be driven.
+	Transaction(segments ...SPISegment) error
+
+	// Mode returns the mode of the bus as read back from the controller.
+	Mode() (SPIMode, error)
+
 	// Close releases the resources associated with the bus.
 	Close() error
 }
@@ -46,47 +147,97 @@ type SPIBus interface {
 // SPIDriver interface interacts with the host descriptors to allow us
 // control of SPI communication.
 type SPIDriver interface {
-	// Bus returns a SPIBus interface which allows us to use spi functionalities
-	Bus(byte, byte, int, int, int) SPIBus
+	// Bus returns a SPIBus interface which allows us to use spi functionalities.
+	// The underlying device is opened immediately and any error is returned.
+	Bus(SPIMode, byte, int, int, int) (SPIBus, error)
 
 	// Close cleans up all the initialized SPIbus
 	Close() error
 }
 
-var spiDriverInitialized bool
-var spiDriverInstance SPIDriver
+// InitSPI initializes the SPI driver of the board.
+func (b *Board) InitSPI() error {
+	_, err := b.SPIDriver()
+	return err
+}
 
-// InitSPI initializes the SPI driver.
-func InitSPI() error {
-	if spiDriverInitialized {
-		return nil
+// SPIDriver returns the SPI driver of the board, initializing it if
+// required.
+func (b *Board) SPIDriver() (SPIDriver, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.spiDriver != nil {
+		return b.spiDriver, nil
 	}
 
-	desc, err := DescribeHost()
+	desc, err := b.descriptor()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if desc.SPIDriver == nil {
-		return ErrFeatureNotSupported
+		return nil, ErrFeatureNotSupported
 	}
 
-	spiDriverInstance = desc.SPIDriver()
-	spiDriverInitialized = true
+	b.spiDriver = desc.SPIDriver()
 
-	return nil
+	return b.spiDriver, nil
 }
 
-// CloseSPI releases resources associated with the SPI driver.
-func CloseSPI() error {
-	return spiDriverInstance.Close()
+// CloseSPI releases resources associated with the SPI driver of the board.
+func (b *Board) CloseSPI() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.spiDriver == nil {
+		return nil
+	}
+
+	err := b.spiDriver.Close()
+	b.spiDriver = nil
+
+	return err
+}
+
+// OpenSPIBus returns a SPIBus of the board. A host without SPI support
+// results in ErrFeatureNotSupported, while a missing device node or
+// insufficient permissions are reported as a *DeviceError.
+func (b *Board) OpenSPIBus(mode SPIMode, channel byte, speed, bpw, delay int) (SPIBus, error) {
+	drv, err := b.SPIDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return drv.Bus(mode, channel, speed, bpw, delay)
 }
 
-// NewSPIBus returns a SPIBus.
-func NewSPIBus(mode, channel byte, speed, bpw, delay int) SPIBus {
-	if err := InitSPI(); err != nil {
+// NewSPIBus is like OpenSPIBus but panics if the bus cannot be opened.
+func (b *Board) NewSPIBus(mode SPIMode, channel byte, speed, bpw, delay int) SPIBus {
+	bus, err := b.OpenSPIBus(mode, channel, speed, bpw, delay)
+	if err != nil {
 		panic(err)
 	}
 
-	return spiDriverInstance.Bus(mode, channel, speed, bpw, delay)
+	return bus
+}
+
+// InitSPI initializes the SPI driver.
+func InitSPI() error {
+	return defaultBoard.InitSPI()
+}
+
+// CloseSPI releases resources associated with the SPI driver.
+func CloseSPI() error {
+	return defaultBoard.CloseSPI()
+}
+
+// OpenSPIBus returns a SPIBus, reporting any error opening it up front.
+func OpenSPIBus(mode SPIMode, channel byte, speed, bpw, delay int) (SPIBus, error) {
+	return defaultBoard.OpenSPIBus(mode, channel, speed, bpw, delay)
+}
+
+// NewSPIBus is like OpenSPIBus but panics if the bus cannot be opened.
+func NewSPIBus(mode SPIMode, channel byte, speed, bpw, delay int) SPIBus {
+	return defaultBoard.NewSPIBus(mode, channel, speed, bpw, delay)
 }
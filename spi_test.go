@@ -0,0 +1,25 @@
+package embd
+
+import "testing"
+
+func TestSPIModeValidate(t *testing.T) {
+	var tests = []struct {
+		mode  SPIMode
+		valid bool
+	}{
+		{SPIMode0, true},
+		{SPIMode3 | SPILSBFirst | SPICSHigh, true},
+		{SPIMode0 | SPI3Wire | SPINoCS, true},
+		{SPIMode1 | SPITxQuad | SPIRxDual, true},
+		{SPITxDual | SPITxQuad, false},
+		{SPIRxDual | SPIRxQuad, false},
+		{SPI3Wire | SPIRxDual, false},
+		{0x1000, false},
+	}
+	for _, test := range tests {
+		err := test.mode.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("Validating mode %#x: got %v, want valid %v", uint32(test.mode), err, test.valid)
+		}
+	}
+}
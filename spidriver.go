@@ -1,14 +1,46 @@
 package embd
 
-import "sync"
+import (
+	"errors"
+	"sync"
+)
 
-type spiBusFactory func(int, byte, byte, int, int, int, func() error) SPIBus
+type spiBusFactory func(int, SPIMode, byte, int, int, int, func() error) SPIBus
+
+// spiConfigurer is implemented by buses which can change their transfer
+// parameters between transfers, allowing several devices with differing
+// settings to share a bus.
+type spiConfigurer interface {
+	Configure(mode SPIMode, speed, bpw, delay int) error
+}
+
+// errSPIBusClosed is returned when using a device whose bus has been closed.
+var errSPIBusClosed = errors.New("spi: bus is closed")
+
+type spiConfig struct {
+	mode  SPIMode
+	speed int
+	bpw   int
+	delay int
+}
+
+// sharedSPIBus is the bus of a channel, shared by all the devices
+// requested on that channel.
+type sharedSPIBus struct {
+	bus SPIBus
+
+	mu     sync.Mutex // Guards the following and serializes transfers.
+	cfg    spiConfig
+	closed bool
+
+	refs int
+}
 
 type spiDriver struct {
 	spiDevMinor int
 	initializer func() error
 
-	busMap     map[byte]SPIBus
+	busMap     map[byte]*sharedSPIBus
 	busMapLock sync.Mutex
 
 	sbf spiBusFactory
@@ -21,25 +53,194 @@ func NewSPIDriver(spiDevMinor int, sbf spiBusFactory, i func() error) SPIDriver
 		spiDevMinor: spiDevMinor,
 		sbf:         sbf,
 		initializer: i,
+		busMap:      make(map[byte]*sharedSPIBus),
+	}
+}
+
+// Bus returns a SPIBus interface which allows us to use spi functionalities.
+// The bus of a channel is opened once and shared between all the devices
+// on that channel; each device keeps its own mode, speed, bpw and delay,
+// and the bus is reconfigured between transfers as required.
+func (s *spiDriver) Bus(mode SPIMode, channel byte, speed, bpw, delay int) (SPIBus, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.busMapLock.Lock()
+	defer s.busMapLock.Unlock()
+
+	cfg := spiConfig{mode: mode, speed: speed, bpw: bpw, delay: delay}
+
+	sb, ok := s.busMap[channel]
+	if !ok {
+		b := s.sbf(s.spiDevMinor, mode, channel, speed, bpw, delay, s.initializer)
+		if o, ok := b.(opener); ok {
+			if err := o.Open(); err != nil {
+				return nil, err
+			}
+		}
+		sb = &sharedSPIBus{bus: b, cfg: cfg}
+		s.busMap[channel] = sb
 	}
+	sb.refs++
+
+	return &spiDevice{drv: s, channel: channel, shared: sb, cfg: cfg}, nil
 }
 
-// Bus returns a SPIBus interface which allows us to use spi functionalities
-func (s *spiDriver) Bus(mode, channel byte, speed, bpw, delay int) SPIBus {
+// release drops a reference to the bus of a channel, closing it once the
+// last device on the channel is closed.
+func (s *spiDriver) release(channel byte, sb *sharedSPIBus) error {
 	s.busMapLock.Lock()
 	defer s.busMapLock.Unlock()
 
-	b := s.sbf(s.spiDevMinor, mode, channel, speed, bpw, delay, s.initializer)
-	s.busMap = make(map[byte]SPIBus)
-	s.busMap[channel] = b
-	return b
+	sb.refs--
+	if sb.refs > 0 || s.busMap[channel] != sb {
+		return nil
+	}
+	delete(s.busMap, channel)
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	sb.closed = true
+	return sb.bus.Close()
 }
 
 // Close cleans up all the initialized SPIbus
 func (s *spiDriver) Close() error {
-	for _, b := range s.busMap {
-		b.Close()
+	s.busMapLock.Lock()
+	defer s.busMapLock.Unlock()
+
+	var firstErr error
+	for channel, sb := range s.busMap {
+		sb.mu.Lock()
+		sb.closed = true
+		if err := sb.bus.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		sb.mu.Unlock()
+
+		delete(s.busMap, channel)
+	}
+
+	return firstErr
+}
+
+// spiDevice is a handle to a shared bus carrying per device settings.
+type spiDevice struct {
+	drv     *spiDriver
+	channel byte
+	shared  *sharedSPIBus
+	cfg     spiConfig
+
+	closeOnce sync.Once
+}
+
+// acquire locks the shared bus and reconfigures it for the device if
+// another device changed the settings since. On success, the caller must
+// unlock the shared bus.
+func (d *spiDevice) acquire() (SPIBus, error) {
+	sb := d.shared
+	sb.mu.Lock()
+
+	if sb.closed {
+		sb.mu.Unlock()
+		return nil, errSPIBusClosed
 	}
+	if sb.cfg == d.cfg {
+		return sb.bus, nil
+	}
+
+	c, ok := sb.bus.(spiConfigurer)
+	if !ok {
+		sb.mu.Unlock()
+		return nil, errors.New("spi: bus does not support per device configuration")
+	}
+	if err := c.Configure(d.cfg.mode, d.cfg.speed, d.cfg.bpw, d.cfg.delay); err != nil {
+		sb.mu.Unlock()
+		return nil, err
+	}
+	sb.cfg = d.cfg
+
+	return sb.bus, nil
+}
+
+func (d *spiDevice) Write(data []byte) (int, error) {
+	bus, err := d.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.Write(data)
+}
+
+func (d *spiDevice) TransferAndReceiveData(dataBuffer []uint8) error {
+	bus, err := d.acquire()
+	if err != nil {
+		return err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.TransferAndReceiveData(dataBuffer)
+}
+
+func (d *spiDevice) ReceiveData(len int) ([]uint8, error) {
+	bus, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.ReceiveData(len)
+}
+
+func (d *spiDevice) TransferAndReceiveByte(data byte) (byte, error) {
+	bus, err := d.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.TransferAndReceiveByte(data)
+}
+
+func (d *spiDevice) ReceiveByte() (byte, error) {
+	bus, err := d.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.ReceiveByte()
+}
+
+func (d *spiDevice) Transaction(segments ...SPISegment) error {
+	bus, err := d.acquire()
+	if err != nil {
+		return err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.Transaction(segments...)
+}
+
+func (d *spiDevice) Mode() (SPIMode, error) {
+	bus, err := d.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer d.shared.mu.Unlock()
+
+	return bus.Mode()
+}
 
-	return nil
+// Close releases the device. The bus is closed along with the last device
+// on the channel.
+func (d *spiDevice) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		err = d.drv.release(d.channel, d.shared)
+	})
+	return err
 }
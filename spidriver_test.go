@@ -0,0 +1,117 @@
+package embd
+
+import "testing"
+
+type fakeSPIBus struct {
+	channel byte
+	mode    SPIMode
+	speed   int
+
+	configures int
+	closes     int
+}
+
+func (b *fakeSPIBus) Write(data []byte) (int, error)                  { return len(data), nil }
+func (b *fakeSPIBus) TransferAndReceiveData(dataBuffer []uint8) error { return nil }
+func (b *fakeSPIBus) ReceiveData(len int) ([]uint8, error)            { return make([]uint8, len), nil }
+func (b *fakeSPIBus) TransferAndReceiveByte(data byte) (byte, error)  { return data, nil }
+func (b *fakeSPIBus) ReceiveByte() (byte, error)                      { return 0, nil }
+
+func (b *fakeSPIBus) Transaction(segments ...SPISegment) error { return nil }
+
+func (b *fakeSPIBus) Mode() (SPIMode, error) { return b.mode, nil }
+
+func (b *fakeSPIBus) Configure(mode SPIMode, speed, bpw, delay int) error {
+	b.mode = mode
+	b.speed = speed
+	b.configures++
+	return nil
+}
+
+func (b *fakeSPIBus) Close() error {
+	b.closes++
+	return nil
+}
+
+type fakeSPIFactory struct {
+	buses []*fakeSPIBus
+}
+
+func (f *fakeSPIFactory) newBus(minor int, mode SPIMode, channel byte, speed, bpw, delay int, i func() error) SPIBus {
+	b := &fakeSPIBus{channel: channel, mode: mode, speed: speed}
+	f.buses = append(f.buses, b)
+	return b
+}
+
+func TestSPIDriverBusCaching(t *testing.T) {
+	f := &fakeSPIFactory{}
+	drv := NewSPIDriver(0, f.newBus, nil)
+	if _, err := drv.Bus(SPIMode0, 0, 1000000, 8, 0); err != nil {
+		t.Fatalf("Opening channel 0: got %v", err)
+	}
+	if _, err := drv.Bus(SPIMode0, 1, 1000000, 8, 0); err != nil {
+		t.Fatalf("Opening channel 1: got %v", err)
+	}
+	if _, err := drv.Bus(SPIMode3, 0, 500000, 8, 0); err != nil {
+		t.Fatalf("Opening channel 0 again: got %v", err)
+	}
+	if len(f.buses) != 2 {
+		t.Fatalf("Opening channels 0, 1, 0: got %v buses, want 2", len(f.buses))
+	}
+	if err := drv.Close(); err != nil {
+		t.Fatalf("Closing driver: got %v", err)
+	}
+	for _, b := range f.buses {
+		if b.closes != 1 {
+			t.Errorf("Closing driver: bus %v closed %v times, want 1", b.channel, b.closes)
+		}
+	}
+}
+
+func TestSPIDriverPerDeviceConfig(t *testing.T) {
+	f := &fakeSPIFactory{}
+	drv := NewSPIDriver(0, f.newBus, nil)
+	d1, _ := drv.Bus(SPIMode0, 0, 1000000, 8, 0)
+	d2, _ := drv.Bus(SPIMode3, 0, 500000, 8, 0)
+	bus := f.buses[0]
+
+	if _, err := d1.ReceiveByte(); err != nil {
+		t.Fatalf("Receiving on device 1: got %v", err)
+	}
+	if bus.configures != 0 {
+		t.Fatalf("Receiving on device 1: got %v reconfigurations, want 0", bus.configures)
+	}
+	if _, err := d2.ReceiveByte(); err != nil {
+		t.Fatalf("Receiving on device 2: got %v", err)
+	}
+	if bus.mode != SPIMode3 || bus.speed != 500000 {
+		t.Fatalf("Receiving on device 2: bus configured with mode %v speed %v, want %v %v", bus.mode, bus.speed, SPIMode3, 500000)
+	}
+	if _, err := d1.ReceiveByte(); err != nil {
+		t.Fatalf("Receiving on device 1: got %v", err)
+	}
+	if bus.configures != 2 || bus.mode != SPIMode0 {
+		t.Fatalf("Receiving on device 1 again: got %v reconfigurations with mode %v, want 2 with mode %v", bus.configures, bus.mode, SPIMode0)
+	}
+}
+
+func TestSPIDeviceClose(t *testing.T) {
+	f := &fakeSPIFactory{}
+	drv := NewSPIDriver(0, f.newBus, nil)
+	d1, _ := drv.Bus(SPIMode0, 0, 1000000, 8, 0)
+	d2, _ := drv.Bus(SPIMode0, 0, 1000000, 8, 0)
+	bus := f.buses[0]
+
+	d1.Close()
+	d1.Close()
+	if bus.closes != 0 {
+		t.Fatal("Closing one of two devices: bus was closed")
+	}
+	d2.Close()
+	if bus.closes != 1 {
+		t.Fatalf("Closing both devices: bus closed %v times, want 1", bus.closes)
+	}
+	if _, err := d2.ReceiveByte(); err != errSPIBusClosed {
+		t.Fatalf("Receiving on closed device: got %v, want %v", err, errSPIBusClosed)
+	}
+}
@@ -0,0 +1,120 @@
+// Package units provides types for the physical quantities measured by
+// sensors, so that the unit of a reading is part of its type.
+//
+// Each type is a float64 in the unit it is named after, and converts to
+// the other usual units of its quantity.
+package units
+
+import (
+	"fmt"
+	"math"
+)
+
+// Celsius is a temperature in degrees Celsius.
+type Celsius float64
+
+// Kelvin returns the temperature in kelvins.
+func (c Celsius) Kelvin() float64 {
+	return float64(c) + 273.15
+}
+
+// Fahrenheit returns the temperature in degrees Fahrenheit.
+func (c Celsius) Fahrenheit() float64 {
+	return float64(c)*9/5 + 32
+}
+
+func (c Celsius) String() string {
+	return fmt.Sprintf("%.2f°C", float64(c))
+}
+
+// FromKelvin returns the temperature of k kelvins.
+func FromKelvin(k float64) Celsius {
+	return Celsius(k - 273.15)
+}
+
+// Pascal is a pressure in pascals.
+type Pascal float64
+
+// StandardAtmosphere is the pressure at sea level of the standard
+// atmosphere.
+const StandardAtmosphere Pascal = 101325
+
+// Hectopascals returns the pressure in hectopascals, or millibars.
+func (p Pascal) Hectopascals() float64 {
+	return float64(p) / 100
+}
+
+// Altitude returns the altitude at which the standard atmosphere has the
+// pressure p, given the pressure at sea level.
+func (p Pascal) Altitude(seaLevel Pascal) Meters {
+	return Meters(44330 * (1 - math.Pow(float64(p/seaLevel), 0.190295)))
+}
+
+func (p Pascal) String() string {
+	return fmt.Sprintf("%.2fhPa", p.Hectopascals())
+}
+
+// RelativeHumidity is a relative humidity in percent.
+type RelativeHumidity float64
+
+func (h RelativeHumidity) String() string {
+	return fmt.Sprintf("%.1f%%RH", float64(h))
+}
+
+// Lux is an illuminance in lux.
+type Lux float64
+
+func (l Lux) String() string {
+	return fmt.Sprintf("%.1flx", float64(l))
+}
+
+// DegreesPerSecond is an angular rate in degrees per second.
+type DegreesPerSecond float64
+
+// RadiansPerSecond returns the angular rate in radians per second.
+func (d DegreesPerSecond) RadiansPerSecond() float64 {
+	return float64(d) * math.Pi / 180
+}
+
+func (d DegreesPerSecond) String() string {
+	return fmt.Sprintf("%.2f°/s", float64(d))
+}
+
+// StandardGravity is the acceleration of free fall at sea level.
+const StandardGravity MetersPerSecondSquared = 9.80665
+
+// MetersPerSecondSquared is an acceleration in meters per second squared.
+type MetersPerSecondSquared float64
+
+// G returns the acceleration in multiples of the standard gravity.
+func (a MetersPerSecondSquared) G() float64 {
+	return float64(a / StandardGravity)
+}
+
+func (a MetersPerSecondSquared) String() string {
+	return fmt.Sprintf("%.3fm/s²", float64(a))
+}
+
+// Gauss is a magnetic flux density in gauss.
+type Gauss float64
+
+// Microtesla returns the magnetic flux density in microteslas.
+func (g Gauss) Microtesla() float64 {
+	return float64(g) * 100
+}
+
+func (g Gauss) String() string {
+	return fmt.Sprintf("%.3fG", float64(g))
+}
+
+// Meters is a length in meters.
+type Meters float64
+
+// Centimeters returns the length in centimeters.
+func (m Meters) Centimeters() float64 {
+	return float64(m) * 100
+}
+
+func (m Meters) String() string {
+	return fmt.Sprintf("%.3fm", float64(m))
+}
@@ -0,0 +1,52 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConversions(t *testing.T) {
+	// The conversions are exact but for rounding, except for the
+	// altitude, which is that of the barometric formula to within 0.5m.
+	tests := []struct {
+		name      string
+		got, want float64
+		tol       float64
+	}{
+		{"Celsius(100).Kelvin", Celsius(100).Kelvin(), 373.15, 1e-9},
+		{"Celsius(-40).Fahrenheit", Celsius(-40).Fahrenheit(), -40, 1e-9},
+		{"FromKelvin(0)", float64(FromKelvin(0)), -273.15, 1e-9},
+		{"Pascal(101325).Hectopascals", Pascal(101325).Hectopascals(), 1013.25, 1e-9},
+		{"StandardAtmosphere.Altitude", float64(StandardAtmosphere.Altitude(StandardAtmosphere)), 0, 1e-9},
+		{"Pascal(89875).Altitude", float64(Pascal(89875).Altitude(StandardAtmosphere)), 1000, 0.5},
+		{"DegreesPerSecond(180).RadiansPerSecond", DegreesPerSecond(180).RadiansPerSecond(), math.Pi, 1e-9},
+		{"StandardGravity.G", StandardGravity.G(), 1, 1e-9},
+		{"Gauss(0.5).Microtesla", Gauss(0.5).Microtesla(), 50, 1e-9},
+		{"Meters(1.5).Centimeters", Meters(1.5).Centimeters(), 150, 1e-9},
+	}
+	for _, test := range tests {
+		if math.Abs(test.got-test.want) > test.tol {
+			t.Errorf("%v: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{Celsius(21.5), "21.50°C"},
+		{Pascal(101325), "1013.25hPa"},
+		{RelativeHumidity(45), "45.0%RH"},
+		{Lux(320), "320.0lx"},
+		{Meters(0.25), "0.250m"},
+	}
+	for _, test := range tests {
+		if got := test.v.(interface {
+			String() string
+		}).String(); got != test.want {
+			t.Errorf("String: got %q, want %q", got, test.want)
+		}
+	}
+}
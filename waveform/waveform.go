@@ -0,0 +1,216 @@
+// Package waveform streams sample buffers to an analog output, such as a
+// DAC, at a fixed sample rate.
+//
+// Samples are paced against the time the playback started rather than
+// the previous sample, so that the rate does not drift. A player which
+// falls more than a sample period behind, e.g. as the process was not
+// scheduled in time, resynchronizes rather than rushing through the late
+// samples, and counts them in its statistics.
+package waveform
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// Stats describes how the samples were played.
+type Stats struct {
+	// Samples is the number of samples written to the output.
+	Samples uint64
+
+	// Late is the number of samples written more than a sample period
+	// after they were due.
+	Late uint64
+
+	// Underruns is the number of times Stream received a buffer after
+	// its first sample was due.
+	Underruns uint64
+}
+
+// Player writes samples to an analog output at Rate samples per second.
+type Player struct {
+	Out  embd.AnalogOutput
+	Rate int
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New creates a new player writing to out at rate samples per second.
+func New(out embd.AnalogOutput, rate int) *Player {
+	return &Player{Out: out, Rate: rate}
+}
+
+// schedule gives the time each sample is due.
+type schedule struct {
+	rate  int
+	start time.Time
+	n     int64
+}
+
+func (p *Player) schedule() (*schedule, error) {
+	if p.Rate <= 0 {
+		return nil, fmt.Errorf("waveform: invalid sample rate %v", p.Rate)
+	}
+	return &schedule{rate: p.Rate, start: time.Now()}, nil
+}
+
+func (s *schedule) due() time.Time {
+	return s.start.Add(time.Duration(s.n * int64(time.Second) / int64(s.rate)))
+}
+
+func (s *schedule) period() time.Duration {
+	return time.Second / time.Duration(s.rate)
+}
+
+// next moves on to the next sample, rebasing the schedule every second to
+// keep n small.
+func (s *schedule) next() {
+	s.n++
+	if s.n == int64(s.rate) {
+		s.start = s.start.Add(time.Second)
+		s.n = 0
+	}
+}
+
+func (s *schedule) resync() {
+	s.start = time.Now()
+	s.n = 0
+}
+
+func (p *Player) play(ctx context.Context, s *schedule, samples []int) error {
+	for _, v := range samples {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		due := s.due()
+		if d := due.Sub(time.Now()); d > 0 {
+			time.Sleep(d)
+		}
+		if err := p.Out.Write(v); err != nil {
+			return err
+		}
+
+		late := time.Now().Sub(due) > s.period()
+		p.mu.Lock()
+		p.stats.Samples++
+		if late {
+			p.stats.Late++
+		}
+		p.mu.Unlock()
+
+		if late {
+			s.resync()
+		}
+		s.next()
+	}
+	return nil
+}
+
+// Play writes samples once, returning when the last sample is written or
+// ctx is done.
+func (p *Player) Play(ctx context.Context, samples []int) error {
+	s, err := p.schedule()
+	if err != nil {
+		return err
+	}
+
+	glog.V(1).Infof("waveform: playing %v samples at %v sps", len(samples), p.Rate)
+
+	return p.play(ctx, s, samples)
+}
+
+// Loop writes samples repeatedly until ctx is done, returning ctx.Err().
+func (p *Player) Loop(ctx context.Context, samples []int) error {
+	if len(samples) == 0 {
+		return fmt.Errorf("waveform: no samples to loop")
+	}
+	s, err := p.schedule()
+	if err != nil {
+		return err
+	}
+
+	glog.V(1).Infof("waveform: looping %v samples at %v sps", len(samples), p.Rate)
+
+	for {
+		if err := p.play(ctx, s, samples); err != nil {
+			return err
+		}
+	}
+}
+
+// Stream writes the buffers received from buffers back to back, returning
+// when buffers is closed or ctx is done.
+func (p *Player) Stream(ctx context.Context, buffers <-chan []int) error {
+	s, err := p.schedule()
+	if err != nil {
+		return err
+	}
+
+	glog.V(1).Infof("waveform: streaming at %v sps", p.Rate)
+
+	first := true
+	for {
+		var (
+			buf []int
+			ok  bool
+		)
+		select {
+		case buf, ok = <-buffers:
+		default:
+			select {
+			case buf, ok = <-buffers:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if ok && !first && time.Now().After(s.due()) {
+				p.mu.Lock()
+				p.stats.Underruns++
+				p.mu.Unlock()
+			}
+		}
+		if !ok {
+			return nil
+		}
+		if first {
+			s.resync()
+			first = false
+		}
+		if err := p.play(ctx, s, buf); err != nil {
+			return err
+		}
+	}
+}
+
+// Stats returns the statistics of the player.
+func (p *Player) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
+// ResetStats clears the statistics of the player.
+func (p *Player) ResetStats() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats = Stats{}
+}
+
+// Sine returns n samples of a period of a sine wave spanning 0 to max.
+func Sine(n, max int) []int {
+	samples := make([]int, n)
+	for i := range samples {
+		v := (1 + math.Sin(2*math.Pi*float64(i)/float64(n))) / 2
+		samples[i] = int(math.Floor(v*float64(max) + 0.5))
+	}
+	return samples
+}
@@ -0,0 +1,103 @@
+package waveform
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeOutput struct {
+	values []int
+	times  []time.Time
+}
+
+func (o *fakeOutput) Write(value int) error {
+	o.values = append(o.values, value)
+	o.times = append(o.times, time.Now())
+	return nil
+}
+
+func (o *fakeOutput) Max() int {
+	return 4095
+}
+
+func (o *fakeOutput) Close() error {
+	return nil
+}
+
+func TestPlay(t *testing.T) {
+	out := &fakeOutput{}
+	p := New(out, 1000)
+	samples := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if err := p.Play(context.Background(), samples); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.values, samples) {
+		t.Errorf("Play: got %v, want %v", out.values, samples)
+	}
+	if d := out.times[10].Sub(out.times[0]); d < 10*time.Millisecond {
+		t.Errorf("Play: 11 samples at 1000 sps took %v, want at least 10ms", d)
+	}
+	if s := p.Stats(); s.Samples != 11 {
+		t.Errorf("Stats: got %v samples, want 11", s.Samples)
+	}
+}
+
+func TestLoop(t *testing.T) {
+	out := &fakeOutput{}
+	p := New(out, 2000)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Loop(ctx, []int{1, 2, 3}); err != context.DeadlineExceeded {
+		t.Fatalf("Loop: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(out.values) < 6 {
+		t.Fatalf("Loop: got %v samples in 20ms at 2000 sps", len(out.values))
+	}
+	for i, v := range out.values {
+		if v != i%3+1 {
+			t.Fatalf("Loop: got %v, want repetitions of [1 2 3]", out.values)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	out := &fakeOutput{}
+	p := New(out, 10000)
+	buffers := make(chan []int)
+	go func() {
+		buffers <- []int{1, 2}
+		time.Sleep(5 * time.Millisecond)
+		buffers <- []int{3, 4}
+		close(buffers)
+	}()
+	if err := p.Stream(context.Background(), buffers); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(out.values, want) {
+		t.Errorf("Stream: got %v, want %v", out.values, want)
+	}
+	if s := p.Stats(); s.Underruns != 1 {
+		t.Errorf("Stats: got %v underruns, want 1", s.Underruns)
+	}
+
+	p.ResetStats()
+	if s := p.Stats(); s != (Stats{}) {
+		t.Errorf("Stats after reset: got %+v", s)
+	}
+}
+
+func TestInvalidRate(t *testing.T) {
+	p := New(&fakeOutput{}, 0)
+	if err := p.Play(context.Background(), []int{1}); err == nil {
+		t.Error("Play at 0 sps: got no error")
+	}
+}
+
+func TestSine(t *testing.T) {
+	want := []int{50, 100, 50, 0}
+	if got := Sine(4, 100); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sine(4, 100): got %v, want %v", got, want)
+	}
+}